@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Anchor identifies a record of the chain by its sequence number and hash
+type Anchor struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// Anchors are kept next to the log in path.anchor and updated at every rotation
+type Anchors struct {
+	// Rotated is the last record before the latest rotation, the log has to contain it
+	Rotated *Anchor `json:"rotated,omitempty"`
+	// Pruned is the last record of the newest file removed because of MaxBackups,
+	// the oldest remaining file has to continue it
+	Pruned *Anchor `json:"pruned,omitempty"`
+	// MAC authenticates the anchors if the log has a key
+	MAC string `json:"mac,omitempty"`
+}
+
+func anchorName(path string) string {
+	return path + ".anchor"
+}
+
+// ReadAnchors returns the anchors of the log at path, empty ones if the log was never rotated.
+// With a key their MAC is checked.
+func ReadAnchors(path string, key []byte) (Anchors, error) {
+	var a Anchors
+	b, err := os.ReadFile(anchorName(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	} else if err != nil {
+		return a, err
+	}
+	if err := json.Unmarshal(b, &a); err != nil {
+		return a, fmt.Errorf("%s: %v. %w", anchorName(path), err, ErrInvalidRecord)
+	}
+	if len(key) > 0 {
+		mac, err := a.mac(key)
+		if err != nil {
+			return a, err
+		}
+		if !hmac.Equal([]byte(a.MAC), []byte(mac)) {
+			return a, fmt.Errorf("%s does not match its MAC. %w", anchorName(path), ErrBrokenChain)
+		}
+	}
+	return a, nil
+}
+
+func (a Anchors) mac(key []byte) (string, error) {
+	a.MAC = ""
+	b, err := json.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+	return hashBody(b, key), nil
+}
+
+// writeAnchors replaces path.anchor, through a temporary file so that it is never left half written
+func writeAnchors(path string, a Anchors, key []byte) error {
+	a.MAC = ""
+	if len(key) > 0 {
+		mac, err := a.mac(key)
+		if err != nil {
+			return err
+		}
+		a.MAC = mac
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	tmp := anchorName(path) + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if serr := f.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, anchorName(path))
+}
@@ -0,0 +1,379 @@
+// Package audit records clipboard changes to a tamper-evident log.
+//
+// Every change is written as one JSON line with the time, the owner process and the name, size and
+// SHA-256 hash of every format. Contents are only stored when Options.IncludeContent is set.
+// Each record contains the hash of its predecessor and ends with its own hash over the preceding bytes,
+// so that modifying, removing or reordering records breaks the chain. VerifyLog checks it.
+//
+// With Options.Key the hashes are HMAC-SHA256, without the key nobody can rewrite the chain consistently.
+// Plain SHA-256 hashes only detect changes made without recomputing them.
+// At every rotation the last record and the records removed by MaxBackups are anchored in a separate file,
+// so that truncating the log or removing its oldest files is detected as well.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+var (
+	// ErrInvalidRecord is returned for lines that are not a complete record
+	ErrInvalidRecord = errors.New("invalid audit record")
+	// ErrBrokenChain is returned when a record does not match its hash or does not follow its predecessor
+	ErrBrokenChain = errors.New("audit chain is broken")
+)
+
+// DefaultMaxSize is the size at which a log is rotated when Options.MaxSize is zero
+const DefaultMaxSize = 16 << 20
+
+// Record is a single clipboard change
+type Record struct {
+	// Seq numbers the records of a chain, starting at 1
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	// Owner is the executable path of the clipboard owner, empty if unknown
+	Owner          string         `json:"owner,omitempty"`
+	SequenceNumber uint32         `json:"sequenceNumber"`
+	Formats        []FormatRecord `json:"formats"`
+	// Omitted lists the formats that were not read because they exceeded a size limit
+	Omitted []string `json:"omitted,omitempty"`
+	// Prev is the hash of the previous record, empty for the first one
+	Prev string `json:"prev,omitempty"`
+	// Hash is the hex encoded SHA-256 or HMAC-SHA256 of the record line up to the hash itself.
+	// It has to remain the last field.
+	Hash string `json:"hash,omitempty"`
+}
+
+// FormatRecord describes the data of a single format
+type FormatRecord struct {
+	Name    string `json:"name"`
+	Size    int    `json:"size"`
+	SHA256  string `json:"sha256"`
+	Content []byte `json:"content,omitempty"`
+}
+
+// NewRecord describes the snapshot s, the data itself is only included if includeContent is set
+func NewRecord(s *clipboard.Snapshot, owner string, includeContent bool) Record {
+	r := Record{
+		Time:           s.Time,
+		Owner:          owner,
+		SequenceNumber: s.SequenceNumber,
+		Formats:        make([]FormatRecord, 0, len(s.Formats)),
+	}
+	for _, f := range s.Formats {
+		sum := sha256.Sum256(f.Data)
+		fr := FormatRecord{Name: f.Name, Size: len(f.Data), SHA256: hex.EncodeToString(sum[:])}
+		if includeContent {
+			fr.Content = f.Data
+		}
+		r.Formats = append(r.Formats, fr)
+	}
+	for _, f := range s.Omitted {
+		r.Omitted = append(r.Omitted, f.Name)
+	}
+	return r
+}
+
+// Options configure a Log
+type Options struct {
+	// MaxSize is the size in bytes after which the log file is rotated, DefaultMaxSize if zero.
+	// A negative value disables rotation.
+	MaxSize int64
+	// MaxBackups limits the number of rotated files, zero keeps all of them
+	MaxBackups int
+	// IncludeContent stores the data of every format, base64 encoded
+	IncludeContent bool
+	// Key makes the record hashes HMAC-SHA256 with this key, it has to be kept outside the log
+	Key []byte
+}
+
+// Log appends records to a JSON lines file, safe for concurrent use.
+//
+// Rotated files are renamed to path.1, path.2, ..., path.1 being the most recent one.
+// The chain continues across rotations.
+type Log struct {
+	path string
+	opts Options
+
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	last    Record
+	anchors Anchors
+}
+
+// Open opens or creates the log at path and continues the chain of its last record
+func Open(path string, opts Options) (*Log, error) {
+	l := &Log{path: path, opts: opts}
+	if opts.MaxSize == 0 {
+		l.opts.MaxSize = DefaultMaxSize
+	}
+	anchors, err := ReadAnchors(path, opts.Key)
+	if err != nil {
+		return nil, err
+	}
+	l.anchors = anchors
+	// the current file might have just been rotated, the chain then continues from the previous one
+	for _, p := range []string{backupName(path, 1), path} {
+		last, err := lastRecord(p, opts.Key)
+		if err != nil {
+			return nil, err
+		}
+		if last != nil {
+			l.last = *last
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.f, l.size = f, st.Size()
+	return l, nil
+}
+
+// lastRecord returns the last record of the file at path, nil if it does not exist or is empty
+func lastRecord(path string, key []byte) (*Record, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var last []byte
+	err = readLines(f, func(line []byte) error {
+		last = append(last[:0], line...)
+		return nil
+	})
+	if err != nil || last == nil {
+		return nil, err
+	}
+	r, err := parseRecord(last, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Record appends a record describing s
+func (l *Log) Record(s *clipboard.Snapshot, owner string) error {
+	return l.Append(NewRecord(s, owner, l.opts.IncludeContent))
+}
+
+// Append links r to the previous record and writes it. Seq, Prev and Hash of r are overwritten.
+func (l *Log) Append(r Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return os.ErrClosed
+	}
+
+	r.Seq = l.last.Seq + 1
+	r.Prev = l.last.Hash
+	r.Time = r.Time.UTC()
+	line, err := encodeRecord(&r, l.opts.Key)
+	if err != nil {
+		return err
+	}
+
+	if l.opts.MaxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.opts.MaxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+	l.last = r
+	return nil
+}
+
+// Head returns the last record written, to be stored outside the log and passed to VerifyLog later
+func (l *Log) Head() Anchor {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Anchor{Seq: l.last.Seq, Hash: l.last.Hash}
+}
+
+// rotate renames the current file to path.1, l.mu has to be held
+func (l *Log) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	l.f = nil
+	anchors := l.anchors
+	anchors.Rotated = &Anchor{Seq: l.last.Seq, Hash: l.last.Hash}
+
+	n := 1
+	for ; ; n++ {
+		if _, err := os.Stat(backupName(l.path, n)); err != nil {
+			break
+		}
+	}
+	for ; n > 1; n-- {
+		if l.opts.MaxBackups > 0 && n > l.opts.MaxBackups {
+			// the oldest remaining file continues the chain of the removed one
+			last, err := lastRecord(backupName(l.path, n-1), l.opts.Key)
+			if err != nil {
+				return err
+			}
+			if last != nil {
+				anchors.Pruned = &Anchor{Seq: last.Seq, Hash: last.Hash}
+			}
+			// anchor before removing, a failure must not leave a gap that looks like tampering
+			if err := writeAnchors(l.path, anchors, l.opts.Key); err != nil {
+				return err
+			}
+			if err := os.Remove(backupName(l.path, n-1)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.Rename(backupName(l.path, n-1), backupName(l.path, n)); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(l.path, backupName(l.path, 1)); err != nil {
+		return err
+	}
+	if err := writeAnchors(l.path, anchors, l.opts.Key); err != nil {
+		return err
+	}
+	l.anchors = anchors
+
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	l.f, l.size = f, 0
+	return nil
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return os.ErrClosed
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+func backupName(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// Files returns the existing files of the log at path, oldest first
+func Files(path string) ([]string, error) {
+	var files []string
+	for n := 1; ; n++ {
+		p := backupName(path, n)
+		if _, err := os.Stat(p); errors.Is(err, os.ErrNotExist) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		files = append([]string{p}, files...)
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return files, nil
+}
+
+const hashField = `,"hash":"`
+
+// hashBody returns the hex encoded SHA-256 of body, HMAC-SHA256 if key is set
+func hashBody(body, key []byte) string {
+	if len(key) == 0 {
+		sum := sha256.Sum256(body)
+		return hex.EncodeToString(sum[:])
+	}
+	m := hmac.New(sha256.New, key)
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// encodeRecord returns the line of r and sets r.Hash
+func encodeRecord(r *Record, key []byte) ([]byte, error) {
+	r.Hash = ""
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	r.Hash = hashBody(body, key)
+
+	line := make([]byte, 0, len(body)+len(hashField)+len(r.Hash)+3)
+	line = append(line, body[:len(body)-1]...)
+	line = append(line, hashField...)
+	line = append(line, r.Hash...)
+	line = append(line, "\"}\n"...)
+	return line, nil
+}
+
+// parseRecord decodes a line and checks that it matches its hash
+func parseRecord(line, key []byte) (*Record, error) {
+	const hashLen = 2 * sha256.Size
+	end := len(line) - len(hashField) - hashLen - 2
+	if end < 1 || !bytes.Equal(line[end:end+len(hashField)], []byte(hashField)) || !bytes.HasSuffix(line, []byte("\"}")) {
+		return nil, fmt.Errorf("missing hash. %w", ErrInvalidRecord)
+	}
+	var r Record
+	if err := json.Unmarshal(line, &r); err != nil {
+		return nil, fmt.Errorf("%v. %w", err, ErrInvalidRecord)
+	}
+
+	body := make([]byte, 0, end+1)
+	body = append(append(body, line[:end]...), '}')
+	if !hmac.Equal([]byte(hashBody(body, key)), []byte(r.Hash)) {
+		return nil, fmt.Errorf("record %d does not match its hash. %w", r.Seq, ErrBrokenChain)
+	}
+	return &r, nil
+}
+
+// readLines calls fn for every line of r without its line ending, lines may be of any length
+func readLines(r io.Reader, fn func(line []byte) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if ferr := fn(bytes.TrimRight(line, "\r\n")); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
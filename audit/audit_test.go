@@ -0,0 +1,250 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeLog appends n records to the log at path
+func writeLog(t *testing.T, path string, opts Options, n int) *Log {
+	t.Helper()
+	l, err := Open(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		r := Record{
+			Time:           time.Date(2021, 1, 1, 0, 0, i, 0, time.UTC),
+			SequenceNumber: uint32(i + 1),
+			Formats:        []FormatRecord{{Name: "CF_UNICODETEXT", Size: 4, SHA256: "00"}},
+		}
+		if err := l.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+func fileLines(t *testing.T, path string) [][]byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.SplitAfter(bytes.TrimSuffix(b, []byte("\n")), []byte("\n"))
+}
+
+func writeFileLines(t *testing.T, path string, lines [][]byte) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, bytes.Join(lines, nil), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := writeLog(t, path, Options{}, 5)
+	defer l.Close()
+
+	n, err := VerifyLog(path, VerifyOptions{})
+	if err != nil || n != 5 {
+		t.Fatalf("VerifyLog = %d, %v", n, err)
+	}
+	head := l.Head()
+	if head.Seq != 5 {
+		t.Errorf("Head().Seq = %d, want 5", head.Seq)
+	}
+	if _, err := VerifyLog(path, VerifyOptions{Head: &head}); err != nil {
+		t.Errorf("VerifyLog with head: %v", err)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeLog(t, path, Options{}, 2).Close()
+	l := writeLog(t, path, Options{}, 2)
+	defer l.Close()
+	if n, err := VerifyLog(path, VerifyOptions{}); err != nil || n != 4 {
+		t.Errorf("VerifyLog = %d, %v, want 4 records", n, err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	// every record exceeds MaxSize, so every further record rotates
+	l := writeLog(t, path, Options{MaxSize: 1}, 4)
+	defer l.Close()
+
+	files, err := Files(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{path + ".3", path + ".2", path + ".1", path}
+	if len(files) != len(want) {
+		t.Fatalf("files %v, want %v", files, want)
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Errorf("files %v, want %v", files, want)
+			break
+		}
+	}
+	if n, err := VerifyLog(path, VerifyOptions{}); err != nil || n != 4 {
+		t.Errorf("VerifyLog = %d, %v", n, err)
+	}
+	a, err := ReadAnchors(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Rotated == nil || a.Rotated.Seq != 3 || a.Pruned != nil {
+		t.Errorf("anchors %+v, want rotated at record 3 and nothing pruned", a)
+	}
+}
+
+func TestMaxBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := writeLog(t, path, Options{MaxSize: 1, MaxBackups: 2}, 6)
+	defer l.Close()
+
+	files, err := Files(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("files %v, want the log and 2 backups", files)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 was not pruned: %v", path, err)
+	}
+	a, err := ReadAnchors(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Pruned == nil || a.Pruned.Seq != 3 {
+		t.Errorf("anchors %+v, want records up to 3 pruned", a)
+	}
+	if n, err := VerifyLog(path, VerifyOptions{}); err != nil || n != 3 {
+		t.Errorf("VerifyLog = %d, %v, want 3 records", n, err)
+	}
+	// without the anchors the pruned start is indistinguishable from removed files
+	if _, err := VerifyFiles(files...); !errors.Is(err, ErrBrokenChain) {
+		t.Errorf("VerifyFiles: got %v, want ErrBrokenChain", err)
+	}
+}
+
+func TestBrokenChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		tamper func(t *testing.T, path string)
+	}{
+		{"edited record", Options{}, func(t *testing.T, path string) {
+			lines := fileLines(t, path)
+			lines[1] = bytes.Replace(lines[1], []byte("CF_UNICODETEXT"), []byte("CF_TEXT"), 1)
+			writeFileLines(t, path, lines)
+		}},
+		{"removed record", Options{}, func(t *testing.T, path string) {
+			lines := fileLines(t, path)
+			writeFileLines(t, path, append(lines[:1], lines[2:]...))
+		}},
+		{"reordered records", Options{}, func(t *testing.T, path string) {
+			lines := fileLines(t, path)
+			lines[1], lines[2] = lines[2], lines[1]
+			writeFileLines(t, path, lines)
+		}},
+		{"removed oldest file", Options{MaxSize: 1}, func(t *testing.T, path string) {
+			os.Remove(path + ".3")
+		}},
+		{"removed newest files", Options{MaxSize: 1}, func(t *testing.T, path string) {
+			os.Remove(path)
+			os.Remove(path + ".1")
+		}},
+		{"truncated after rotation", Options{MaxSize: 1}, func(t *testing.T, path string) {
+			os.Remove(path)
+			writeFileLines(t, path+".1", nil)
+		}},
+		{"removed anchor of pruned files", Options{MaxSize: 1, MaxBackups: 1}, func(t *testing.T, path string) {
+			os.Remove(path + ".anchor")
+		}},
+		{"chain rewritten with the plain hash", Options{Key: []byte("key")}, func(t *testing.T, path string) {
+			lines := fileLines(t, path)
+			var out [][]byte
+			prev := ""
+			for _, b := range lines {
+				r, err := parseRecord(bytes.TrimSuffix(b, []byte("\n")), []byte("key"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				r.Prev = prev
+				line, err := encodeRecord(r, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				prev = r.Hash
+				out = append(out, line)
+			}
+			writeFileLines(t, path, out)
+		}},
+		{"forged anchors", Options{Key: []byte("key"), MaxSize: 1, MaxBackups: 1}, func(t *testing.T, path string) {
+			a, err := ReadAnchors(path, []byte("key"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.Pruned.Seq--
+			if err := writeAnchors(path, a, []byte("other")); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			writeLog(t, path, tt.opts, 4).Close()
+			if _, err := VerifyLog(path, VerifyOptions{Key: tt.opts.Key}); err != nil {
+				t.Fatalf("before tampering: %v", err)
+			}
+			tt.tamper(t, path)
+			if _, err := VerifyLog(path, VerifyOptions{Key: tt.opts.Key}); !errors.Is(err, ErrBrokenChain) {
+				t.Errorf("got %v, want ErrBrokenChain", err)
+			}
+		})
+	}
+}
+
+func TestTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := writeLog(t, path, Options{}, 3)
+	head := l.Head()
+	l.Close()
+
+	lines := fileLines(t, path)
+	writeFileLines(t, path, lines[:2])
+	if _, err := VerifyLog(path, VerifyOptions{}); err != nil {
+		t.Errorf("without a head: %v", err)
+	}
+	if _, err := VerifyLog(path, VerifyOptions{Head: &head}); !errors.Is(err, ErrBrokenChain) {
+		t.Errorf("with the head: got %v, want ErrBrokenChain", err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeLog(t, path, Options{Key: []byte("key"), MaxSize: 1}, 3).Close()
+
+	if _, err := VerifyLog(path, VerifyOptions{Key: []byte("key")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range [][]byte{nil, []byte("other")} {
+		if _, err := VerifyLog(path, VerifyOptions{Key: key}); !errors.Is(err, ErrBrokenChain) {
+			t.Errorf("key %q: got %v, want ErrBrokenChain", key, err)
+		}
+	}
+	if _, err := Open(path, Options{Key: []byte("other")}); !errors.Is(err, ErrBrokenChain) {
+		t.Errorf("Open with another key: got %v, want ErrBrokenChain", err)
+	}
+}
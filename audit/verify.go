@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Verifier checks records in order, possibly spread over several files
+type Verifier struct {
+	// Key is the key the log was written with, nil for plain SHA-256 hashes
+	Key []byte
+	// Start is the record preceding the first one checked, e.g. Anchors.Pruned.
+	// If nil the first record has to start the chain.
+	Start *Anchor
+	// Require lists records the chain has to contain, checked by Finish
+	Require []Anchor
+
+	// Records is the number of records checked so far
+	Records int
+	// Last is the most recent valid record
+	Last *Record
+	// First is the sequence number of the first record checked
+	First uint64
+}
+
+// Verify checks that every line of r is a record matching its hash and following the previous one
+func (v *Verifier) Verify(r io.Reader) error {
+	line := 0
+	return readLines(r, func(b []byte) error {
+		line++
+		if len(b) == 0 {
+			return fmt.Errorf("line %d: empty line. %w", line, ErrInvalidRecord)
+		}
+		rec, err := parseRecord(b, v.Key)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if v.Last != nil {
+			if rec.Prev != v.Last.Hash {
+				return fmt.Errorf("line %d: record %d does not link to record %d. %w", line, rec.Seq, v.Last.Seq, ErrBrokenChain)
+			}
+			if rec.Seq != v.Last.Seq+1 {
+				return fmt.Errorf("line %d: record %d follows record %d. %w", line, rec.Seq, v.Last.Seq, ErrBrokenChain)
+			}
+		} else if !(rec.Seq == 1 && rec.Prev == "") && !(v.Start != nil && rec.Seq == v.Start.Seq+1 && rec.Prev == v.Start.Hash) {
+			// older records were removed without being anchored
+			return fmt.Errorf("line %d: record %d has no predecessor. %w", line, rec.Seq, ErrBrokenChain)
+		} else {
+			v.First = rec.Seq
+		}
+		anchors := v.Require
+		if v.Start != nil {
+			anchors = append([]Anchor{*v.Start}, anchors...)
+		}
+		for _, a := range anchors {
+			if rec.Seq == a.Seq && rec.Hash != a.Hash {
+				return fmt.Errorf("line %d: record %d does not match its anchor. %w", line, rec.Seq, ErrBrokenChain)
+			}
+		}
+		v.Last = rec
+		v.Records++
+		return nil
+	})
+}
+
+// Finish checks that all records of Require were seen, call it after the last Verify
+func (v *Verifier) Finish() error {
+	var last uint64
+	if v.Last != nil {
+		last = v.Last.Seq
+	}
+	for _, a := range v.Require {
+		if a.Seq == 0 {
+			continue
+		}
+		if a.Seq > last {
+			return fmt.Errorf("record %d is missing, the log ends at record %d. %w", a.Seq, last, ErrBrokenChain)
+		}
+		if a.Seq < v.First {
+			return fmt.Errorf("record %d is missing, the log starts at record %d. %w", a.Seq, v.First, ErrBrokenChain)
+		}
+	}
+	return nil
+}
+
+// VerifyOptions configure VerifyLog
+type VerifyOptions struct {
+	// Key is the Options.Key of the log
+	Key []byte
+	// Head is a record the log has to contain, typically a Log.Head stored elsewhere
+	Head *Anchor
+}
+
+// VerifyLog checks the chain across all files of the log at path against its anchors.
+// It returns the number of valid records.
+func VerifyLog(path string, opts VerifyOptions) (int, error) {
+	files, err := Files(path)
+	if err != nil {
+		return 0, err
+	}
+	anchors, err := ReadAnchors(path, opts.Key)
+	if err != nil {
+		return 0, err
+	}
+	// a rotated log without files was removed, Finish reports the missing records
+	if len(files) == 0 && anchors.Rotated == nil {
+		return 0, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+	}
+	v := Verifier{Key: opts.Key, Start: anchors.Pruned}
+	if anchors.Rotated != nil {
+		v.Require = append(v.Require, *anchors.Rotated)
+	}
+	if opts.Head != nil {
+		v.Require = append(v.Require, *opts.Head)
+	}
+	return verifyFiles(&v, files)
+}
+
+// VerifyFiles checks the chain across the files given oldest first, see Files.
+// The first record has to start the chain, VerifyLog accepts logs pruned by MaxBackups.
+// It returns the number of valid records.
+func VerifyFiles(paths ...string) (int, error) {
+	return verifyFiles(&Verifier{}, paths)
+}
+
+func verifyFiles(v *Verifier, paths []string) (int, error) {
+	for _, p := range paths {
+		if err := verifyFile(v, p); err != nil {
+			return v.Records, err
+		}
+	}
+	return v.Records, v.Finish()
+}
+
+func verifyFile(v *Verifier, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := v.Verify(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
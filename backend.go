@@ -0,0 +1,216 @@
+package clipboard
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Item is the data of a single clipboard format
+type Item struct {
+	Format Format
+	Data   []byte
+}
+
+// Backend provides access to a clipboard.
+//
+// SystemBackend returns the Windows clipboard,
+// MemoryBackend behaves the same way without touching it.
+type Backend interface {
+	// Formats returns all formats currently available, in the order they were set
+	Formats() ([]Format, error)
+	// Data returns a copy of the data stored in the slot id
+	Data(id Format) ([]byte, error)
+	// Write replaces the clipboard contents with items as a single change
+	Write(items []Item) error
+	// FormatName returns a readable name for the passed id
+	FormatName(id Format) (string, error)
+	// RegisterFormat returns the id of the format name, registering it if necessary.
+	// Names of pre-defined formats (e.g. "CF_UNICODETEXT") return their fixed id.
+	RegisterFormat(name string) (Format, error)
+	// SequenceNumber changes whenever the clipboard contents change
+	SequenceNumber() (uint32, error)
+}
+
+// firstRegisteredFormat is the id RegisterClipboardFormat starts with
+const firstRegisteredFormat = 0xC000
+
+// synthesizedFormats lists the formats Windows derives from others when they are missing,
+// with their possible sources in order of preference.
+// CF_BITMAP is synthesized as well, but a GDI handle can not be emulated.
+var synthesizedFormats = []struct {
+	format  Format
+	sources []Format
+}{
+	{CF_TEXT, []Format{CF_UNICODETEXT, CF_OEMTEXT}},
+	{CF_OEMTEXT, []Format{CF_UNICODETEXT, CF_TEXT}},
+	{CF_UNICODETEXT, []Format{CF_TEXT, CF_OEMTEXT}},
+	{CF_DIB, []Format{CF_DIBV5}},
+	{CF_DIBV5, []Format{CF_DIB}},
+}
+
+// MemoryBackend is an in-memory Backend, safe for concurrent use.
+//
+// Like the Windows clipboard, it synthesizes the text and bitmap formats listed in synthesizedFormats.
+type MemoryBackend struct {
+	mu     sync.Mutex
+	items  []Item
+	seq    uint32
+	names  map[Format]string
+	ids    map[string]Format
+	nextID Format
+}
+
+// NewMemoryBackend returns an empty MemoryBackend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		names:  map[Format]string{},
+		ids:    map[string]Format{},
+		nextID: firstRegisteredFormat,
+	}
+}
+
+func (b *MemoryBackend) Formats() ([]Format, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result := make([]Format, 0, len(b.items))
+	for _, v := range b.items {
+		result = append(result, v.Format)
+	}
+	// synthesized formats are enumerated after the ones actually set
+	for _, s := range synthesizedFormats {
+		if _, ok := b.synthesisSource(s.format); ok {
+			result = append(result, s.format)
+		}
+	}
+	return result, nil
+}
+
+// item returns the item of the format id, b.mu has to be held
+func (b *MemoryBackend) item(id Format) (Item, bool) {
+	for _, v := range b.items {
+		if v.Format == id {
+			return v, true
+		}
+	}
+	return Item{}, false
+}
+
+// synthesisSource returns the item id can be synthesized from, if it was not set itself.
+// b.mu has to be held.
+func (b *MemoryBackend) synthesisSource(id Format) (Item, bool) {
+	if _, ok := b.item(id); ok {
+		return Item{}, false
+	}
+	for _, s := range synthesizedFormats {
+		if s.format != id {
+			continue
+		}
+		for _, src := range s.sources {
+			if v, ok := b.item(src); ok {
+				return v, true
+			}
+		}
+	}
+	return Item{}, false
+}
+
+func (b *MemoryBackend) Data(id Format) ([]byte, error) {
+	return b.DataLimit(id, 0)
+}
+
+// DataLimit implements LimitedBackend, synthesized formats are converted before their size is checked
+func (b *MemoryBackend) DataLimit(id Format, limit int64) ([]byte, error) {
+	b.mu.Lock()
+	if v, ok := b.item(id); ok {
+		b.mu.Unlock()
+		if err := checkSize(int64(len(v.Data)), limit); err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), v.Data...), nil
+	}
+	src, ok := b.synthesisSource(id)
+	b.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("format %d not available. %w", id, ErrUnknownClipboardFormat)
+	}
+
+	path, err := ConversionPath([]string{predefinedFormatNames[src.Format]}, predefinedFormatNames[id])
+	if err != nil {
+		return nil, err
+	}
+	// Write replaces items, src.Data is never modified
+	data, err := runConversion(path, src.Data)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSize(int64(len(data)), limit); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (b *MemoryBackend) Write(items []Item) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.items = b.items[:0]
+	for _, v := range items {
+		b.items = append(b.items, Item{Format: v.Format, Data: append([]byte(nil), v.Data...)})
+	}
+	b.seq++
+	return nil
+}
+
+func (b *MemoryBackend) FormatName(id Format) (string, error) {
+	if id.IsRegistered() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if name, ok := b.names[id]; ok {
+			return name, nil
+		}
+		return "", fmt.Errorf("unregistered format %d. %w", id, ErrUnknownClipboardFormat)
+	}
+	return predefinedFormatName(id)
+}
+
+func (b *MemoryBackend) RegisterFormat(name string) (Format, error) {
+	if id, ok := predefinedFormatID(name); ok {
+		return id, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if id, ok := b.ids[name]; ok {
+		return id, nil
+	}
+	if b.nextID > 0xFFFF {
+		return 0, fmt.Errorf("no format ids left for %q", name)
+	}
+	id := b.nextID
+	b.nextID++
+	b.ids[name] = id
+	b.names[id] = name
+	return id, nil
+}
+
+// SeedFormat registers name with a specific id, as if it was registered on a real clipboard
+func (b *MemoryBackend) SeedFormat(id Format, name string) error {
+	if !id.IsRegistered() {
+		return fmt.Errorf("%q: %d is not a registered format id", name, id)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if v, ok := b.names[id]; ok && v != name {
+		return fmt.Errorf("%q: id %d is already used by %q", name, id, v)
+	}
+	b.ids[name] = id
+	b.names[id] = name
+	if id >= b.nextID {
+		b.nextID = id + 1
+	}
+	return nil
+}
+
+func (b *MemoryBackend) SequenceNumber() (uint32, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq, nil
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package clipboard
+
+// defaultBackend is nil, letting DefaultRegistry allocate ids in memory
+func defaultBackend() Backend {
+	return nil
+}
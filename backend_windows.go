@@ -0,0 +1,122 @@
+package clipboard
+
+import (
+	"image"
+	"sync"
+	"syscall"
+
+	"github.com/kirides/go-winclipboard/hwnd"
+	"github.com/kirides/go-winclipboard/internal/winsys"
+)
+
+// SystemBackend returns a Backend for the Windows clipboard.
+// Its writes are owned by a message-only window created on first use.
+func SystemBackend() Backend {
+	return systemBackend{}
+}
+
+func defaultBackend() Backend {
+	return systemBackend{}
+}
+
+type systemBackend struct{}
+
+func (systemBackend) Formats() ([]Format, error) {
+	return AvailableFormats()
+}
+
+func (systemBackend) Data(id Format) ([]byte, error) {
+	return GetFormatData(id)
+}
+
+func (systemBackend) DataLimit(id Format, limit int64) ([]byte, error) {
+	return GetFormatDataLimit(id, limit)
+}
+
+var (
+	ownerOnce sync.Once
+	owner     *hwnd.Window
+	ownerErr  error
+)
+
+// clipboardOwner returns the message-only window the system backend writes with.
+// EmptyClipboard makes the window passed to OpenClipboard the owner, SetClipboardData fails if that is NULL.
+func clipboardOwner() (*hwnd.Window, error) {
+	ownerOnce.Do(func() {
+		owner, ownerErr = hwnd.New(nil)
+	})
+	return owner, ownerErr
+}
+
+// Write replaces the clipboard contents, owned by a message-only window of the process.
+// The clipboard is opened on the thread of that window, the calling thread does not matter.
+func (systemBackend) Write(items []Item) error {
+	w, err := clipboardOwner()
+	if err != nil {
+		return err
+	}
+	var werr error
+	if err := w.Invoke(func() { werr = writeItems(w.Handle, items) }); err != nil {
+		return err
+	}
+	return werr
+}
+
+func writeItems(owner syscall.Handle, items []Item) error {
+	tx, err := Begin(owner)
+	if err != nil {
+		return err
+	}
+	defer tx.Abort()
+	for _, v := range items {
+		if err := tx.Set(v.Format, v.Data); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (systemBackend) FormatName(id Format) (string, error) {
+	return systemFormatName(id)
+}
+
+func (systemBackend) RegisterFormat(name string) (Format, error) {
+	if id, ok := predefinedFormatID(name); ok {
+		return id, nil
+	}
+	id, err := winsys.RegisterClipboardFormat(name)
+	if err != nil {
+		return 0, err
+	}
+	return Format(id), nil
+}
+
+func (systemBackend) SequenceNumber() (uint32, error) {
+	return winsys.GetClipboardSequenceNumber(), nil
+}
+
+// GetAs returns the data of the format target, converting it from the cheapest available format.
+// target might also be a MIME type.
+func GetAs(target string) ([]byte, error) {
+	return GetAsFrom(systemBackend{}, target)
+}
+
+// GetImage returns the image stored in the clipboard, preferring "PNG", "GIF" and "JFIF" over CF_DIBV5 and CF_DIB
+func GetImage() (image.Image, error) {
+	return GetImageFrom(systemBackend{})
+}
+
+// SetImage places img in the clipboard as PNG, CF_DIBV5 and CF_DIB
+func SetImage(img image.Image) error {
+	return SetImageTo(systemBackend{}, img)
+}
+
+// GetObjectDescriptor decodes the "Object Descriptor" of the clipboard, falling back to the "Link Source Descriptor"
+func GetObjectDescriptor() (*ObjectDescriptor, error) {
+	return GetObjectDescriptorFrom(systemBackend{})
+}
+
+// GetLinkSource decodes the "Link Source" of the clipboard
+func GetLinkSource() (*LinkSource, error) {
+	return GetLinkSourceFrom(systemBackend{})
+}
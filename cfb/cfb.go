@@ -0,0 +1,125 @@
+// Package cfb reads and writes Compound File Binary files (MS-CFB),
+// the structured storage format of OLE objects, Outlook .msg and legacy Office documents.
+//
+// A compound file is a tree of storages (directories) and streams (files).
+package cfb
+
+import (
+	"errors"
+	"time"
+	"unicode"
+	"unicode/utf16"
+)
+
+var ErrInvalidName = errors.New("invalid entry name")
+
+// Storage is a directory of a compound file, the root storage represents the file itself
+type Storage struct {
+	Name string
+	// CLSID identifies the application the storage belongs to
+	CLSID     [16]byte
+	StateBits uint32
+	Created   time.Time
+	Modified  time.Time
+
+	Storages []*Storage
+	Streams  []*Stream
+}
+
+// Stream is a file inside a compound file
+type Stream struct {
+	Name string
+	Data []byte
+}
+
+// Storage returns the direct child storage called name, compared case-insensitively
+func (s *Storage) Storage(name string) *Storage {
+	for _, v := range s.Storages {
+		if compareNames(v.Name, name) == 0 {
+			return v
+		}
+	}
+	return nil
+}
+
+// Stream returns the direct child stream called name, compared case-insensitively
+func (s *Storage) Stream(name string) *Stream {
+	for _, v := range s.Streams {
+		if compareNames(v.Name, name) == 0 {
+			return v
+		}
+	}
+	return nil
+}
+
+const (
+	headerSize         = 512
+	sectorSize         = 512
+	miniSectorSize     = 64
+	miniStreamCutoff   = 4096
+	dirEntrySize       = 128
+	maxNameLen         = 31
+	headerDIFATEntries = 109
+
+	// special sector numbers
+	maxRegSect = 0xFFFFFFFA
+	difSect    = 0xFFFFFFFC
+	fatSect    = 0xFFFFFFFD
+	endOfChain = 0xFFFFFFFE
+	freeSect   = 0xFFFFFFFF
+	noStream   = 0xFFFFFFFF
+
+	// directory entry types
+	typeUnknown = 0
+	typeStorage = 1
+	typeStream  = 2
+	typeRoot    = 5
+
+	colorRed   = 0
+	colorBlack = 1
+)
+
+var signature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// compareNames orders directory entries: shorter names first, then by upper-cased UTF-16 code units
+func compareNames(a, b string) int {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	if len(ua) != len(ub) {
+		return len(ua) - len(ub)
+	}
+	for i := range ua {
+		ca, cb := upper(ua[i]), upper(ub[i])
+		if ca != cb {
+			return int(ca) - int(cb)
+		}
+	}
+	return 0
+}
+
+func upper(c uint16) uint16 {
+	if c >= 0xD800 && c < 0xE000 {
+		return c
+	}
+	u := unicode.ToUpper(rune(c))
+	if u > 0xFFFF {
+		return c
+	}
+	return uint16(u)
+}
+
+// filetime converts t into 100ns intervals since 1601-01-01 UTC, zero stays zero
+func filetime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	const unixOffset = 11644473600
+	return uint64(t.Unix()+unixOffset)*1e7 + uint64(t.Nanosecond()/100)
+}
+
+func fromFiletime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+	const unixOffset = 11644473600
+	return time.Unix(int64(ft/1e7)-unixOffset, int64(ft%1e7)*100)
+}
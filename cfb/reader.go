@@ -0,0 +1,437 @@
+package cfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf16"
+)
+
+var ErrFormat = errors.New("invalid compound file")
+
+// Entry describes a storage or stream of a compound file
+type Entry struct {
+	Name string
+	// Path lists the names of the storages containing the entry, starting below the root
+	Path      []string
+	IsStorage bool
+	Size      int64
+	CLSID     [16]byte
+	StateBits uint32
+	Created   time.Time
+	Modified  time.Time
+
+	id uint32
+}
+
+// File is an opened compound file
+type File struct {
+	r           io.ReaderAt
+	sectorShift uint
+	numSectors  uint32
+	fat         []uint32
+	miniFAT     []uint32
+	miniStream  []byte
+	dir         []dirEntry
+	root        Entry
+	entries     []Entry
+}
+
+// Open parses the header, allocation tables and directory of the compound file in r.
+// Stream contents are read on demand.
+func Open(r io.ReaderAt, size int64) (*File, error) {
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("cfb: reading header: %v. %w", err, ErrFormat)
+	}
+	if !bytes.Equal(header[:8], signature) {
+		return nil, fmt.Errorf("cfb: missing signature. %w", ErrFormat)
+	}
+	if binary.LittleEndian.Uint16(header[0x1C:]) != 0xFFFE {
+		return nil, fmt.Errorf("cfb: invalid byte order. %w", ErrFormat)
+	}
+	f := &File{r: r}
+	switch major := binary.LittleEndian.Uint16(header[0x1A:]); major {
+	case 3:
+		f.sectorShift = 9
+	case 4:
+		f.sectorShift = 12
+	default:
+		return nil, fmt.Errorf("cfb: unsupported version %d. %w", major, ErrFormat)
+	}
+	if shift := binary.LittleEndian.Uint16(header[0x1E:]); uint(shift) != f.sectorShift {
+		return nil, fmt.Errorf("cfb: sector shift %d does not match the version. %w", shift, ErrFormat)
+	}
+	if binary.LittleEndian.Uint16(header[0x20:]) != 6 {
+		return nil, fmt.Errorf("cfb: unsupported mini sector size. %w", ErrFormat)
+	}
+	ss := int64(1) << f.sectorShift
+	if size < ss {
+		return nil, fmt.Errorf("cfb: %d bytes are too short. %w", size, ErrFormat)
+	}
+	// the header occupies the first sector, a truncated last sector is tolerated
+	if n := (size - ss + ss - 1) / ss; n < maxRegSect {
+		f.numSectors = uint32(n)
+	} else {
+		f.numSectors = maxRegSect
+	}
+
+	numFAT := binary.LittleEndian.Uint32(header[0x2C:])
+	firstDir := binary.LittleEndian.Uint32(header[0x30:])
+	firstMiniFAT := binary.LittleEndian.Uint32(header[0x3C:])
+	firstDIFAT := binary.LittleEndian.Uint32(header[0x44:])
+	numDIFAT := binary.LittleEndian.Uint32(header[0x48:])
+	if numFAT > f.numSectors || numDIFAT > f.numSectors {
+		return nil, fmt.Errorf("cfb: %d FAT sectors exceed the file. %w", numFAT, ErrFormat)
+	}
+
+	// locate the FAT sectors through the header and the DIFAT chain
+	fatSectors := make([]uint32, 0, numFAT)
+	for i := 0; i < headerDIFATEntries && uint32(len(fatSectors)) < numFAT; i++ {
+		fatSectors = append(fatSectors, binary.LittleEndian.Uint32(header[0x4C+i*4:]))
+	}
+	next := firstDIFAT
+	for i := uint32(0); uint32(len(fatSectors)) < numFAT; i++ {
+		if i >= numDIFAT || next >= f.numSectors {
+			return nil, fmt.Errorf("cfb: DIFAT ends before %d FAT sectors. %w", numFAT, ErrFormat)
+		}
+		sector, err := f.readSector(next)
+		if err != nil {
+			return nil, err
+		}
+		n := len(sector)/4 - 1
+		for j := 0; j < n && uint32(len(fatSectors)) < numFAT; j++ {
+			fatSectors = append(fatSectors, binary.LittleEndian.Uint32(sector[j*4:]))
+		}
+		next = binary.LittleEndian.Uint32(sector[n*4:])
+	}
+	for _, s := range fatSectors {
+		if s >= f.numSectors {
+			return nil, fmt.Errorf("cfb: FAT sector %d out of range. %w", s, ErrFormat)
+		}
+		sector, err := f.readSector(s)
+		if err != nil {
+			return nil, err
+		}
+		f.fat = append(f.fat, decodeUint32s(sector)...)
+	}
+
+	dirData, err := f.readChain(firstDir, -1)
+	if err != nil {
+		return nil, fmt.Errorf("cfb: directory: %v. %w", err, ErrFormat)
+	}
+	for i := 0; i+dirEntrySize <= len(dirData); i += dirEntrySize {
+		e := decodeDirEntry(dirData[i : i+dirEntrySize])
+		if e.typ != typeUnknown {
+			// version 3 limits streams to 4 GiB, no stream can be larger than the file
+			if f.sectorShift == 9 && e.size > 0xFFFFFFFF {
+				return nil, fmt.Errorf("cfb: entry %d: size %d exceeds version 3 limit. %w", len(f.dir), e.size, ErrFormat)
+			}
+			if e.size > uint64(size) {
+				return nil, fmt.Errorf("cfb: entry %d: size %d exceeds the file size. %w", len(f.dir), e.size, ErrFormat)
+			}
+		}
+		f.dir = append(f.dir, e)
+	}
+	if len(f.dir) == 0 || f.dir[0].typ != typeRoot {
+		return nil, fmt.Errorf("cfb: missing root entry. %w", ErrFormat)
+	}
+
+	if firstMiniFAT != endOfChain {
+		data, err := f.readChain(firstMiniFAT, -1)
+		if err != nil {
+			return nil, fmt.Errorf("cfb: mini FAT: %v. %w", err, ErrFormat)
+		}
+		f.miniFAT = decodeUint32s(data)
+	}
+	if root := f.dir[0]; root.size > 0 {
+		if f.miniStream, err = f.readChain(root.start, int64(root.size)); err != nil {
+			return nil, fmt.Errorf("cfb: mini stream: %v. %w", err, ErrFormat)
+		}
+	}
+
+	f.root = f.entry(0, nil)
+	visited := map[uint32]bool{0: true}
+	if err := f.walk(f.dir[0].child, nil, visited); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// walk appends the entries of the sibling tree below id, depth first
+func (f *File) walk(id uint32, path []string, visited map[uint32]bool) error {
+	if id == noStream {
+		return nil
+	}
+	if id >= uint32(len(f.dir)) || visited[id] {
+		return fmt.Errorf("cfb: invalid directory tree at entry %d. %w", id, ErrFormat)
+	}
+	visited[id] = true
+	e := f.dir[id]
+	if err := f.walk(e.left, path, visited); err != nil {
+		return err
+	}
+	switch e.typ {
+	case typeStorage, typeStream:
+		entry := f.entry(id, path)
+		f.entries = append(f.entries, entry)
+		if e.typ == typeStorage {
+			sub := append(append([]string(nil), path...), e.name)
+			if err := f.walk(e.child, sub, visited); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cfb: entry %d has type %d. %w", id, e.typ, ErrFormat)
+	}
+	return f.walk(e.right, path, visited)
+}
+
+func (f *File) entry(id uint32, path []string) Entry {
+	e := f.dir[id]
+	return Entry{
+		Name:      e.name,
+		Path:      path,
+		IsStorage: e.typ != typeStream,
+		Size:      int64(e.size),
+		CLSID:     e.clsid,
+		StateBits: e.stateBits,
+		Created:   fromFiletime(e.created),
+		Modified:  fromFiletime(e.modified),
+		id:        id,
+	}
+}
+
+// Root returns the root entry, holding the CLSID of the file
+func (f *File) Root() Entry {
+	return f.root
+}
+
+// Entries returns all storages and streams, depth first and sorted like they are stored
+func (f *File) Entries() []Entry {
+	return f.entries
+}
+
+// OpenStream returns a reader for the contents of the stream e
+func (f *File) OpenStream(e Entry) (io.Reader, error) {
+	if e.IsStorage || e.id >= uint32(len(f.dir)) {
+		return nil, fmt.Errorf("cfb: %s is not a stream", e.Name)
+	}
+	d := f.dir[e.id]
+	if d.size == 0 {
+		return bytes.NewReader(nil), nil
+	}
+	if d.size < miniStreamCutoff {
+		data, err := f.readMiniChain(d.start, int64(d.size))
+		if err != nil {
+			return nil, fmt.Errorf("cfb: %s: %v. %w", e.Name, err, ErrFormat)
+		}
+		return bytes.NewReader(data), nil
+	}
+	sectors, err := f.chain(d.start)
+	if err != nil {
+		return nil, fmt.Errorf("cfb: %s: %v. %w", e.Name, err, ErrFormat)
+	}
+	if int64(len(sectors))<<f.sectorShift < int64(d.size) {
+		return nil, fmt.Errorf("cfb: %s: chain shorter than %d bytes. %w", e.Name, d.size, ErrFormat)
+	}
+	return &chainReader{f: f, sectors: sectors, remaining: int64(d.size)}, nil
+}
+
+// Load reads the compound file in r including all stream contents
+func Load(r io.ReaderAt, size int64) (*Storage, error) {
+	f, err := Open(r, size)
+	if err != nil {
+		return nil, err
+	}
+	root := &Storage{
+		Name:      f.root.Name,
+		CLSID:     f.root.CLSID,
+		StateBits: f.root.StateBits,
+		Created:   f.root.Created,
+		Modified:  f.root.Modified,
+	}
+	storages := map[uint32]*Storage{0: root}
+	parents := map[uint32]uint32{}
+	for id, d := range f.dir {
+		if d.typ == typeStorage || d.typ == typeRoot {
+			markParent(f.dir, d.child, uint32(id), parents, map[uint32]bool{})
+		}
+	}
+
+	// every sector belongs to at most one stream, larger totals mean overlapping chains
+	var total int64
+	for _, e := range f.entries {
+		parent := storages[parents[e.id]]
+		if parent == nil {
+			return nil, fmt.Errorf("cfb: %s has no parent. %w", e.Name, ErrFormat)
+		}
+		if e.IsStorage {
+			s := &Storage{Name: e.Name, CLSID: e.CLSID, StateBits: e.StateBits, Created: e.Created, Modified: e.Modified}
+			storages[e.id] = s
+			parent.Storages = append(parent.Storages, s)
+			continue
+		}
+		if total += e.Size; total > size {
+			return nil, fmt.Errorf("cfb: streams exceed the file size. %w", ErrFormat)
+		}
+		r, err := f.OpenStream(e)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		parent.Streams = append(parent.Streams, &Stream{Name: e.Name, Data: data})
+	}
+	return root, nil
+}
+
+// markParent records parent for every entry of the sibling tree below id
+func markParent(dir []dirEntry, id, parent uint32, parents map[uint32]uint32, visited map[uint32]bool) {
+	if id == noStream || id >= uint32(len(dir)) || visited[id] {
+		return
+	}
+	visited[id] = true
+	parents[id] = parent
+	markParent(dir, dir[id].left, parent, parents, visited)
+	markParent(dir, dir[id].right, parent, parents, visited)
+}
+
+func (f *File) readSector(id uint32) ([]byte, error) {
+	buf := make([]byte, 1<<f.sectorShift)
+	n, err := f.r.ReadAt(buf, int64(id+1)<<f.sectorShift)
+	if n == len(buf) {
+		return buf, nil
+	}
+	// the last sector might be truncated
+	if err == io.EOF && n > 0 {
+		return buf, nil
+	}
+	return nil, fmt.Errorf("cfb: reading sector %d: %v. %w", id, err, ErrFormat)
+}
+
+// chain returns the sectors of the FAT chain starting at start
+func (f *File) chain(start uint32) ([]uint32, error) {
+	var result []uint32
+	for s := start; s != endOfChain; s = f.fat[s] {
+		if s >= f.numSectors || s >= uint32(len(f.fat)) {
+			return nil, fmt.Errorf("sector %d out of range", s)
+		}
+		if uint32(len(result)) >= f.numSectors {
+			return nil, errors.New("sector chain contains a loop")
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// readChain reads the chain starting at start, limited to size bytes unless size is negative
+func (f *File) readChain(start uint32, size int64) ([]byte, error) {
+	sectors, err := f.chain(start)
+	if err != nil {
+		return nil, err
+	}
+	if size >= 0 && int64(len(sectors))<<f.sectorShift < size {
+		return nil, fmt.Errorf("chain shorter than %d bytes", size)
+	}
+	buf := make([]byte, 0, len(sectors)<<f.sectorShift)
+	for _, s := range sectors {
+		sector, err := f.readSector(s)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, sector...)
+	}
+	if size >= 0 {
+		buf = buf[:size]
+	}
+	return buf, nil
+}
+
+func (f *File) readMiniChain(start uint32, size int64) ([]byte, error) {
+	buf := make([]byte, 0, size)
+	steps := 0
+	for s := start; int64(len(buf)) < size; s = f.miniFAT[s] {
+		if s >= uint32(len(f.miniFAT)) || int64(s+1)*miniSectorSize > int64(len(f.miniStream)) {
+			return nil, fmt.Errorf("mini sector %d out of range", s)
+		}
+		if steps++; steps > len(f.miniFAT) {
+			return nil, errors.New("mini sector chain contains a loop")
+		}
+		off := int64(s) * miniSectorSize
+		buf = append(buf, f.miniStream[off:off+miniSectorSize]...)
+	}
+	return buf[:size], nil
+}
+
+// chainReader reads a stream stored in regular sectors, one sector at a time
+type chainReader struct {
+	f         *File
+	sectors   []uint32
+	remaining int64
+	buf       []byte
+}
+
+func (r *chainReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		if r.remaining <= 0 {
+			return 0, io.EOF
+		}
+		sector, err := r.f.readSector(r.sectors[0])
+		if err != nil {
+			return 0, err
+		}
+		r.sectors = r.sectors[1:]
+		if int64(len(sector)) > r.remaining {
+			sector = sector[:r.remaining]
+		}
+		r.remaining -= int64(len(sector))
+		r.buf = sector
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func decodeDirEntry(b []byte) dirEntry {
+	nameLen := int(binary.LittleEndian.Uint16(b[0x40:]))
+	if nameLen > 64 {
+		nameLen = 64
+	}
+	name := make([]uint16, 0, nameLen/2)
+	for i := 0; i+1 < nameLen; i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		name = append(name, c)
+	}
+	e := dirEntry{
+		name:      string(utf16.Decode(name)),
+		typ:       b[0x42],
+		color:     b[0x43],
+		left:      binary.LittleEndian.Uint32(b[0x44:]),
+		right:     binary.LittleEndian.Uint32(b[0x48:]),
+		child:     binary.LittleEndian.Uint32(b[0x4C:]),
+		stateBits: binary.LittleEndian.Uint32(b[0x60:]),
+		created:   binary.LittleEndian.Uint64(b[0x64:]),
+		modified:  binary.LittleEndian.Uint64(b[0x6C:]),
+		start:     binary.LittleEndian.Uint32(b[0x74:]),
+		size:      binary.LittleEndian.Uint64(b[0x78:]),
+	}
+	copy(e.clsid[:], b[0x50:0x60])
+	return e
+}
+
+func decodeUint32s(b []byte) []uint32 {
+	result := make([]uint32, len(b)/4)
+	for i := range result {
+		result[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	return result
+}
@@ -0,0 +1,183 @@
+package cfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/writer.cfb")
+
+// testTree covers mini and regular streams, nested storages and an empty stream
+func testTree() *Storage {
+	return &Storage{
+		CLSID: [16]byte{0x0b, 0x0d, 0x02, 0x00, 0, 0, 0, 0, 0xc0, 0, 0, 0, 0, 0, 0, 0x46},
+		Streams: []*Stream{
+			{Name: "Small", Data: bytes.Repeat([]byte("small"), 20)},
+			{Name: "Large", Data: bytes.Repeat([]byte("0123456789"), 1000)},
+			{Name: "Empty"},
+		},
+		Storages: []*Storage{{
+			Name:      "Sub",
+			StateBits: 1,
+			Streams:   []*Stream{{Name: "\x01Inner", Data: []byte("inner")}},
+		}},
+	}
+}
+
+func readFile(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestWriteGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testTree()); err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := ioutil.WriteFile(filepath.Join("testdata", "writer.cfb"), buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(buf.Bytes(), readFile(t, "writer.cfb")) {
+		t.Error("output differs from testdata/writer.cfb")
+	}
+}
+
+func TestLoadWriter(t *testing.T) {
+	b := readFile(t, "writer.cfb")
+	got, err := Load(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testTree()
+	if got.CLSID != want.CLSID {
+		t.Errorf("CLSID %x, want %x", got.CLSID, want.CLSID)
+	}
+	for _, s := range want.Streams {
+		if v := got.Stream(s.Name); v == nil || !bytes.Equal(v.Data, s.Data) {
+			t.Errorf("stream %s differs", s.Name)
+		}
+	}
+	sub := got.Storage("SUB")
+	if sub == nil || sub.StateBits != 1 {
+		t.Fatalf("storage Sub: %+v", sub)
+	}
+	if v := sub.Stream("\x01Inner"); v == nil || string(v.Data) != "inner" {
+		t.Errorf("stream Sub/\\x01Inner differs")
+	}
+}
+
+// checkStreams reads every stream of f and compares its length with the entry size
+func checkStreams(t *testing.T, f *File) map[string]int64 {
+	t.Helper()
+	sizes := map[string]int64{}
+	for _, e := range f.Entries() {
+		if e.IsStorage {
+			continue
+		}
+		r, err := f.OpenStream(e)
+		if err != nil {
+			t.Fatalf("%s: %v", e.Name, err)
+		}
+		n, err := io.Copy(ioutil.Discard, r)
+		if err != nil || n != e.Size {
+			t.Errorf("%s: read %d of %d bytes, %v", e.Name, n, e.Size, err)
+		}
+		sizes[filepath.Join(append(append([]string(nil), e.Path...), e.Name)...)] = e.Size
+	}
+	return sizes
+}
+
+func TestOpenOffice(t *testing.T) {
+	b := readFile(t, "excel.xls")
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := checkStreams(t, f)
+	want := map[string]int64{
+		"\x01Ole":                        20,
+		"\x01CompObj":                    73,
+		"Workbook":                       2719,
+		"\x05SummaryInformation":         236,
+		"\x05DocumentSummaryInformation": 116,
+	}
+	for name, size := range want {
+		if sizes[name] != size {
+			t.Errorf("excel.xls: %q has %d bytes, want %d", name, sizes[name], size)
+		}
+	}
+
+	b = readFile(t, "outlook.msg")
+	f, err = Open(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(f.Entries()); n != 112 {
+		t.Errorf("outlook.msg: %d entries, want 112", n)
+	}
+	sizes = checkStreams(t, f)
+	if sizes[filepath.Join("__nameid_version1.0", "__substg1.0_00020102")] != 64 {
+		t.Error("outlook.msg: missing named property GUID stream")
+	}
+	if _, err := Load(bytes.NewReader(b), int64(len(b))); err != nil {
+		t.Errorf("Load outlook.msg: %v", err)
+	}
+}
+
+// sizeOffset returns the offset of the size of directory entry id, the directory of writer.cfb fits in one sector
+func sizeOffset(b []byte, id int) int {
+	firstDir := int(binary.LittleEndian.Uint32(b[0x30:]))
+	return headerSize + firstDir*sectorSize + id*dirEntrySize + 0x78
+}
+
+func TestOpenInvalidSize(t *testing.T) {
+	b := readFile(t, "writer.cfb")
+	large := -1
+	for id := 1; id < 6; id++ {
+		if binary.LittleEndian.Uint64(b[sizeOffset(b, id):]) == 10000 {
+			large = id
+		}
+	}
+	if large < 0 {
+		t.Fatal("stream Large not found")
+	}
+
+	tests := []struct {
+		name string
+		size uint64
+	}{
+		{"larger than the file", uint64(len(b)) + 1},
+		{"upper half set in version 3", 1<<32 | 10000},
+		{"maximum", 1<<64 - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append([]byte(nil), b...)
+			binary.LittleEndian.PutUint64(data[sizeOffset(b, large):], tt.size)
+			if _, err := Open(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrFormat) {
+				t.Errorf("got %v, want ErrFormat", err)
+			}
+		})
+	}
+}
+
+func TestOpenTruncated(t *testing.T) {
+	b := readFile(t, "writer.cfb")
+	for _, n := range []int{0, 8, headerSize, len(b) / 2} {
+		if _, err := Open(bytes.NewReader(b[:n]), int64(n)); err == nil {
+			t.Errorf("%d bytes: opened", n)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package cfb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// dirEntry is a directory entry as stored in the file
+type dirEntry struct {
+	name              string
+	typ               byte
+	color             byte
+	left, right       uint32
+	child             uint32
+	clsid             [16]byte
+	stateBits         uint32
+	created, modified uint64
+	start             uint32
+	size              uint64
+	data              []byte
+}
+
+// Write serializes root as a version 3 compound file (512 byte sectors).
+//
+// The name of root is ignored, it is always stored as "Root Entry".
+func Write(w io.Writer, root *Storage) error {
+	entries := []*dirEntry{{
+		name: "Root Entry", typ: typeRoot, color: colorBlack,
+		left: noStream, right: noStream, child: noStream,
+		clsid: root.CLSID, stateBits: root.StateBits, modified: filetime(root.Modified),
+	}}
+	if err := addChildren(&entries, 0, root); err != nil {
+		return err
+	}
+
+	// streams below the cutoff are stored in 64 byte sectors inside the mini stream
+	var miniStream []byte
+	var miniFAT []uint32
+	var regular []*dirEntry
+	for _, e := range entries {
+		if e.typ != typeStream {
+			continue
+		}
+		e.size = uint64(len(e.data))
+		switch {
+		case len(e.data) == 0:
+			e.start = endOfChain
+		case len(e.data) < miniStreamCutoff:
+			e.start = uint32(len(miniFAT))
+			n := sectorCount(len(e.data), miniSectorSize)
+			for i := 0; i < n; i++ {
+				miniFAT = append(miniFAT, uint32(len(miniFAT)+1))
+			}
+			miniFAT[len(miniFAT)-1] = endOfChain
+			miniStream = append(miniStream, e.data...)
+			miniStream = append(miniStream, make([]byte, n*miniSectorSize-len(e.data))...)
+		default:
+			regular = append(regular, e)
+		}
+	}
+
+	// sector layout: regular streams, mini stream, mini FAT, directory, FAT, DIFAT
+	var fat []uint32
+	chain := func(n int, last uint32) uint32 {
+		if n == 0 {
+			return last
+		}
+		start := uint32(len(fat))
+		for i := 0; i < n; i++ {
+			fat = append(fat, uint32(len(fat)+1))
+		}
+		fat[len(fat)-1] = endOfChain
+		return start
+	}
+	for _, e := range regular {
+		e.start = chain(sectorCount(len(e.data), sectorSize), endOfChain)
+	}
+	entries[0].start = chain(sectorCount(len(miniStream), sectorSize), endOfChain)
+	entries[0].size = uint64(len(miniStream))
+	miniFATSectors := sectorCount(len(miniFAT)*4, sectorSize)
+	firstMiniFAT := chain(miniFATSectors, endOfChain)
+	dirSectors := sectorCount(len(entries)*dirEntrySize, sectorSize)
+	firstDir := chain(dirSectors, endOfChain)
+
+	// the FAT has to describe its own sectors and the DIFAT sectors as well
+	base := len(fat)
+	fatSectors, difatSectors := 0, 0
+	for {
+		f := sectorCount(base+fatSectors+difatSectors, sectorSize/4)
+		d := 0
+		if f > headerDIFATEntries {
+			d = sectorCount(f-headerDIFATEntries, sectorSize/4-1)
+		}
+		if f == fatSectors && d == difatSectors {
+			break
+		}
+		fatSectors, difatSectors = f, d
+	}
+	if uint64(base+fatSectors+difatSectors) > maxRegSect {
+		return fmt.Errorf("cfb: %d sectors exceed the file format", base+fatSectors+difatSectors)
+	}
+	firstFAT := uint32(len(fat))
+	for i := 0; i < fatSectors; i++ {
+		fat = append(fat, fatSect)
+	}
+	firstDIFAT := uint32(endOfChain)
+	if difatSectors > 0 {
+		firstDIFAT = uint32(len(fat))
+	}
+	for i := 0; i < difatSectors; i++ {
+		fat = append(fat, difSect)
+	}
+	for len(fat)%(sectorSize/4) != 0 {
+		fat = append(fat, freeSect)
+	}
+
+	bw := bufio.NewWriter(w)
+	header := make([]byte, headerSize)
+	copy(header, signature)
+	binary.LittleEndian.PutUint16(header[0x18:], 0x003E)
+	binary.LittleEndian.PutUint16(header[0x1A:], 3)
+	binary.LittleEndian.PutUint16(header[0x1C:], 0xFFFE)
+	binary.LittleEndian.PutUint16(header[0x1E:], 9)
+	binary.LittleEndian.PutUint16(header[0x20:], 6)
+	binary.LittleEndian.PutUint32(header[0x2C:], uint32(fatSectors))
+	binary.LittleEndian.PutUint32(header[0x30:], firstDir)
+	binary.LittleEndian.PutUint32(header[0x38:], miniStreamCutoff)
+	binary.LittleEndian.PutUint32(header[0x3C:], firstMiniFAT)
+	binary.LittleEndian.PutUint32(header[0x40:], uint32(miniFATSectors))
+	binary.LittleEndian.PutUint32(header[0x44:], firstDIFAT)
+	binary.LittleEndian.PutUint32(header[0x48:], uint32(difatSectors))
+	for i := 0; i < headerDIFATEntries; i++ {
+		v := uint32(freeSect)
+		if i < fatSectors {
+			v = firstFAT + uint32(i)
+		}
+		binary.LittleEndian.PutUint32(header[0x4C+i*4:], v)
+	}
+	bw.Write(header)
+
+	for _, e := range regular {
+		writePadded(bw, e.data)
+	}
+	writePadded(bw, miniStream)
+	writePadded(bw, uint32s(miniFAT))
+	dir := make([]byte, 0, dirSectors*sectorSize)
+	for _, e := range entries {
+		dir = append(dir, e.encode()...)
+	}
+	for len(dir) < dirSectors*sectorSize {
+		dir = append(dir, (&dirEntry{left: noStream, right: noStream, child: noStream}).encode()...)
+	}
+	bw.Write(dir)
+	bw.Write(uint32s(fat))
+
+	// DIFAT sectors hold 127 FAT sector numbers and the number of the next DIFAT sector
+	for i := 0; i < difatSectors; i++ {
+		sector := make([]uint32, sectorSize/4)
+		for j := range sector {
+			sector[j] = freeSect
+		}
+		for j := 0; j < sectorSize/4-1; j++ {
+			if k := headerDIFATEntries + i*(sectorSize/4-1) + j; k < fatSectors {
+				sector[j] = firstFAT + uint32(k)
+			}
+		}
+		sector[len(sector)-1] = endOfChain
+		if i+1 < difatSectors {
+			sector[len(sector)-1] = firstDIFAT + uint32(i+1)
+		}
+		bw.Write(uint32s(sector))
+	}
+	return bw.Flush()
+}
+
+// addChildren appends the children of s as entries and links them below the entry parent
+func addChildren(entries *[]*dirEntry, parent int, s *Storage) error {
+	var children []*dirEntry
+	storages := map[*dirEntry]*Storage{}
+	for _, v := range s.Storages {
+		e := &dirEntry{
+			name: v.Name, typ: typeStorage, clsid: v.CLSID, stateBits: v.StateBits,
+			created: filetime(v.Created), modified: filetime(v.Modified),
+		}
+		children = append(children, e)
+		storages[e] = v
+	}
+	for _, v := range s.Streams {
+		children = append(children, &dirEntry{name: v.Name, typ: typeStream, data: v.Data})
+	}
+	for _, e := range children {
+		if err := checkName(e.name); err != nil {
+			return err
+		}
+		e.left, e.right, e.child = noStream, noStream, noStream
+	}
+	sort.Slice(children, func(i, j int) bool { return compareNames(children[i].name, children[j].name) < 0 })
+	for i := 1; i < len(children); i++ {
+		if compareNames(children[i-1].name, children[i].name) == 0 {
+			return fmt.Errorf("cfb: duplicate name %q. %w", children[i].name, ErrInvalidName)
+		}
+	}
+
+	first := uint32(len(*entries))
+	*entries = append(*entries, children...)
+	(*entries)[parent].child = buildTree(*entries, first, len(children))
+
+	for i, e := range children {
+		if st, ok := storages[e]; ok {
+			if err := addChildren(entries, int(first)+i, st); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// buildTree links the n sorted entries starting at first as a balanced red-black tree and returns its root.
+//
+// All nodes are black except those on the deepest level, which keeps the black height equal on every path.
+func buildTree(entries []*dirEntry, first uint32, n int) uint32 {
+	if n == 0 {
+		return noStream
+	}
+	depth := 0
+	for 1<<(depth+1)-1 < n {
+		depth++
+	}
+	var build func(lo, hi, level int) uint32
+	build = func(lo, hi, level int) uint32 {
+		if lo >= hi {
+			return noStream
+		}
+		mid := (lo + hi) / 2
+		e := entries[first+uint32(mid)]
+		e.color = colorBlack
+		if level == depth && depth > 0 {
+			e.color = colorRed
+		}
+		e.left = build(lo, mid, level+1)
+		e.right = build(mid+1, hi, level+1)
+		return first + uint32(mid)
+	}
+	return build(0, n, 0)
+}
+
+func checkName(name string) error {
+	if name == "" || len(utf16.Encode([]rune(name))) > maxNameLen || strings.ContainsAny(name, `/\:!`) {
+		return fmt.Errorf("cfb: %q. %w", name, ErrInvalidName)
+	}
+	return nil
+}
+
+func (e *dirEntry) encode() []byte {
+	buf := make([]byte, dirEntrySize)
+	if e.name != "" {
+		name := utf16.Encode([]rune(e.name))
+		for i, c := range name {
+			binary.LittleEndian.PutUint16(buf[i*2:], c)
+		}
+		binary.LittleEndian.PutUint16(buf[0x40:], uint16(len(name)+1)*2)
+	}
+	buf[0x42] = e.typ
+	buf[0x43] = e.color
+	binary.LittleEndian.PutUint32(buf[0x44:], e.left)
+	binary.LittleEndian.PutUint32(buf[0x48:], e.right)
+	binary.LittleEndian.PutUint32(buf[0x4C:], e.child)
+	copy(buf[0x50:], e.clsid[:])
+	binary.LittleEndian.PutUint32(buf[0x60:], e.stateBits)
+	binary.LittleEndian.PutUint64(buf[0x64:], e.created)
+	binary.LittleEndian.PutUint64(buf[0x6C:], e.modified)
+	binary.LittleEndian.PutUint32(buf[0x74:], e.start)
+	binary.LittleEndian.PutUint64(buf[0x78:], e.size)
+	return buf
+}
+
+func sectorCount(size, sector int) int {
+	return (size + sector - 1) / sector
+}
+
+func uint32s(v []uint32) []byte {
+	buf := make([]byte, len(v)*4)
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[i*4:], x)
+	}
+	return buf
+}
+
+// writePadded writes data followed by zeros up to the next sector boundary
+func writePadded(w io.Writer, data []byte) {
+	w.Write(data)
+	if rest := len(data) % sectorSize; rest != 0 {
+		w.Write(make([]byte, sectorSize-rest))
+	}
+}
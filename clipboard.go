@@ -2,12 +2,25 @@ package clipboard
 
 import (
 	"reflect"
+	"time"
 	"unsafe"
 )
 
 type FileInfo struct {
+	// Name is a relative path, directories are separated by backslashes
 	Name string
 	Size int64
+	// Attributes are FILE_ATTRIBUTE_ flags, zero if unknown
+	Attributes uint32
+	// CreationTime, AccessTime and ModTime are zero if unknown
+	CreationTime time.Time
+	AccessTime   time.Time
+	ModTime      time.Time
+}
+
+// IsDir reports whether f describes a directory
+func (f FileInfo) IsDir() bool {
+	return f.Attributes&_FILE_ATTRIBUTE_DIRECTORY != 0
 }
 
 func byteSliceFromUintptr(v uintptr, len int) []byte {
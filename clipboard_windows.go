@@ -2,11 +2,10 @@ package clipboard
 
 import (
 	"bytes"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
-	"reflect"
+	"runtime"
 	"syscall"
 	"time"
 	"unicode/utf16"
@@ -21,6 +20,9 @@ import (
 const (
 	_CFSTR_FILEGROUPDESCRIPTORW = "FileGroupDescriptorW"
 	_CFSTR_FILECONTENTS         = "FileContents"
+
+	// maxFileGroupDescriptorSize allows for more than 100000 files
+	maxFileGroupDescriptorSize = 64 << 20
 )
 
 func Init() error {
@@ -34,23 +36,154 @@ func RemoveClipboardFormatListener(h syscall.Handle) error {
 	return winsys.RemoveClipboardFormatListener(h)
 }
 
-func getClipboardDataHGlobalSlice(id uint32) (s []byte, err error) {
-	r1, e1 := winsys.GetClipboardData(id)
-	if e1 != nil {
-		return nil, e1
-	}
-	hHeap, _ := winsys.GetProcessHeap()
-	size, e2 := winsys.HeapSize(hHeap, 0, uintptr(r1))
+// getClipboardDataGlobal returns a copy of the HGLOBAL contents in the slot id,
+// failing with ErrTooLarge before copying anything if it is larger than limit.
+// The clipboard has to be opened by the caller.
+func getClipboardDataGlobal(id Format, limit int64) ([]byte, error) {
+	var result []byte
+	err := withClipboardDataGlobal(id, limit, func(r *globalReader) error {
+		result = make([]byte, r.Size())
+		_, err := io.ReadFull(r, result)
+		return err
+	})
+	return result, err
+}
 
+// withClipboardDataGlobal calls fn with a reader over the locked HGLOBAL in the slot id.
+// The clipboard has to be opened by the caller.
+func withClipboardDataGlobal(id Format, limit int64, fn func(r *globalReader) error) error {
+	h, err := winsys.GetClipboardData(uint32(id))
+	if err != nil {
+		return err
+	}
+	// GlobalSize is the size of the allocation, which the clipboard owner controls
+	size, _ := winsys.GlobalSize(uintptr(h))
+	if err := checkSize(int64(size), limit); err != nil {
+		return err
+	}
 	if size == 0 {
-		return nil, e2
+		return fn(&globalReader{})
+	}
+	lpMem, err := winsys.GlobalLock(uintptr(h))
+	if lpMem == 0 {
+		return err
 	}
-	s = byteSliceFromUintptr(uintptr(r1), int(size))
-	return s, nil
+	defer winsys.GlobalUnlock(uintptr(h))
+	return fn(&globalReader{mem: lpMem, size: int64(size)})
 }
 
+// globalReader reads locked HGLOBAL memory without copying it first
+type globalReader struct {
+	mem  uintptr
+	size int64
+	off  int64
+}
+
+func (r *globalReader) Read(p []byte) (int, error) {
+	if r.off >= r.size {
+		return 0, io.EOF
+	}
+	n := r.size - r.off
+	if n > int64(len(p)) {
+		n = int64(len(p))
+	}
+	copy(p, byteSliceFromUintptr(r.mem+uintptr(r.off), int(n)))
+	r.off += n
+	return int(n), nil
+}
+
+// Size returns the number of bytes of the memory object, which might be rounded up by the system
+func (r *globalReader) Size() int64 {
+	return r.size
+}
+
+// ReadFormatStream calls fn with a reader over the data in the slot f, without copying it into a slice first.
+// size is the size of the memory object, it fails with ErrTooLarge before calling fn if size exceeds limit.
+//
+// The clipboard stays open until fn returns, other applications can not access it in the meantime.
+func ReadFormatStream(f Format, limit int64, fn func(r io.Reader, size int64) error) error {
+	// the clipboard has to be closed by the thread that opened it
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := winsys.OpenClipboard(0); err != nil {
+		return err
+	}
+	defer winsys.CloseClipboard()
+
+	if err := winsys.IsClipboardFormatAvailable(uint32(f)); err != nil {
+		return err
+	}
+	return withClipboardDataGlobal(f, limit, func(r *globalReader) error {
+		return fn(r, r.Size())
+	})
+}
+
+// SetData places data in the slot id, the clipboard has to be opened by the caller.
+//
+// Deprecated: use Begin and (*Transaction).Set
 func SetData(id uint, data []byte) error {
-	return setClipboardDataSlice(uint32(id), data)
+	return setClipboardDataSlice(Format(id), data)
+}
+
+// GetData returns a copy of the raw data stored in the slot id
+//
+// Deprecated: use GetFormatData
+func GetData(id uint) ([]byte, error) {
+	return GetFormatData(Format(id))
+}
+
+// GetFormatData returns a copy of the raw data stored in the slot f,
+// failing with ErrTooLarge if it exceeds DefaultMaxFormatSize
+func GetFormatData(f Format) ([]byte, error) {
+	return GetFormatDataLimit(f, DefaultMaxFormatSize)
+}
+
+// GetFormatDataLimit is GetFormatData with another limit, zero or less for none
+func GetFormatDataLimit(f Format, limit int64) ([]byte, error) {
+	if err := winsys.OpenClipboard(0); err != nil {
+		return nil, err
+	}
+	defer winsys.CloseClipboard()
+
+	if err := winsys.IsClipboardFormatAvailable(uint32(f)); err != nil {
+		return nil, err
+	}
+	return getClipboardDataGlobal(f, limit)
+}
+
+// GetUnicodeText returns the text stored in the CF_UNICODETEXT(13) slot
+func GetUnicodeText() (string, error) {
+	data, err := GetFormatData(CF_UNICODETEXT)
+	if err != nil {
+		return "", err
+	}
+	return DecodeUnicodeText(data), nil
+}
+
+// OwnerProcess returns the executable path of the process that currently owns the clipboard.
+//
+// An empty string is returned if the clipboard has no owner.
+func OwnerProcess() (string, error) {
+	h, _ := winsys.GetClipboardOwner()
+	if h == 0 {
+		return "", nil
+	}
+	var pid uint32
+	if _, err := windows.GetWindowThreadProcessId(windows.HWND(h), &pid); err != nil {
+		return "", err
+	}
+	hProc, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(hProc)
+
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	n := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(hProc, 0, &buf[0], &n); err != nil {
+		return "", err
+	}
+	return string(utf16.Decode(buf[:n])), nil
 }
 
 func getUnicodeBytes(text string) ([]byte, error) {
@@ -73,12 +206,77 @@ func Empty() error {
 	return winsys.EmptyClipboard()
 }
 
+// Transaction replaces the clipboard contents with one or more formats at once.
+//
+// Other applications observe a single clipboard change once Commit is called.
+type Transaction struct {
+	closed bool
+}
+
+// Begin opens the clipboard for hWnd and empties it, making hWnd the clipboard owner.
+// hWnd has to be a window, SetClipboardData fails for a clipboard emptied without an owner.
+// The clipboard stays open until Commit or Abort is called, on the same thread.
+func Begin(hWnd syscall.Handle) (*Transaction, error) {
+	if err := winsys.OpenClipboard(hWnd); err != nil {
+		return nil, err
+	}
+	if err := winsys.EmptyClipboard(); err != nil {
+		winsys.CloseClipboard()
+		return nil, err
+	}
+	return &Transaction{}, nil
+}
+
+// Set places data in the slot f
+func (tx *Transaction) Set(f Format, data []byte) error {
+	return setClipboardDataSlice(f, data)
+}
+
+// SetData places data in the slot id
+//
+// Deprecated: use Set
+func (tx *Transaction) SetData(id uint, data []byte) error {
+	return tx.Set(Format(id), data)
+}
+
+// SetUnicodeText places text in the CF_UNICODETEXT(13) slot
+func (tx *Transaction) SetUnicodeText(text string) error {
+	data, err := getUnicodeBytes(text)
+	if err != nil {
+		return err
+	}
+	return setClipboardDataSlice(CF_UNICODETEXT, data)
+}
+
+// Commit closes the clipboard, publishing all formats set so far
+func (tx *Transaction) Commit() error {
+	if tx.closed {
+		return nil
+	}
+	tx.closed = true
+	return winsys.CloseClipboard()
+}
+
+// Abort empties the clipboard again and closes it, so that formats set so far are not published.
+// The previous contents can not be restored. Abort does nothing after Commit,
+// so it can be deferred right after Begin.
+func (tx *Transaction) Abort() error {
+	if tx.closed {
+		return nil
+	}
+	tx.closed = true
+	err := winsys.EmptyClipboard()
+	if cerr := winsys.CloseClipboard(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
 func SetUnicodeText(text string) error {
 	data, err := getUnicodeBytes(text)
 	if err != nil {
 		return err
 	}
-	const CF_UNICODETEXT = 13
 	if err := winsys.OpenClipboard(0); err != nil {
 		return err
 	}
@@ -87,7 +285,7 @@ func SetUnicodeText(text string) error {
 	return setClipboardDataSlice(CF_UNICODETEXT, data)
 }
 
-// GetFileGroupDescriptor returns a slice containing file metadata (filename + filesize) in the FileGroupDescriptorW slot
+// GetFileGroupDescriptor returns a slice containing file metadata (name, size, attributes and times) in the FileGroupDescriptorW slot
 func GetFileGroupDescriptor() ([]FileInfo, error) {
 	id, err := winsys.RegisterClipboardFormat(_CFSTR_FILEGROUPDESCRIPTORW)
 	if err != nil {
@@ -101,25 +299,11 @@ func GetFileGroupDescriptor() ([]FileInfo, error) {
 	if err := winsys.IsClipboardFormatAvailable(id); err != nil {
 		return nil, err
 	}
-	h, err := getClipboardDataHGlobalSlice(id)
+	data, err := getClipboardDataGlobal(Format(id), maxFileGroupDescriptorSize)
 	if err != nil {
 		return nil, err
 	}
-
-	items := []_FILEDESCRIPTORW{}
-	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&items))
-	hdr.Data = uintptr(unsafe.Pointer(&h[4]))
-	hdr.Len = int(binary.LittleEndian.Uint32(h))
-	result := []FileInfo{}
-
-	for i := 0; i < len(items); i++ {
-		fd := items[i]
-		result = append(result, FileInfo{
-			Name: syscall.UTF16ToString(fd.cFileName[:]),
-			Size: int64(fd.nFileSizeHigh)<<32 | int64(fd.nFileSizeLow),
-		})
-	}
-	return result, nil
+	return DecodeFileGroupDescriptor(data)
 }
 
 type NamedReadCloser interface {
@@ -134,7 +318,12 @@ type comStreamWrapper struct {
 }
 
 func (s *comStreamWrapper) Read(buf []byte) (int, error) {
-	return s.iStream.Read(buf)
+	n, err := s.iStream.Read(buf)
+	// some streams keep returning S_OK without data at their end
+	if n == 0 && err == nil && len(buf) > 0 {
+		return 0, io.EOF
+	}
+	return n, err
 }
 func (s *comStreamWrapper) Close() error {
 	return s.medium.Release()
@@ -142,6 +331,20 @@ func (s *comStreamWrapper) Close() error {
 func (s *comStreamWrapper) Name() string {
 	return s.name
 }
+
+// memoryContent is FileContents data that was copied out of an HGLOBAL or IStorage
+type memoryContent struct {
+	*bytes.Reader
+	name string
+}
+
+func (c *memoryContent) Close() error {
+	return nil
+}
+func (c *memoryContent) Name() string {
+	return c.name
+}
+
 func GetFileContents() ([]NamedReadCloser, error) {
 	fds, err := GetFileGroupDescriptor()
 	if err != nil {
@@ -153,31 +356,17 @@ func GetFileContents() ([]NamedReadCloser, error) {
 		return nil, err
 	}
 	defer dataObject.Release()
-	id1, _ := winsys.RegisterClipboardFormat(_CFSTR_FILECONTENTS)
-
-	const DVASPECT_CONTENT = 0x1
-	format := winsys.FORMATETC{
-		ClipFormat:     uint16(id1),
-		DvTargetDevice: 0,
-		Aspect:         DVASPECT_CONTENT,
-		Index:          -1,
-		Tymed:          uint32(winsys.TymedHGLOBAL | winsys.TymedISTREAM),
-	}
 
 	var result []NamedReadCloser
 	for i := 0; i < len(fds); i++ {
-		format.Index = int32(i)
-		var medium winsys.STGMEDIUM
-		if err := dataObject.GetData(&format, &medium); err != nil {
-			return nil, err
-		}
-
-		stream, err := medium.Stream()
+		c, err := getFileContent(dataObject, i, &fds[i])
 		if err != nil {
-			medium.Release()
+			for _, v := range result {
+				v.Close()
+			}
 			return nil, err
 		}
-		result = append(result, &comStreamWrapper{iStream: stream, medium: medium, name: fds[i].Name})
+		result = append(result, c)
 	}
 
 	return result, nil
@@ -188,28 +377,75 @@ func GetFileContent(index int) (NamedReadCloser, error) {
 		return nil, err
 	}
 	defer dataObject.Release()
-	id1, _ := winsys.RegisterClipboardFormat(_CFSTR_FILECONTENTS)
 
-	const DVASPECT_CONTENT = 0x1
+	// the descriptor is optional, it provides the name and the size of HGLOBAL contents
+	var info *FileInfo
+	if fds, err := GetFileGroupDescriptor(); err == nil && index >= 0 && index < len(fds) {
+		info = &fds[index]
+	}
+	return getFileContent(dataObject, index, info)
+}
+
+// getFileContent requests the FileContents item index from dataObject.
+//
+// Streams are read directly, HGLOBAL contents are copied
+// and storages (e.g. Outlook messages) are serialized as compound file.
+func getFileContent(dataObject *winsys.IDataObject, index int, info *FileInfo) (NamedReadCloser, error) {
+	id, err := winsys.RegisterClipboardFormat(_CFSTR_FILECONTENTS)
+	if err != nil {
+		return nil, err
+	}
 	format := winsys.FORMATETC{
-		ClipFormat:     uint16(id1),
+		ClipFormat:     uint16(id),
 		DvTargetDevice: 0,
 		Aspect:         DVASPECT_CONTENT,
 		Index:          int32(index),
-		Tymed:          uint32(winsys.TymedHGLOBAL | winsys.TymedISTREAM),
+		Tymed:          uint32(winsys.TymedHGLOBAL | winsys.TymedISTREAM | winsys.TymedISTORAGE),
 	}
 
 	var medium winsys.STGMEDIUM
 	if err := dataObject.GetData(&format, &medium); err != nil {
 		return nil, err
 	}
+	var name string
+	if info != nil {
+		name = info.Name
+	}
 
-	stream, err := medium.Stream()
-	if err != nil {
+	switch medium.Tymed {
+	case winsys.TymedISTREAM:
+		stream, err := medium.Stream()
+		if err != nil {
+			medium.Release()
+			return nil, err
+		}
+		return &comStreamWrapper{iStream: stream, medium: medium, name: name}, nil
+	case winsys.TymedHGLOBAL:
+		data, err := medium.Bytes()
 		medium.Release()
-		return nil, err
+		if err != nil {
+			return nil, err
+		}
+		// GlobalSize might be rounded up
+		if info != nil && info.Size > 0 && info.Size < int64(len(data)) {
+			data = data[:info.Size]
+		}
+		return &memoryContent{Reader: bytes.NewReader(data), name: name}, nil
+	case winsys.TymedISTORAGE:
+		stg, err := medium.Storage()
+		if err != nil {
+			medium.Release()
+			return nil, err
+		}
+		data, err := compoundFileFromStorage(stg)
+		medium.Release()
+		if err != nil {
+			return nil, err
+		}
+		return &memoryContent{Reader: bytes.NewReader(data), name: name}, nil
 	}
-	return &comStreamWrapper{iStream: stream, medium: medium}, nil
+	medium.Release()
+	return nil, fmt.Errorf("unsupported medium %d", medium.Tymed)
 }
 
 // returns a slice containing the filepaths in the H_DROP(15) slot
@@ -260,8 +496,51 @@ func GetHDROP() ([]string, error) {
 	return []string{}, nil
 }
 
+// GetShellIDListArray returns the file system paths of the items in the "Shell IDList Array" slot.
+// Items without a file system path, like the Control Panel, are skipped.
+func GetShellIDListArray() ([]string, error) {
+	id, err := RegisterFormat(ShellIDListFormatName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := GetFormatData(id)
+	if err != nil {
+		return nil, err
+	}
+	pidls, err := DecodeShellIDList(data)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	result := make([]string, 0, len(pidls))
+	for _, pidl := range pidls {
+		err := winsys.ShGetPathFromIDList(uintptr(unsafe.Pointer(&pidl[0])), buf)
+		runtime.KeepAlive(pidl)
+		if err != nil {
+			continue
+		}
+		result = append(result, windows.UTF16ToString(buf))
+	}
+	return result, nil
+}
+
 // Formats returns a slice that contains all formats currently avaiable in the clipboard
+//
+// Deprecated: use AvailableFormats
 func Formats() ([]int, error) {
+	formats, err := AvailableFormats()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]int, len(formats))
+	for i, f := range formats {
+		result[i] = int(f)
+	}
+	return result, nil
+}
+
+// AvailableFormats returns all formats currently available in the clipboard, in the order they were set
+func AvailableFormats() ([]Format, error) {
 	if err := winsys.OpenClipboard(0); err != nil {
 		return nil, err
 	}
@@ -269,7 +548,7 @@ func Formats() ([]int, error) {
 
 	var f uint32 = 0
 	var err error
-	var result []int
+	var result []Format
 	retries := 0
 	for {
 		f, err = winsys.EnumClipboardFormats(f)
@@ -289,65 +568,28 @@ func Formats() ([]int, error) {
 			}
 			return nil, err
 		}
-		result = append(result, int(f))
+		result = append(result, Format(f))
 	}
 	return result, nil
 }
 
-var ErrUnknownClipboardFormat = errors.New("unknown clipboard format")
-
-var predefinedFormatNames = map[uint]string{
-	1:  "CF_TEXT",
-	2:  "CF_BITMAP",
-	3:  "CF_METAFILEPICT",
-	4:  "CF_SYLK",
-	5:  "CF_DIF",
-	6:  "CF_TIFF",
-	7:  "CF_OEMTEXT",
-	8:  "CF_DIB",
-	9:  "CF_PALETTE",
-	10: "CF_PENDATA",
-	11: "CF_RIFF",
-	12: "CF_WAVE",
-	13: "CF_UNICODETEXT",
-	14: "CF_ENHMETAFILE",
-	15: "CF_HDROP",
-	16: "CF_LOCALE",
-	17: "CF_DIBV5",
-
-	0x0080: "CF_OWNERDISPLAY",
-	0x0081: "CF_DSPTEXT",
-	0x0082: "CF_DSPBITMAP",
-	0x0083: "CF_DSPMETAFILEPICT",
-	0x008E: "CF_DSPENHMETAFILE",
-	0x0200: "CF_PRIVATEFIRST",
-	0x02FF: "CF_PRIVATELAST",
-	0x0300: "CF_GDIOBJFIRST",
-	0x03FF: "CF_GDIOBJLAST",
-}
-
-func predefinedFormatName(id uint) (string, error) {
-	if v, ok := predefinedFormatNames[id]; ok {
-		return v, nil
-	}
-	if id > 0x0200 && id < 0x02FF {
-		return "PRIVATE", nil
-	}
-	if id > 0x0300 && id < 0x03FF {
-		return "GDIOBJ", nil
-	}
-	return "", fmt.Errorf("unsupported format %d. %w", id, ErrUnknownClipboardFormat)
+// FormatName returns a readable name for the passed id.
+//
+// Being either a pre-defined name, or through a (cached) call to GetClipboardFormatNameW.
+//
+// Deprecated: use Format.Name
+func FormatName(id int) (string, error) {
+	return Format(id).Name()
 }
 
-func isRegisteredClipboardFormat(id uint) bool {
-	return id >= 0xC000 && id <= 0xFFFF
+// RegisterFormat returns the id of the format name, registering it if necessary
+func RegisterFormat(name string) (Format, error) {
+	return DefaultRegistry.Register(name)
 }
 
-// FormatName returns a readable name for the passed id.
-//
-// Being either a pre-defined name, or through a call to GetClipboardFormatNameW)
-func FormatName(id int) (string, error) {
-	if isRegisteredClipboardFormat(uint(id)) {
+// systemFormatName resolves id without any caching
+func systemFormatName(id Format) (string, error) {
+	if id.IsRegistered() {
 		buf := [256]uint16{}
 		n, err := winsys.GetClipboardFormatName(uint32(id), &buf[0], int32(len(buf)))
 		if err != nil {
@@ -356,5 +598,5 @@ func FormatName(id int) (string, error) {
 		return string(utf16.Decode(buf[:n])), nil
 	}
 
-	return predefinedFormatName(uint(id))
+	return predefinedFormatName(id)
 }
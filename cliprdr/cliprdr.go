@@ -0,0 +1,204 @@
+// Package cliprdr encodes and decodes the PDUs of the RDP clipboard virtual channel (MS-RDPECLIP).
+package cliprdr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MsgType is the msgType field of CLIPRDR_HEADER
+type MsgType uint16
+
+const (
+	MsgMonitorReady         MsgType = 0x0001
+	MsgFormatList           MsgType = 0x0002
+	MsgFormatListResponse   MsgType = 0x0003
+	MsgFormatDataRequest    MsgType = 0x0004
+	MsgFormatDataResponse   MsgType = 0x0005
+	MsgTempDirectory        MsgType = 0x0006
+	MsgClipCaps             MsgType = 0x0007
+	MsgFileContentsRequest  MsgType = 0x0008
+	MsgFileContentsResponse MsgType = 0x0009
+	MsgLockClipData         MsgType = 0x000A
+	MsgUnlockClipData       MsgType = 0x000B
+)
+
+var msgTypeNames = map[MsgType]string{
+	MsgMonitorReady:         "CB_MONITOR_READY",
+	MsgFormatList:           "CB_FORMAT_LIST",
+	MsgFormatListResponse:   "CB_FORMAT_LIST_RESPONSE",
+	MsgFormatDataRequest:    "CB_FORMAT_DATA_REQUEST",
+	MsgFormatDataResponse:   "CB_FORMAT_DATA_RESPONSE",
+	MsgTempDirectory:        "CB_TEMP_DIRECTORY",
+	MsgClipCaps:             "CB_CLIP_CAPS",
+	MsgFileContentsRequest:  "CB_FILECONTENTS_REQUEST",
+	MsgFileContentsResponse: "CB_FILECONTENTS_RESPONSE",
+	MsgLockClipData:         "CB_LOCK_CLIPDATA",
+	MsgUnlockClipData:       "CB_UNLOCK_CLIPDATA",
+}
+
+func (t MsgType) String() string {
+	if name, ok := msgTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("MsgType(%d)", uint16(t))
+}
+
+// msgFlags of CLIPRDR_HEADER
+const (
+	flagResponseOK   = 0x0001
+	flagResponseFail = 0x0002
+	flagASCIINames   = 0x0004
+)
+
+// headerSize is the size of CLIPRDR_HEADER
+const headerSize = 8
+
+// DefaultMaxPDUSize limits the dataLen accepted by ReadPDU when Codec.MaxPDUSize is zero
+const DefaultMaxPDUSize = 64 << 20
+
+var (
+	ErrShortPDU      = errors.New("cliprdr: short PDU")
+	ErrUnknownPDU    = errors.New("cliprdr: unknown msgType")
+	ErrMalformedPDU  = errors.New("cliprdr: malformed PDU")
+	ErrPDUTooLarge   = errors.New("cliprdr: PDU exceeds size limit")
+	errUnexpectedEnd = fmt.Errorf("unexpected end of data. %w", ErrMalformedPDU)
+)
+
+// PDU is implemented by every CLIPRDR message
+type PDU interface {
+	Type() MsgType
+	flags(c *Codec) uint16
+	appendBody(b []byte, c *Codec) []byte
+	decodeBody(flags uint16, body []byte, c *Codec) error
+}
+
+// Codec encodes and decodes PDUs.
+//
+// The zero value uses short format names, as mandated before capabilities are exchanged.
+type Codec struct {
+	// LongFormatNames selects the long format name variant of the Format List PDU.
+	// Set it once both sides announced CB_USE_LONG_FORMAT_NAMES.
+	LongFormatNames bool
+	// MaxPDUSize limits the dataLen accepted by ReadPDU, 0 means DefaultMaxPDUSize
+	MaxPDUSize uint32
+}
+
+// Negotiate updates c from the capabilities of both sides
+func (c *Codec) Negotiate(local, remote GeneralFlags) {
+	c.LongFormatNames = local&remote&UseLongFormatNames != 0
+}
+
+// Encode returns the wire representation of p including CLIPRDR_HEADER
+func (c *Codec) Encode(p PDU) []byte {
+	b := make([]byte, headerSize, 64)
+	b = p.appendBody(b, c)
+	binary.LittleEndian.PutUint16(b[0:], uint16(p.Type()))
+	binary.LittleEndian.PutUint16(b[2:], p.flags(c))
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-headerSize))
+	return b
+}
+
+// Decode parses the PDU at the start of data and returns it along with the number of bytes consumed
+func (c *Codec) Decode(data []byte) (PDU, int, error) {
+	if len(data) < headerSize {
+		return nil, 0, ErrShortPDU
+	}
+	msgType := MsgType(binary.LittleEndian.Uint16(data[0:]))
+	flags := binary.LittleEndian.Uint16(data[2:])
+	dataLen := binary.LittleEndian.Uint32(data[4:])
+	if uint64(dataLen) > uint64(len(data)-headerSize) {
+		return nil, 0, ErrShortPDU
+	}
+	n := headerSize + int(dataLen)
+
+	p := newPDU(msgType)
+	if p == nil {
+		return nil, n, fmt.Errorf("%v. %w", msgType, ErrUnknownPDU)
+	}
+	if err := p.decodeBody(flags, data[headerSize:n], c); err != nil {
+		return nil, n, fmt.Errorf("%v: %w", msgType, err)
+	}
+	return p, n, nil
+}
+
+// ReadPDU reads and decodes a single PDU from r
+func (c *Codec) ReadPDU(r io.Reader) (PDU, error) {
+	hdr := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	dataLen := binary.LittleEndian.Uint32(hdr[4:])
+	// the buffer is allocated before any data arrived, the limit keeps peers from requesting gigabytes
+	if dataLen > c.maxPDUSize() {
+		return nil, fmt.Errorf("%d bytes. %w", dataLen, ErrPDUTooLarge)
+	}
+	buf := make([]byte, headerSize+int(dataLen))
+	copy(buf, hdr)
+	if _, err := io.ReadFull(r, buf[headerSize:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	p, _, err := c.Decode(buf)
+	return p, err
+}
+
+func (c *Codec) maxPDUSize() uint32 {
+	if c.MaxPDUSize > 0 {
+		return c.MaxPDUSize
+	}
+	return DefaultMaxPDUSize
+}
+
+// WritePDU encodes p and writes it to w
+func (c *Codec) WritePDU(w io.Writer, p PDU) error {
+	_, err := w.Write(c.Encode(p))
+	return err
+}
+
+func newPDU(t MsgType) PDU {
+	switch t {
+	case MsgMonitorReady:
+		return &MonitorReady{}
+	case MsgFormatList:
+		return &FormatList{}
+	case MsgFormatListResponse:
+		return &FormatListResponse{}
+	case MsgFormatDataRequest:
+		return &FormatDataRequest{}
+	case MsgFormatDataResponse:
+		return &FormatDataResponse{}
+	case MsgTempDirectory:
+		return &TempDirectory{}
+	case MsgClipCaps:
+		return &Capabilities{}
+	case MsgFileContentsRequest:
+		return &FileContentsRequest{}
+	case MsgFileContentsResponse:
+		return &FileContentsResponse{}
+	case MsgLockClipData:
+		return &LockClipData{}
+	case MsgUnlockClipData:
+		return &UnlockClipData{}
+	}
+	return nil
+}
+
+func responseFlags(ok bool) uint16 {
+	if ok {
+		return flagResponseOK
+	}
+	return flagResponseFail
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
@@ -0,0 +1,283 @@
+package cliprdr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// utf16Field returns s as UTF-16LE hex, zero padded to size bytes
+func utf16Field(s string, size int) string {
+	b := make([]byte, size)
+	putUTF16(b, s)
+	return hex.EncodeToString(b)
+}
+
+// PDUs laid out like the annotated examples of MS-RDPECLIP section 4:
+// CLIPRDR_HEADER (msgType, msgFlags, dataLen) followed by the body
+var specExamples = []struct {
+	name  string
+	codec Codec
+	wire  string
+	pdu   PDU
+}{
+	{
+		name: "monitor ready",
+		wire: "0100 0000 00000000",
+		pdu:  &MonitorReady{},
+	},
+	{
+		name: "clipboard capabilities",
+		wire: "0700 0000 10000000" +
+			"0100 0000" + // cCapabilitiesSets, pad1
+			"0100 0c00 02000000 0e000000", // CB_CAPSTYPE_GENERAL, lengthCapability, version, generalFlags
+		pdu: &Capabilities{Version: CapsVersion2, Flags: UseLongFormatNames | StreamFileClipEnabled | FileClipNoFilePaths},
+	},
+	{
+		name:  "format list, long format names",
+		codec: Codec{LongFormatNames: true},
+		wire: "0200 0000 18000000" +
+			"04c00000 4e0061007400690076006500 0000" + // 0xC004 "Native"
+			"0d000000 0000", // CF_UNICODETEXT without name
+		pdu: &FormatList{Formats: []Format{{ID: 0xC004, Name: "Native"}, {ID: 13}}},
+	},
+	{
+		name: "format list, short UTF-16 format names",
+		wire: "0200 0000 24000000" +
+			"04c00000" + utf16Field("Native", 32),
+		pdu: &FormatList{Formats: []Format{{ID: 0xC004, Name: "Native"}}},
+	},
+	{
+		name: "format list, short ASCII format names",
+		wire: "0200 0400 24000000" +
+			"04c00000" + hex.EncodeToString(append([]byte("Native"), make([]byte, 26)...)),
+		pdu: &FormatList{Formats: []Format{{ID: 0xC004, Name: "Native"}}, ASCIINames: true},
+	},
+	{
+		name: "format list response",
+		wire: "0300 0100 00000000",
+		pdu:  &FormatListResponse{OK: true},
+	},
+	{
+		name: "format data request",
+		wire: "0400 0000 04000000 0d000000",
+		pdu:  &FormatDataRequest{FormatID: 13},
+	},
+	{
+		name: "format data response",
+		wire: "0500 0100 04000000 68000000",
+		pdu:  &FormatDataResponse{OK: true, Data: []byte{'h', 0, 0, 0}},
+	},
+	{
+		name: "failed format data response",
+		wire: "0500 0200 00000000",
+		pdu:  &FormatDataResponse{},
+	},
+	{
+		name: "temporary directory",
+		wire: "0600 0000 08020000" + utf16Field(`C:\Temp`, tempDirectorySize),
+		pdu:  &TempDirectory{Path: `C:\Temp`},
+	},
+	{
+		name: "file contents size request",
+		wire: "0800 0000 18000000" +
+			"02000000 00000000 01000000" + // streamId, lindex, FILECONTENTS_SIZE
+			"00000000 00000000 08000000", // nPositionLow, nPositionHigh, cbRequested
+		pdu: &FileContentsRequest{StreamID: 2, Flags: FileContentsSize, Requested: 8},
+	},
+	{
+		name: "file contents range request with clipDataId",
+		wire: "0800 0000 1c000000" +
+			"03000000 01000000 02000000" +
+			"00100000 01000000 00000100 07000000",
+		pdu: &FileContentsRequest{StreamID: 3, Index: 1, Flags: FileContentsRange, Position: 1<<32 | 0x1000, Requested: 0x10000, ClipDataID: 7, HasClipDataID: true},
+	},
+	{
+		name: "file contents size response",
+		wire: "0900 0100 0c000000 02000000 0004000000000000",
+		pdu:  SizeResponse(2, 0x400),
+	},
+	{
+		name: "lock clipboard data",
+		wire: "0a00 0000 04000000 07000000",
+		pdu:  &LockClipData{ClipDataID: 7},
+	},
+	{
+		name: "unlock clipboard data",
+		wire: "0b00 0000 04000000 07000000",
+		pdu:  &UnlockClipData{ClipDataID: 7},
+	},
+}
+
+func TestSpecExamples(t *testing.T) {
+	for _, tt := range specExamples {
+		t.Run(tt.name, func(t *testing.T) {
+			wire := unhex(t, tt.wire)
+			c := tt.codec
+
+			p, n, err := c.Decode(wire)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != len(wire) {
+				t.Errorf("consumed %d of %d bytes", n, len(wire))
+			}
+			if !reflect.DeepEqual(p, tt.pdu) {
+				t.Errorf("decoded %#v, want %#v", p, tt.pdu)
+			}
+			if got := c.Encode(tt.pdu); !bytes.Equal(got, wire) {
+				t.Errorf("encoded\n%x\nwant\n%x", got, wire)
+			}
+		})
+	}
+}
+
+func TestReadPDUStream(t *testing.T) {
+	var buf bytes.Buffer
+	var c Codec
+	for _, tt := range specExamples[:2] {
+		if err := c.WritePDU(&buf, tt.pdu); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range specExamples[:2] {
+		p, err := c.ReadPDU(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(p, tt.pdu) {
+			t.Errorf("read %#v, want %#v", p, tt.pdu)
+		}
+	}
+}
+
+func TestReadPDUSizeLimit(t *testing.T) {
+	hdr := unhex(t, "0500 0100 ffffffff")
+	var c Codec
+	if _, err := c.ReadPDU(bytes.NewReader(hdr)); !errors.Is(err, ErrPDUTooLarge) {
+		t.Errorf("default limit: got %v, want ErrPDUTooLarge", err)
+	}
+
+	c.MaxPDUSize = 3
+	wire := unhex(t, "0400 0000 04000000 0d000000")
+	if _, err := c.ReadPDU(bytes.NewReader(wire)); !errors.Is(err, ErrPDUTooLarge) {
+		t.Errorf("MaxPDUSize 3: got %v, want ErrPDUTooLarge", err)
+	}
+	c.MaxPDUSize = 4
+	if _, err := c.ReadPDU(bytes.NewReader(wire)); err != nil {
+		t.Errorf("MaxPDUSize 4: %v", err)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+		wire  string
+		want  error
+	}{
+		{"short header", Codec{}, "0100 0000", ErrShortPDU},
+		{"dataLen beyond data", Codec{}, "0400 0000 08000000 0d000000", ErrShortPDU},
+		{"unknown msgType", Codec{}, "ff00 0000 00000000", ErrUnknownPDU},
+		{"short format name entry", Codec{}, "0200 0000 05000000 0400000000", ErrMalformedPDU},
+		{"unterminated long format name", Codec{LongFormatNames: true}, "0200 0000 06000000 04c00000 4e00", ErrMalformedPDU},
+		{"capability length", Codec{}, "0700 0000 08000000 0100 0000 0100 0200", ErrMalformedPDU},
+		{"short format data request", Codec{}, "0400 0000 02000000 0d00", ErrMalformedPDU},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := tt.codec.Decode(unhex(t, tt.wire)); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	var c Codec
+	c.Negotiate(UseLongFormatNames|CanLockClipData, UseLongFormatNames)
+	if !c.LongFormatNames {
+		t.Error("both sides support long format names")
+	}
+	c.Negotiate(UseLongFormatNames, StreamFileClipEnabled)
+	if c.LongFormatNames {
+		t.Error("only one side supports long format names")
+	}
+}
+
+func TestFileList(t *testing.T) {
+	modTime := time.Date(2021, 4, 15, 10, 30, 0, 0, time.UTC)
+	files := []clipboard.FileInfo{
+		{Name: `dir\report.txt`, Size: 0x100000002, Attributes: 0x20, ModTime: modTime},
+	}
+
+	// CLIPRDR_FILELIST: cItems followed by one 592 byte FILEDESCRIPTORW per file
+	want := make([]byte, 4+592)
+	binary.LittleEndian.PutUint32(want, 1)
+	fd := want[4:]
+	binary.LittleEndian.PutUint32(fd[0:], 0x64) // FD_ATTRIBUTES | FD_WRITESTIME | FD_FILESIZE
+	binary.LittleEndian.PutUint32(fd[36:], 0x20)
+	binary.LittleEndian.PutUint64(fd[56:], uint64(modTime.Unix()+11644473600)*1e7)
+	binary.LittleEndian.PutUint32(fd[64:], 1) // nFileSizeHigh
+	binary.LittleEndian.PutUint32(fd[68:], 2) // nFileSizeLow
+	putUTF16(fd[72:], `dir\report.txt`)
+
+	if got := EncodeFileList(files); !bytes.Equal(got, want) {
+		t.Errorf("encoded\n%x\nwant\n%x", got, want)
+	}
+	got, err := DecodeFileList(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != files[0].Name || got[0].Size != files[0].Size ||
+		got[0].Attributes != files[0].Attributes || !got[0].ModTime.Equal(modTime) {
+		t.Errorf("decoded %+v, want %+v", got, files)
+	}
+
+	if _, err := DecodeFileList(want[:300]); !errors.Is(err, clipboard.ErrInvalidFileGroupDescriptor) {
+		t.Errorf("truncated list: got %v", err)
+	}
+}
+
+func TestFormatMapping(t *testing.T) {
+	local := clipboard.NewRegistry(clipboard.NewMemoryBackend())
+	html, err := local.Register("HTML Format")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := LocalFormatList([]clipboard.Format{clipboard.CF_UNICODETEXT, html}, local.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Format{{ID: 13}, {ID: uint32(html), Name: "HTML Format"}}
+	if !reflect.DeepEqual(list.Formats, want) {
+		t.Errorf("LocalFormatList %+v, want %+v", list.Formats, want)
+	}
+
+	// the remote side registered its formats in a different order
+	remote := &FormatList{Formats: []Format{{ID: 13}, {ID: 0xC0F0, Name: "HTML Format"}, {ID: 0xC0F1, Name: "Native"}}}
+	m, err := remote.Map(local.Register)
+	if err != nil {
+		t.Fatal(err)
+	}
+	native, _ := local.Register("Native")
+	if m[13] != clipboard.CF_UNICODETEXT || m[0xC0F0] != html || m[0xC0F1] != native {
+		t.Errorf("Map %v", m)
+	}
+}
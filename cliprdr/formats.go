@@ -0,0 +1,70 @@
+package cliprdr
+
+import (
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+// Registered format names used for file transfers
+const (
+	FileGroupDescriptorW = "FileGroupDescriptorW"
+	FileContents         = "FileContents"
+)
+
+// LocalFormatList creates a Format List PDU from the local clipboard formats.
+//
+// ids and name are typically clipboard.AvailableFormats and clipboard.Format.Name.
+// Names are only sent for registered formats, the ids of all others are identical on both sides of the channel.
+func LocalFormatList(ids []clipboard.Format, name func(id clipboard.Format) (string, error)) (*FormatList, error) {
+	p := &FormatList{}
+	for _, id := range ids {
+		f := Format{ID: uint32(id)}
+		if id.IsRegistered() {
+			n, err := name(id)
+			if err != nil {
+				return nil, err
+			}
+			f.Name = n
+		}
+		p.Formats = append(p.Formats, f)
+	}
+	return p, nil
+}
+
+// Map translates the format ids of a received Format List into local ids, keyed by remote id.
+//
+// register resolves a format name to its local id, e.g. clipboard.RegisterFormat.
+func (p *FormatList) Map(register func(name string) (clipboard.Format, error)) (map[uint32]clipboard.Format, error) {
+	result := make(map[uint32]clipboard.Format, len(p.Formats))
+	for _, f := range p.Formats {
+		if f.Name == "" || !clipboard.Format(f.ID).IsRegistered() {
+			result[f.ID] = clipboard.Format(f.ID)
+			continue
+		}
+		id, err := register(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		result[f.ID] = id
+	}
+	return result, nil
+}
+
+// Lookup returns the remote id of the format announced with name
+func (p *FormatList) Lookup(name string) (uint32, bool) {
+	for _, f := range p.Formats {
+		if f.Name == name {
+			return f.ID, true
+		}
+	}
+	return 0, false
+}
+
+// DecodeFileList parses a CLIPRDR_FILELIST, the FileGroupDescriptorW format data
+func DecodeFileList(data []byte) ([]clipboard.FileInfo, error) {
+	return clipboard.DecodeFileGroupDescriptor(data)
+}
+
+// EncodeFileList creates a CLIPRDR_FILELIST, the FileGroupDescriptorW format data
+func EncodeFileList(files []clipboard.FileInfo) []byte {
+	return clipboard.EncodeFileGroupDescriptor(files)
+}
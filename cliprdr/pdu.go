@@ -0,0 +1,393 @@
+package cliprdr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+// MonitorReady is sent by the server once the channel is ready (CLIPRDR_MONITOR_READY)
+type MonitorReady struct{}
+
+func (*MonitorReady) Type() MsgType                           { return MsgMonitorReady }
+func (*MonitorReady) flags(*Codec) uint16                     { return 0 }
+func (*MonitorReady) appendBody(b []byte, _ *Codec) []byte    { return b }
+func (*MonitorReady) decodeBody(uint16, []byte, *Codec) error { return nil }
+
+// GeneralFlags of CLIPRDR_GENERAL_CAPABILITY
+type GeneralFlags uint32
+
+const (
+	UseLongFormatNames     GeneralFlags = 0x00000002
+	StreamFileClipEnabled  GeneralFlags = 0x00000004
+	FileClipNoFilePaths    GeneralFlags = 0x00000008
+	CanLockClipData        GeneralFlags = 0x00000010
+	HugeFileSupportEnabled GeneralFlags = 0x00000020
+)
+
+const (
+	CapsVersion1 = 0x00000001
+	CapsVersion2 = 0x00000002
+)
+
+// capabilitySetType of CLIPRDR_GENERAL_CAPABILITY
+const capsTypeGeneral = 0x0001
+
+// generalCapabilitySize is the lengthCapability of CLIPRDR_GENERAL_CAPABILITY
+const generalCapabilitySize = 12
+
+// Capabilities announces the supported features (CLIPRDR_CAPS).
+// Only the general capability set is defined by the protocol.
+type Capabilities struct {
+	Version uint32
+	Flags   GeneralFlags
+}
+
+func (*Capabilities) Type() MsgType       { return MsgClipCaps }
+func (*Capabilities) flags(*Codec) uint16 { return 0 }
+func (p *Capabilities) appendBody(b []byte, _ *Codec) []byte {
+	b = appendUint16(b, 1) // cCapabilitiesSets
+	b = appendUint16(b, 0) // pad1
+	b = appendUint16(b, capsTypeGeneral)
+	b = appendUint16(b, generalCapabilitySize)
+	b = appendUint32(b, p.Version)
+	return appendUint32(b, uint32(p.Flags))
+}
+func (p *Capabilities) decodeBody(_ uint16, body []byte, _ *Codec) error {
+	if len(body) < 4 {
+		return errUnexpectedEnd
+	}
+	count := binary.LittleEndian.Uint16(body)
+	body = body[4:]
+	for i := uint16(0); i < count; i++ {
+		if len(body) < 4 {
+			return errUnexpectedEnd
+		}
+		capsType := binary.LittleEndian.Uint16(body)
+		length := int(binary.LittleEndian.Uint16(body[2:]))
+		if length < 4 || length > len(body) {
+			return fmt.Errorf("capability length %d. %w", length, ErrMalformedPDU)
+		}
+		// unknown capability sets are skipped, as required by the specification
+		if capsType == capsTypeGeneral {
+			if length < generalCapabilitySize {
+				return fmt.Errorf("general capability length %d. %w", length, ErrMalformedPDU)
+			}
+			p.Version = binary.LittleEndian.Uint32(body[4:])
+			p.Flags = GeneralFlags(binary.LittleEndian.Uint32(body[8:]))
+		}
+		body = body[length:]
+	}
+	return nil
+}
+
+// tempDirectorySize is the size of wszTempDir in CLIPRDR_TEMP_DIRECTORY
+const tempDirectorySize = 520
+
+// TempDirectory tells the server where the client stores temporary files (CLIPRDR_TEMP_DIRECTORY)
+type TempDirectory struct {
+	Path string
+}
+
+func (*TempDirectory) Type() MsgType       { return MsgTempDirectory }
+func (*TempDirectory) flags(*Codec) uint16 { return 0 }
+func (p *TempDirectory) appendBody(b []byte, _ *Codec) []byte {
+	field := make([]byte, tempDirectorySize)
+	putUTF16(field[:tempDirectorySize-2], p.Path)
+	return append(b, field...)
+}
+func (p *TempDirectory) decodeBody(_ uint16, body []byte, _ *Codec) error {
+	if len(body) < tempDirectorySize {
+		return errUnexpectedEnd
+	}
+	p.Path = clipboard.DecodeUnicodeText(body[:tempDirectorySize])
+	return nil
+}
+
+// Format is a single entry of a Format List PDU
+type Format struct {
+	ID uint32
+	// Name is empty for predefined formats, and identifies registered formats
+	Name string
+}
+
+// shortFormatNameSize is the size of formatName in CLIPRDR_SHORT_FORMAT_NAME
+const shortFormatNameSize = 32
+
+// FormatList announces the formats available on the sender's clipboard (CLIPRDR_FORMAT_LIST).
+//
+// The long or short format name variant is chosen by Codec.LongFormatNames.
+type FormatList struct {
+	Formats []Format
+	// ASCIINames encodes short format names as 8-bit ASCII instead of UTF-16
+	ASCIINames bool
+}
+
+func (*FormatList) Type() MsgType { return MsgFormatList }
+func (p *FormatList) flags(c *Codec) uint16 {
+	if p.ASCIINames && !c.LongFormatNames {
+		return flagASCIINames
+	}
+	return 0
+}
+func (p *FormatList) appendBody(b []byte, c *Codec) []byte {
+	for _, f := range p.Formats {
+		b = appendUint32(b, f.ID)
+		if c.LongFormatNames {
+			for _, r := range utf16.Encode([]rune(f.Name)) {
+				b = appendUint16(b, r)
+			}
+			b = appendUint16(b, 0)
+			continue
+		}
+		field := make([]byte, shortFormatNameSize)
+		if p.ASCIINames {
+			// keep one byte for the terminator
+			copy(field[:shortFormatNameSize-1], f.Name)
+		} else {
+			putUTF16(field[:shortFormatNameSize-2], f.Name)
+		}
+		b = append(b, field...)
+	}
+	return b
+}
+func (p *FormatList) decodeBody(flags uint16, body []byte, c *Codec) error {
+	p.Formats = nil
+	p.ASCIINames = flags&flagASCIINames != 0
+	if !c.LongFormatNames {
+		const entrySize = 4 + shortFormatNameSize
+		if len(body)%entrySize != 0 {
+			return fmt.Errorf("%d bytes are no multiple of %d. %w", len(body), entrySize, ErrMalformedPDU)
+		}
+		for ; len(body) > 0; body = body[entrySize:] {
+			f := Format{ID: binary.LittleEndian.Uint32(body)}
+			name := body[4:entrySize]
+			if p.ASCIINames {
+				if i := bytes.IndexByte(name, 0); i >= 0 {
+					name = name[:i]
+				}
+				f.Name = string(name)
+			} else {
+				f.Name = clipboard.DecodeUnicodeText(name)
+			}
+			p.Formats = append(p.Formats, f)
+		}
+		return nil
+	}
+
+	for len(body) > 0 {
+		if len(body) < 6 {
+			return errUnexpectedEnd
+		}
+		f := Format{ID: binary.LittleEndian.Uint32(body)}
+		body = body[4:]
+		end := -1
+		for i := 0; i+1 < len(body); i += 2 {
+			if body[i] == 0 && body[i+1] == 0 {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return fmt.Errorf("unterminated format name. %w", ErrMalformedPDU)
+		}
+		f.Name = clipboard.DecodeUnicodeText(body[:end])
+		body = body[end+2:]
+		p.Formats = append(p.Formats, f)
+	}
+	return nil
+}
+
+// FormatListResponse acknowledges a Format List PDU (CLIPRDR_FORMAT_LIST_RESPONSE)
+type FormatListResponse struct {
+	OK bool
+}
+
+func (*FormatListResponse) Type() MsgType                        { return MsgFormatListResponse }
+func (p *FormatListResponse) flags(*Codec) uint16                { return responseFlags(p.OK) }
+func (*FormatListResponse) appendBody(b []byte, _ *Codec) []byte { return b }
+func (p *FormatListResponse) decodeBody(flags uint16, _ []byte, _ *Codec) error {
+	p.OK = flags&flagResponseOK != 0
+	return nil
+}
+
+// FormatDataRequest asks for the data of a format announced in the last Format List PDU
+// (CLIPRDR_FORMAT_DATA_REQUEST)
+type FormatDataRequest struct {
+	FormatID uint32
+}
+
+func (*FormatDataRequest) Type() MsgType       { return MsgFormatDataRequest }
+func (*FormatDataRequest) flags(*Codec) uint16 { return 0 }
+func (p *FormatDataRequest) appendBody(b []byte, _ *Codec) []byte {
+	return appendUint32(b, p.FormatID)
+}
+func (p *FormatDataRequest) decodeBody(_ uint16, body []byte, _ *Codec) error {
+	if len(body) < 4 {
+		return errUnexpectedEnd
+	}
+	p.FormatID = binary.LittleEndian.Uint32(body)
+	return nil
+}
+
+// FormatDataResponse carries the data requested by a Format Data Request PDU
+// (CLIPRDR_FORMAT_DATA_RESPONSE)
+type FormatDataResponse struct {
+	OK   bool
+	Data []byte
+}
+
+func (*FormatDataResponse) Type() MsgType         { return MsgFormatDataResponse }
+func (p *FormatDataResponse) flags(*Codec) uint16 { return responseFlags(p.OK) }
+func (p *FormatDataResponse) appendBody(b []byte, _ *Codec) []byte {
+	return append(b, p.Data...)
+}
+func (p *FormatDataResponse) decodeBody(flags uint16, body []byte, _ *Codec) error {
+	p.OK = flags&flagResponseOK != 0
+	p.Data = append([]byte(nil), body...)
+	return nil
+}
+
+// dwFlags of CLIPRDR_FILECONTENTS_REQUEST
+const (
+	FileContentsSize  = 0x00000001
+	FileContentsRange = 0x00000002
+)
+
+// FileContentsRequest asks for the size or a byte range of a file in the file list
+// (CLIPRDR_FILECONTENTS_REQUEST)
+type FileContentsRequest struct {
+	StreamID uint32
+	// Index of the file in the FileGroupDescriptorW list
+	Index int32
+	// Flags is either FileContentsSize or FileContentsRange
+	Flags     uint32
+	Position  uint64
+	Requested uint32
+	// ClipDataID refers to locked clipboard data and is only sent if HasClipDataID is set
+	ClipDataID    uint32
+	HasClipDataID bool
+}
+
+func (*FileContentsRequest) Type() MsgType       { return MsgFileContentsRequest }
+func (*FileContentsRequest) flags(*Codec) uint16 { return 0 }
+func (p *FileContentsRequest) appendBody(b []byte, _ *Codec) []byte {
+	b = appendUint32(b, p.StreamID)
+	b = appendUint32(b, uint32(p.Index))
+	b = appendUint32(b, p.Flags)
+	b = appendUint32(b, uint32(p.Position))
+	b = appendUint32(b, uint32(p.Position>>32))
+	b = appendUint32(b, p.Requested)
+	if p.HasClipDataID {
+		b = appendUint32(b, p.ClipDataID)
+	}
+	return b
+}
+func (p *FileContentsRequest) decodeBody(_ uint16, body []byte, _ *Codec) error {
+	if len(body) < 24 {
+		return errUnexpectedEnd
+	}
+	p.StreamID = binary.LittleEndian.Uint32(body[0:])
+	p.Index = int32(binary.LittleEndian.Uint32(body[4:]))
+	p.Flags = binary.LittleEndian.Uint32(body[8:])
+	p.Position = uint64(binary.LittleEndian.Uint32(body[12:])) | uint64(binary.LittleEndian.Uint32(body[16:]))<<32
+	p.Requested = binary.LittleEndian.Uint32(body[20:])
+	p.HasClipDataID = len(body) >= 28
+	if p.HasClipDataID {
+		p.ClipDataID = binary.LittleEndian.Uint32(body[24:])
+	}
+	return nil
+}
+
+// FileContentsResponse carries the size or byte range asked for by a File Contents Request PDU
+// (CLIPRDR_FILECONTENTS_RESPONSE)
+type FileContentsResponse struct {
+	OK       bool
+	StreamID uint32
+	// Data holds either the requested range or the 64 bit file size, see Size
+	Data []byte
+}
+
+// SizeResponse creates the response to a FileContentsSize request
+func SizeResponse(streamID uint32, size uint64) *FileContentsResponse {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, size)
+	return &FileContentsResponse{OK: true, StreamID: streamID, Data: data}
+}
+
+// Size interprets Data as the answer to a FileContentsSize request
+func (p *FileContentsResponse) Size() (uint64, error) {
+	if len(p.Data) < 8 {
+		return 0, errUnexpectedEnd
+	}
+	return binary.LittleEndian.Uint64(p.Data), nil
+}
+
+func (*FileContentsResponse) Type() MsgType         { return MsgFileContentsResponse }
+func (p *FileContentsResponse) flags(*Codec) uint16 { return responseFlags(p.OK) }
+func (p *FileContentsResponse) appendBody(b []byte, _ *Codec) []byte {
+	b = appendUint32(b, p.StreamID)
+	return append(b, p.Data...)
+}
+func (p *FileContentsResponse) decodeBody(flags uint16, body []byte, _ *Codec) error {
+	p.OK = flags&flagResponseOK != 0
+	// failure responses may omit the stream id
+	if len(body) < 4 {
+		if p.OK {
+			return errUnexpectedEnd
+		}
+		return nil
+	}
+	p.StreamID = binary.LittleEndian.Uint32(body)
+	p.Data = append([]byte(nil), body[4:]...)
+	return nil
+}
+
+// LockClipData asks the peer to retain the current file data (CLIPRDR_LOCK_CLIPDATA)
+type LockClipData struct {
+	ClipDataID uint32
+}
+
+func (*LockClipData) Type() MsgType       { return MsgLockClipData }
+func (*LockClipData) flags(*Codec) uint16 { return 0 }
+func (p *LockClipData) appendBody(b []byte, _ *Codec) []byte {
+	return appendUint32(b, p.ClipDataID)
+}
+func (p *LockClipData) decodeBody(_ uint16, body []byte, _ *Codec) error {
+	if len(body) < 4 {
+		return errUnexpectedEnd
+	}
+	p.ClipDataID = binary.LittleEndian.Uint32(body)
+	return nil
+}
+
+// UnlockClipData releases data retained by a Lock Clipboard Data PDU (CLIPRDR_UNLOCK_CLIPDATA)
+type UnlockClipData struct {
+	ClipDataID uint32
+}
+
+func (*UnlockClipData) Type() MsgType       { return MsgUnlockClipData }
+func (*UnlockClipData) flags(*Codec) uint16 { return 0 }
+func (p *UnlockClipData) appendBody(b []byte, _ *Codec) []byte {
+	return appendUint32(b, p.ClipDataID)
+}
+func (p *UnlockClipData) decodeBody(_ uint16, body []byte, _ *Codec) error {
+	if len(body) < 4 {
+		return errUnexpectedEnd
+	}
+	p.ClipDataID = binary.LittleEndian.Uint32(body)
+	return nil
+}
+
+// putUTF16 writes s as UTF-16LE into field, truncating it to fit
+func putUTF16(field []byte, s string) {
+	for i, r := range utf16.Encode([]rune(s)) {
+		if (i+1)*2 > len(field) {
+			break
+		}
+		binary.LittleEndian.PutUint16(field[i*2:], r)
+	}
+}
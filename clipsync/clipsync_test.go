@@ -0,0 +1,336 @@
+package clipsync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+// pipeHandshake authenticates both ends of a net.Pipe
+func pipeHandshake(t *testing.T, keyA, keyB []byte) (*peer, *peer, error, error) {
+	t.Helper()
+	ca, cb := net.Pipe()
+	t.Cleanup(func() {
+		ca.Close()
+		cb.Close()
+	})
+	type result struct {
+		p   *peer
+		err error
+	}
+	rc := make(chan result, 1)
+	go func() {
+		p, err := handshake(cb, "b", keyB)
+		if err != nil {
+			// unblock a pending write of the other side
+			cb.Close()
+		}
+		rc <- result{p, err}
+	}()
+	a, errA := handshake(ca, "a", keyA)
+	if errA != nil {
+		ca.Close()
+	}
+	r := <-rc
+	return a, r.p, errA, r.err
+}
+
+func TestHandshake(t *testing.T) {
+	a, b, errA, errB := pipeHandshake(t, []byte("secret"), []byte("secret"))
+	if errA != nil || errB != nil {
+		t.Fatal(errA, errB)
+	}
+	if a.id != "b" || b.id != "a" {
+		t.Errorf("peer ids %q, %q", a.id, b.id)
+	}
+}
+
+func TestHandshakeWrongKey(t *testing.T) {
+	_, _, errA, errB := pipeHandshake(t, []byte("secret"), []byte("other"))
+	if !errors.Is(errA, ErrAuth) && !errors.Is(errB, ErrAuth) {
+		t.Errorf("got %v, %v, want ErrAuth", errA, errB)
+	}
+	if errA == nil || errB == nil {
+		t.Errorf("both sides have to fail, got %v, %v", errA, errB)
+	}
+}
+
+func TestFrameAuthentication(t *testing.T) {
+	a, b, errA, errB := pipeHandshake(t, []byte("secret"), []byte("secret"))
+	if errA != nil || errB != nil {
+		t.Fatal(errA, errB)
+	}
+	write := func(f func() error) {
+		go func() {
+			if err := f(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	write(func() error { return a.writeFrame(frameChunk, []byte("data")) })
+	typ, payload, err := b.readFrame()
+	if err != nil || typ != frameChunk || string(payload) != "data" {
+		t.Fatalf("got %d %q %v", typ, payload, err)
+	}
+
+	// the same frame sent again, e.g. replayed by an attacker
+	a.out.seq--
+	write(func() error { return a.writeFrame(frameChunk, []byte("data")) })
+	if _, _, err := b.readFrame(); !errors.Is(err, ErrAuth) {
+		t.Errorf("replayed frame: got %v, want ErrAuth", err)
+	}
+
+	// a frame injected without knowing the session key
+	write(func() error { return writeFrame(b.conn, frameChunk, make([]byte, 8+frameMACSize)) })
+	if _, _, err := a.readFrame(); !errors.Is(err, ErrAuth) {
+		t.Errorf("forged frame: got %v, want ErrAuth", err)
+	}
+
+	write(func() error { return writeFrame(b.conn, frameUpdate, []byte("short")) })
+	if _, _, err := a.readFrame(); !errors.Is(err, ErrProtocol) {
+		t.Errorf("frame without MAC: got %v, want ErrProtocol", err)
+	}
+}
+
+func newTestNode(id string) *Node {
+	return &Node{
+		ID:      id,
+		Backend: clipboard.NewMemoryBackend(),
+		Key:     []byte("secret"),
+		Formats: []string{"Test Data"},
+	}
+}
+
+func setData(t *testing.T, n *Node, data []byte) {
+	t.Helper()
+	id, err := n.Backend.RegisterFormat("Test Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Backend.Write([]clipboard.Item{{Format: id, Data: data}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Poll(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitForData(t *testing.T, n *Node, want []byte) {
+	t.Helper()
+	id, err := n.Backend.RegisterFormat("Test Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := n.Backend.Data(id)
+		if err == nil && bytes.Equal(data, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s has %d bytes (%v), want %d bytes", n.ID, len(data), err, len(want))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSync(t *testing.T) {
+	a, b := newTestNode("a"), newTestNode("b")
+
+	// written before the peers connect, b catches up on connect
+	first := []byte("hello")
+	setData(t, a, first)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ca, cb := net.Pipe()
+	errc := make(chan error, 2)
+	go func() { errc <- a.HandleConn(ctx, ca) }()
+	go func() { errc <- b.HandleConn(ctx, cb) }()
+	waitForData(t, b, first)
+
+	// spans several chunks and goes the other way
+	second := bytes.Repeat([]byte("0123456789"), ChunkSize/4)
+	setData(t, b, second)
+	waitForData(t, a, second)
+
+	// applying an update must not publish it again
+	seq, _ := b.Backend.SequenceNumber()
+	if err := a.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if s, _ := b.Backend.SequenceNumber(); s != seq {
+		t.Error("update was echoed back")
+	}
+
+	cancel()
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err == nil {
+			t.Error("HandleConn returned without error")
+		}
+	}
+}
+
+func TestSyncUpdateTooLarge(t *testing.T) {
+	a, b := newTestNode("a"), newTestNode("b")
+	b.MaxUpdateSize = 16
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ca, cb := net.Pipe()
+	go a.HandleConn(ctx, ca)
+	errc := make(chan error, 1)
+	go func() { errc <- b.HandleConn(ctx, cb) }()
+
+	setData(t, a, make([]byte, 17))
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrUpdateTooLarge) {
+			t.Errorf("got %v, want ErrUpdateTooLarge", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("oversized update was accepted")
+	}
+}
+
+func TestVectorClockCompare(t *testing.T) {
+	tests := []struct {
+		a, b VectorClock
+		want Ordering
+	}{
+		{nil, nil, Equal},
+		{VectorClock{}, VectorClock{"a": 0}, Equal},
+		{VectorClock{"a": 1, "b": 2}, VectorClock{"a": 1, "b": 2}, Equal},
+		{VectorClock{"a": 1}, VectorClock{"a": 2}, Before},
+		{VectorClock{"a": 1}, VectorClock{"a": 1, "b": 1}, Before},
+		{nil, VectorClock{"a": 1}, Before},
+		{VectorClock{"a": 2}, VectorClock{"a": 1}, After},
+		{VectorClock{"a": 1, "b": 1}, VectorClock{"b": 1}, After},
+		{VectorClock{"a": 1}, VectorClock{"b": 1}, Concurrent},
+		{VectorClock{"a": 2, "b": 1}, VectorClock{"a": 1, "b": 2}, Concurrent},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Compare(tt.b); got != tt.want {
+			t.Errorf("%v.Compare(%v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		// the relation is antisymmetric
+		want := tt.want
+		switch want {
+		case Before:
+			want = After
+		case After:
+			want = Before
+		}
+		if got := tt.b.Compare(tt.a); got != want {
+			t.Errorf("%v.Compare(%v) = %d, want %d", tt.b, tt.a, got, want)
+		}
+	}
+}
+
+func TestVectorClockMerge(t *testing.T) {
+	a := VectorClock{"a": 2, "b": 1}
+	b := VectorClock{"b": 3, "c": 1}
+	want := VectorClock{"a": 2, "b": 3, "c": 1}
+	if got := a.Merge(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := b.Merge(a); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// the operands are not modified
+	if !reflect.DeepEqual(a, VectorClock{"a": 2, "b": 1}) || !reflect.DeepEqual(b, VectorClock{"b": 3, "c": 1}) {
+		t.Errorf("operands were modified: %v, %v", a, b)
+	}
+	if got := VectorClock(nil).Merge(a); !reflect.DeepEqual(got, a) {
+		t.Errorf("merging into nil: got %v", got)
+	}
+}
+
+func testUpdate(origin string, clock VectorClock, data string) *update {
+	return &update{Origin: origin, Clock: clock, Formats: []FormatData{{Name: "Test Data", Data: []byte(data)}}}
+}
+
+func TestReceiveConcurrent(t *testing.T) {
+	n := newTestNode("n")
+	if err := n.receive(nil, testUpdate("b", VectorClock{"b": 1}, "from b")); err != nil {
+		t.Fatal(err)
+	}
+	waitForData(t, n, []byte("from b"))
+
+	// concurrent with b's update, the lower origin loses on every node
+	if err := n.receive(nil, testUpdate("a", VectorClock{"a": 1}, "from a")); err != nil {
+		t.Fatal(err)
+	}
+	waitForData(t, n, []byte("from b"))
+	if want := (VectorClock{"a": 1, "b": 1}); !reflect.DeepEqual(n.clock, want) {
+		t.Errorf("clock %v after losing update, want %v", n.clock, want)
+	}
+
+	// the higher origin wins
+	if err := n.receive(nil, testUpdate("c", VectorClock{"c": 1}, "from c")); err != nil {
+		t.Fatal(err)
+	}
+	waitForData(t, n, []byte("from c"))
+	if n.current.Origin != "c" {
+		t.Errorf("current update from %q, want c", n.current.Origin)
+	}
+
+	// updates that were already seen are ignored
+	if err := n.receive(nil, testUpdate("b", VectorClock{"b": 1}, "from b")); err != nil {
+		t.Fatal(err)
+	}
+	waitForData(t, n, []byte("from c"))
+
+	// an update that knows all others replaces the contents regardless of its origin
+	if err := n.receive(nil, testUpdate("a", VectorClock{"a": 2, "b": 1, "c": 1}, "from a again")); err != nil {
+		t.Fatal(err)
+	}
+	waitForData(t, n, []byte("from a again"))
+}
+
+// lockCheckBackend fails the test if it is written while the node's mutex is held
+type lockCheckBackend struct {
+	clipboard.Backend
+	t *testing.T
+	n *Node
+}
+
+func (b *lockCheckBackend) Write(items []clipboard.Item) error {
+	done := make(chan struct{})
+	go func() {
+		b.n.mu.Lock()
+		b.n.mu.Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		b.t.Error("the Backend was written while holding the node's mutex")
+	}
+	return b.Backend.Write(items)
+}
+
+func TestReceiveWithoutLock(t *testing.T) {
+	n := newTestNode("n")
+	n.Backend = &lockCheckBackend{Backend: n.Backend, t: t, n: n}
+	if err := n.receive(nil, testUpdate("b", VectorClock{"b": 1}, "data")); err != nil {
+		t.Fatal(err)
+	}
+	waitForData(t, n, []byte("data"))
+
+	// the update was recorded, polling does not publish it again
+	if err := n.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if n.current.Origin != "b" || n.clock["n"] != 0 {
+		t.Errorf("update was republished: %+v, %v", n.current, n.clock)
+	}
+}
@@ -0,0 +1,60 @@
+package clipsync
+
+// VectorClock counts the changes every node made, keyed by node id
+type VectorClock map[string]uint64
+
+// Ordering is the result of comparing two vector clocks
+type Ordering int
+
+const (
+	Equal Ordering = iota
+	Before
+	After
+	Concurrent
+)
+
+// Compare returns how c relates to other
+func (c VectorClock) Compare(other VectorClock) Ordering {
+	less, greater := false, false
+	for k, v := range c {
+		if o := other[k]; v < o {
+			less = true
+		} else if v > o {
+			greater = true
+		}
+	}
+	for k, o := range other {
+		if _, ok := c[k]; !ok && o > 0 {
+			less = true
+		}
+	}
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return Before
+	case greater:
+		return After
+	}
+	return Equal
+}
+
+// Merge returns the element-wise maximum of c and other
+func (c VectorClock) Merge(other VectorClock) VectorClock {
+	result := c.Copy()
+	for k, v := range other {
+		if v > result[k] {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// Copy returns an independent copy of c
+func (c VectorClock) Copy() VectorClock {
+	result := make(VectorClock, len(c))
+	for k, v := range c {
+		result[k] = v
+	}
+	return result
+}
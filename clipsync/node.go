@@ -0,0 +1,404 @@
+// Package clipsync shares clipboard contents between machines.
+//
+// Every Node watches its local clipboard Backend, publishes changes to all connected peers
+// and applies the changes it receives. Peers authenticate each other with a pre-shared key,
+// which also authenticates every frame after the handshake. Connections can additionally
+// be secured with TLS to keep the contents confidential.
+//
+// Updates carry the id of the node they originated from and a vector clock,
+// so that updates relayed through several peers are applied once and never echoed back.
+package clipsync
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+// DefaultFormats are synchronized when Node.Formats is empty.
+// Formats referring to local resources, like CF_HDROP or GDI handles, can not be shared.
+var DefaultFormats = []string{
+	"CF_UNICODETEXT",
+	"HTML Format",
+	"Rich Text Format",
+	"PNG",
+	"CF_DIB",
+}
+
+const (
+	DefaultPollInterval  = 250 * time.Millisecond
+	DefaultMaxUpdateSize = 32 << 20
+)
+
+// Node is a single participant of the synchronization
+type Node struct {
+	// ID identifies the node, it has to be unique among all peers
+	ID      string
+	Backend clipboard.Backend
+	// Key is the pre-shared key all peers authenticate with
+	Key []byte
+	// TLSConfig enables TLS for Serve and Connect when set
+	TLSConfig *tls.Config
+	// Formats lists the format names to synchronize, empty means DefaultFormats
+	Formats []string
+	// PollInterval is how often the Backend is checked for changes
+	PollInterval time.Duration
+	// MaxUpdateSize limits the total data of a single update, in both directions
+	MaxUpdateSize int64
+	// Logf receives diagnostic messages, might be nil
+	Logf func(format string, args ...interface{})
+
+	mu       sync.Mutex
+	clock    VectorClock
+	current  *update
+	hash     [sha256.Size]byte
+	lastSeq  uint32
+	peers    map[*peer]struct{}
+	updateID uint64
+	// applying counts received updates being written to the Backend
+	applying int
+}
+
+func (n *Node) logf(format string, args ...interface{}) {
+	if n.Logf != nil {
+		n.Logf(format, args...)
+	}
+}
+
+func (n *Node) maxUpdateSize() int64 {
+	if n.MaxUpdateSize > 0 {
+		return n.MaxUpdateSize
+	}
+	return DefaultMaxUpdateSize
+}
+
+func (n *Node) formats() []string {
+	if len(n.Formats) > 0 {
+		return n.Formats
+	}
+	return DefaultFormats
+}
+
+// Run watches the Backend for changes and publishes them until ctx is done
+func (n *Node) Run(ctx context.Context) error {
+	if n.ID == "" || n.Backend == nil {
+		return errors.New("clipsync: Node requires an ID and a Backend")
+	}
+	interval := n.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := n.Poll(); err != nil {
+			n.logf("clipsync: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Poll checks the Backend once and publishes its contents if they changed
+func (n *Node) Poll() error {
+	seq, err := n.Backend.SequenceNumber()
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	unchanged := seq == n.lastSeq
+	n.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	formats, err := n.read()
+	if err != nil {
+		return err
+	}
+	hash := hashFormats(formats)
+
+	n.mu.Lock()
+	if n.applying > 0 {
+		// the change might be a received update, receive records its sequence number
+		n.mu.Unlock()
+		return nil
+	}
+	n.lastSeq = seq
+	// the contents were written by this node, or by a change that restored them
+	if hash == n.hash || len(formats) == 0 {
+		n.mu.Unlock()
+		return nil
+	}
+	if n.clock == nil {
+		n.clock = VectorClock{}
+	}
+	n.clock[n.ID]++
+	n.updateID++
+	u := &update{ID: n.updateID, Origin: n.ID, Clock: n.clock.Copy(), Formats: formats}
+	n.current, n.hash = u, hash
+	peers := n.peerList(nil)
+	n.mu.Unlock()
+
+	n.broadcast(peers, u)
+	return nil
+}
+
+// read returns the data of all configured formats available in the Backend
+func (n *Node) read() ([]FormatData, error) {
+	available, err := n.Backend.Formats()
+	if err != nil {
+		return nil, err
+	}
+	has := make(map[clipboard.Format]bool, len(available))
+	for _, id := range available {
+		has[id] = true
+	}
+
+	var result []FormatData
+	var total int64
+	for _, name := range n.formats() {
+		id, err := n.Backend.RegisterFormat(name)
+		if err != nil || !has[id] {
+			continue
+		}
+		left := n.maxUpdateSize() - total
+		if left <= 0 {
+			n.logf("clipsync: skipping %s. %v", name, ErrUpdateTooLarge)
+			continue
+		}
+		data, err := clipboard.ReadData(n.Backend, id, left)
+		if errors.Is(err, clipboard.ErrTooLarge) {
+			n.logf("clipsync: skipping %s, %v. %v", name, err, ErrUpdateTooLarge)
+			continue
+		} else if err != nil {
+			n.logf("clipsync: reading %s: %v", name, err)
+			continue
+		}
+		total += int64(len(data))
+		result = append(result, FormatData{Name: name, Data: data})
+	}
+	return result, nil
+}
+
+// receive applies an update from the peer from, relaying it to all other peers.
+// The Backend is written without holding n.mu, the update is only committed if it still wins afterwards.
+func (n *Node) receive(from *peer, u *update) error {
+	n.mu.Lock()
+	ok := n.accept(u)
+	if ok {
+		n.applying++
+	}
+	n.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	items := make([]clipboard.Item, 0, len(u.Formats))
+	var err error
+	for _, f := range u.Formats {
+		var id clipboard.Format
+		if id, err = n.Backend.RegisterFormat(f.Name); err != nil {
+			break
+		}
+		items = append(items, clipboard.Item{Format: id, Data: f.Data})
+	}
+	if err == nil {
+		err = n.Backend.Write(items)
+	}
+	seq, seqErr := n.Backend.SequenceNumber()
+
+	n.mu.Lock()
+	n.applying--
+	if err != nil {
+		n.mu.Unlock()
+		return err
+	}
+	// another update was applied in the meantime. The next Poll publishes whatever the Backend holds now,
+	// which supersedes both.
+	if !n.accept(u) {
+		n.mu.Unlock()
+		return nil
+	}
+	if seqErr == nil {
+		n.lastSeq = seq
+	}
+	n.clock = n.clock.Merge(u.Clock)
+	n.current, n.hash = u, hashFormats(u.Formats)
+	peers := n.peerList(from)
+	n.mu.Unlock()
+
+	n.broadcast(peers, u)
+	return nil
+}
+
+// accept reports whether u replaces the current contents, n.mu has to be held.
+// The clock still advances for concurrent updates that lose against the current contents.
+func (n *Node) accept(u *update) bool {
+	switch u.Clock.Compare(n.clock) {
+	case Before, Equal:
+		// already seen, e.g. relayed by another peer
+		return false
+	case Concurrent:
+		// changes made at the same time on different nodes, every node picks the same winner
+		if n.current != nil && u.Origin < n.current.Origin {
+			n.clock = n.clock.Merge(u.Clock)
+			return false
+		}
+	}
+	return true
+}
+
+func hashFormats(formats []FormatData) [sha256.Size]byte {
+	h := sha256.New()
+	for _, f := range formats {
+		fmt.Fprintf(h, "%s\x00%d\x00", f.Name, len(f.Data))
+		h.Write(f.Data)
+	}
+	var result [sha256.Size]byte
+	copy(result[:], h.Sum(nil))
+	return result
+}
+
+// peerList returns all peers except skip, n.mu has to be held
+func (n *Node) peerList(skip *peer) []*peer {
+	result := make([]*peer, 0, len(n.peers))
+	for p := range n.peers {
+		if p != skip {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func (n *Node) broadcast(peers []*peer, u *update) {
+	for _, p := range peers {
+		if err := p.send(u); err != nil {
+			n.logf("clipsync: sending to %s: %v", p.id, err)
+		}
+	}
+}
+
+// Serve accepts connections on l until ctx is done
+func (n *Node) Serve(ctx context.Context, l net.Listener) error {
+	if n.TLSConfig != nil {
+		l = tls.NewListener(l, n.TLSConfig)
+	}
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		go func() {
+			if err := n.HandleConn(ctx, conn); err != nil && ctx.Err() == nil {
+				n.logf("clipsync: %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve
+func (n *Node) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return n.Serve(ctx, l)
+}
+
+// Connect keeps a connection to the peer at the TCP address addr, reconnecting until ctx is done
+func (n *Node) Connect(ctx context.Context, addr string) error {
+	const maxBackoff = 30 * time.Second
+	backoff := time.Second
+	for {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			if n.TLSConfig != nil {
+				cfg := n.TLSConfig
+				if cfg.ServerName == "" {
+					cfg = cfg.Clone()
+					cfg.ServerName, _, _ = net.SplitHostPort(addr)
+				}
+				conn = tls.Client(conn, cfg)
+			}
+			start := time.Now()
+			err = n.HandleConn(ctx, conn)
+			if time.Since(start) > maxBackoff {
+				backoff = time.Second
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		n.logf("clipsync: %s: %v, retrying in %v", addr, err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// HandleConn authenticates the peer on conn and exchanges updates until the connection fails or ctx is done.
+// It can be used with any net.Conn, e.g. one end of net.Pipe.
+func (n *Node) HandleConn(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	p, err := handshake(conn, n.ID, n.Key)
+	if err != nil {
+		return err
+	}
+	defer p.close()
+	go p.writeLoop()
+
+	n.mu.Lock()
+	if n.peers == nil {
+		n.peers = map[*peer]struct{}{}
+	}
+	n.peers[p] = struct{}{}
+	current := n.current
+	n.mu.Unlock()
+	defer func() {
+		n.mu.Lock()
+		delete(n.peers, p)
+		n.mu.Unlock()
+	}()
+
+	// let the new peer catch up, it discards the update if it already knows it
+	if current != nil {
+		if err := p.send(current); err != nil {
+			return err
+		}
+	}
+	return p.readLoop(n)
+}
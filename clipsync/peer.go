@@ -0,0 +1,251 @@
+package clipsync
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// handshakeTimeout limits the time until both sides are authenticated
+const handshakeTimeout = 10 * time.Second
+
+// peer is an authenticated connection
+type peer struct {
+	id   string
+	conn net.Conn
+	// out is only used by writeLoop, in only by readLoop
+	out, in *frameMAC
+
+	mu sync.Mutex
+	// next is the update waiting to be written.
+	// Only the latest clipboard state matters, so a newer update replaces a waiting one.
+	next *update
+	wake chan struct{}
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newPeer(id string, conn net.Conn, out, in *frameMAC) *peer {
+	return &peer{
+		id:   id,
+		conn: conn,
+		out:  out,
+		in:   in,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+}
+
+func (p *peer) close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.conn.Close()
+	})
+}
+
+// handshake exchanges hello frames and proves knowledge of key in both directions.
+//
+// Each side sends HMAC-SHA256(key, sender id || receiver nonce || sender nonce).
+// Every later frame is authenticated with a key derived from the same values, per direction.
+func handshake(conn net.Conn, id string, key []byte) (*peer, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("no key configured. %w", ErrAuth)
+	}
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	local := &hello{NodeID: id, Nonce: make([]byte, nonceSize)}
+	if _, err := rand.Read(local.Nonce); err != nil {
+		return nil, err
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- writeFrame(conn, frameHello, local.encode()) }()
+
+	typ, payload, err := readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	if typ != frameHello {
+		return nil, fmt.Errorf("expected hello, got frame %d. %w", typ, ErrProtocol)
+	}
+	remote, err := decodeHello(payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	// a peer reflecting our own hello could replay our proof
+	if remote.NodeID == id || hmac.Equal(remote.Nonce, local.Nonce) {
+		return nil, fmt.Errorf("peer uses our own id or nonce. %w", ErrAuth)
+	}
+
+	go func() { errc <- writeFrame(conn, frameAuth, authMAC(key, id, remote.Nonce, local.Nonce)) }()
+	typ, payload, err = readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	if typ != frameAuth {
+		return nil, fmt.Errorf("expected auth, got frame %d. %w", typ, ErrProtocol)
+	}
+	if !hmac.Equal(payload, authMAC(key, remote.NodeID, local.Nonce, remote.Nonce)) {
+		return nil, fmt.Errorf("peer %s. %w", remote.NodeID, ErrAuth)
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	out := &frameMAC{key: sessionKey(key, id, remote.Nonce, local.Nonce)}
+	in := &frameMAC{key: sessionKey(key, remote.NodeID, local.Nonce, remote.Nonce)}
+	return newPeer(remote.NodeID, conn, out, in), nil
+}
+
+func authMAC(key []byte, sender string, receiverNonce, senderNonce []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	var n [2]byte
+	binary.BigEndian.PutUint16(n[:], uint16(len(sender)))
+	m.Write(n[:])
+	m.Write([]byte(sender))
+	m.Write(receiverNonce)
+	m.Write(senderNonce)
+	return m.Sum(nil)
+}
+
+// sessionKey derives the key authenticating the frames sent by sender.
+// Unlike the auth MAC it never goes over the wire.
+func sessionKey(key []byte, sender string, receiverNonce, senderNonce []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte("clipsync frames"))
+	m.Write(authMAC(key, sender, receiverNonce, senderNonce))
+	return m.Sum(nil)
+}
+
+// frameMAC authenticates the frames of one direction.
+// The MAC covers a frame counter, so dropped, replayed or reordered frames fail as well.
+type frameMAC struct {
+	key []byte
+	seq uint64
+}
+
+func (m *frameMAC) sum(typ byte, payload []byte) []byte {
+	h := hmac.New(sha256.New, m.key)
+	var b [9]byte
+	binary.BigEndian.PutUint64(b[:], m.seq)
+	b[8] = typ
+	h.Write(b[:])
+	h.Write(payload)
+	m.seq++
+	return h.Sum(nil)
+}
+
+// writeFrame writes a frame followed by its MAC
+func (p *peer) writeFrame(typ byte, payload []byte) error {
+	sealed := make([]byte, 0, len(payload)+frameMACSize)
+	sealed = append(sealed, payload...)
+	return writeFrame(p.conn, typ, append(sealed, p.out.sum(typ, payload)...))
+}
+
+// readFrame reads a frame and verifies its MAC
+func (p *peer) readFrame() (byte, []byte, error) {
+	typ, payload, err := readFrame(p.conn)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(payload) < frameMACSize {
+		return 0, nil, fmt.Errorf("frame without MAC. %w", ErrProtocol)
+	}
+	payload, mac := payload[:len(payload)-frameMACSize], payload[len(payload)-frameMACSize:]
+	if !hmac.Equal(mac, p.in.sum(typ, payload)) {
+		return 0, nil, fmt.Errorf("frame %d from %s. %w", typ, p.id, ErrAuth)
+	}
+	return typ, payload, nil
+}
+
+// send hands u to writeLoop, replacing an update that was not written yet
+func (p *peer) send(u *update) error {
+	select {
+	case <-p.done:
+		return net.ErrClosed
+	default:
+	}
+	p.mu.Lock()
+	p.next = u
+	p.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// writeLoop writes updates including all of their chunks until the peer is closed
+func (p *peer) writeLoop() {
+	for {
+		select {
+		case <-p.wake:
+		case <-p.done:
+			return
+		}
+		p.mu.Lock()
+		u := p.next
+		p.next = nil
+		p.mu.Unlock()
+		if u == nil {
+			continue
+		}
+		if err := writeUpdate(p.writeFrame, u); err != nil {
+			p.close()
+			return
+		}
+	}
+}
+
+// readLoop receives updates and hands them to n until the connection fails
+func (p *peer) readLoop(n *Node) error {
+	var pending *update
+	for {
+		typ, payload, err := p.readFrame()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case frameUpdate:
+			if pending != nil {
+				return fmt.Errorf("update %d is incomplete. %w", pending.ID, ErrProtocol)
+			}
+			u, err := decodeUpdateHeader(payload, n.maxUpdateSize())
+			if err != nil {
+				return err
+			}
+			if !u.complete() {
+				pending = u
+				continue
+			}
+			if err := n.receive(p, u); err != nil {
+				n.logf("clipsync: applying update from %s: %v", p.id, err)
+			}
+		case frameChunk:
+			if pending == nil || len(payload) < 8 || binary.BigEndian.Uint64(payload) != pending.ID {
+				return fmt.Errorf("unexpected chunk. %w", ErrProtocol)
+			}
+			done, err := pending.fill(payload[8:])
+			if err != nil {
+				return err
+			}
+			if done {
+				u := pending
+				pending = nil
+				if err := n.receive(p, u); err != nil {
+					n.logf("clipsync: applying update from %s: %v", p.id, err)
+				}
+			}
+		default:
+			return fmt.Errorf("unexpected frame %d. %w", typ, ErrProtocol)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package clipsync
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ProtocolVersion is sent in every frame header.
+// Peers close the connection on frames of a different version.
+const ProtocolVersion = 2
+
+var frameMagic = [4]byte{'W', 'C', 'S', 'Y'}
+
+// frame types
+const (
+	frameHello  = 1
+	frameAuth   = 2
+	frameUpdate = 3
+	frameChunk  = 4
+)
+
+const (
+	// frameHeaderSize is magic, version, type and payload length
+	frameHeaderSize = 4 + 1 + 1 + 4
+	// maxControlFrame limits every frame but chunks
+	maxControlFrame = 64 << 10
+	// ChunkSize is the largest amount of format data sent in a single frame
+	ChunkSize = 64 << 10
+	nonceSize = 32
+	// frameMACSize is the length of the HMAC-SHA256 appended to every frame after the handshake
+	frameMACSize = sha256.Size
+)
+
+var (
+	ErrProtocol       = errors.New("clipsync: protocol violation")
+	ErrVersion        = errors.New("clipsync: unsupported protocol version")
+	ErrAuth           = errors.New("clipsync: authentication failed")
+	ErrUpdateTooLarge = errors.New("clipsync: update exceeds size limit")
+)
+
+func writeFrame(w io.Writer, typ byte, payload []byte) error {
+	buf := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	copy(buf, frameMagic[:])
+	buf[4] = ProtocolVersion
+	buf[5] = typ
+	binary.BigEndian.PutUint32(buf[6:], uint32(len(payload)))
+	_, err := w.Write(append(buf, payload...))
+	return err
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	hdr := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return 0, nil, err
+	}
+	if [4]byte{hdr[0], hdr[1], hdr[2], hdr[3]} != frameMagic {
+		return 0, nil, fmt.Errorf("bad magic. %w", ErrProtocol)
+	}
+	if hdr[4] != ProtocolVersion {
+		return 0, nil, fmt.Errorf("version %d. %w", hdr[4], ErrVersion)
+	}
+	typ := hdr[5]
+	size := binary.BigEndian.Uint32(hdr[6:])
+	limit := uint32(maxControlFrame + frameMACSize)
+	if typ == frameChunk {
+		limit = ChunkSize + 8 + frameMACSize
+	}
+	if size > limit {
+		return 0, nil, fmt.Errorf("frame of %d bytes. %w", size, ErrProtocol)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return typ, payload, nil
+}
+
+// encoder appends big-endian values
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) uint16(v uint16) { e.b = append(e.b, byte(v>>8), byte(v)) }
+func (e *encoder) uint64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	e.b = append(e.b, b[:]...)
+}
+func (e *encoder) string(s string) {
+	e.uint16(uint16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+// decoder consumes big-endian values, remembering the first error
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.b) {
+		d.err = fmt.Errorf("unexpected end of frame. %w", ErrProtocol)
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+func (d *decoder) uint16() uint16 {
+	if b := d.take(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+func (d *decoder) uint64() uint64 {
+	if b := d.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+func (d *decoder) string() string {
+	return string(d.take(int(d.uint16())))
+}
+
+// hello is the first frame of both sides
+type hello struct {
+	NodeID string
+	Nonce  []byte
+}
+
+func (m *hello) encode() []byte {
+	e := &encoder{}
+	e.string(m.NodeID)
+	e.b = append(e.b, m.Nonce...)
+	return e.b
+}
+
+func decodeHello(b []byte) (*hello, error) {
+	d := &decoder{b: b}
+	m := &hello{NodeID: d.string(), Nonce: d.take(nonceSize)}
+	return m, d.err
+}
+
+// FormatData is the data of a single clipboard format, identified by name
+type FormatData struct {
+	Name string
+	Data []byte
+}
+
+// update announces new clipboard contents, its data follows in chunk frames
+type update struct {
+	ID      uint64
+	Origin  string
+	Clock   VectorClock
+	Formats []FormatData
+}
+
+// encodeHeader encodes everything but the format data
+func (u *update) encodeHeader() []byte {
+	e := &encoder{}
+	e.uint64(u.ID)
+	e.string(u.Origin)
+
+	nodes := make([]string, 0, len(u.Clock))
+	for k := range u.Clock {
+		nodes = append(nodes, k)
+	}
+	sort.Strings(nodes)
+	e.uint16(uint16(len(nodes)))
+	for _, k := range nodes {
+		e.string(k)
+		e.uint64(u.Clock[k])
+	}
+
+	e.uint16(uint16(len(u.Formats)))
+	for _, f := range u.Formats {
+		e.string(f.Name)
+		e.uint64(uint64(len(f.Data)))
+	}
+	return e.b
+}
+
+// decodeUpdateHeader returns the update with allocated but empty format data
+func decodeUpdateHeader(b []byte, maxSize int64) (*update, error) {
+	d := &decoder{b: b}
+	u := &update{ID: d.uint64(), Origin: d.string(), Clock: VectorClock{}}
+	for n := d.uint16(); n > 0 && d.err == nil; n-- {
+		k := d.string()
+		u.Clock[k] = d.uint64()
+	}
+	var total uint64
+	for n := d.uint16(); n > 0 && d.err == nil; n-- {
+		name := d.string()
+		size := d.uint64()
+		total += size
+		if total > uint64(maxSize) || size > uint64(maxSize) {
+			return nil, fmt.Errorf("%d bytes. %w", total, ErrUpdateTooLarge)
+		}
+		u.Formats = append(u.Formats, FormatData{Name: name, Data: make([]byte, 0, size)})
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return u, nil
+}
+
+// writeUpdate sends the update header followed by its data in chunks through write
+func writeUpdate(write func(typ byte, payload []byte) error, u *update) error {
+	if err := write(frameUpdate, u.encodeHeader()); err != nil {
+		return err
+	}
+	chunk := make([]byte, 8, 8+ChunkSize)
+	binary.BigEndian.PutUint64(chunk, u.ID)
+	for _, f := range u.Formats {
+		data := f.Data
+		for len(data) > 0 {
+			n := len(data)
+			if room := ChunkSize - (len(chunk) - 8); n > room {
+				n = room
+			}
+			chunk = append(chunk, data[:n]...)
+			data = data[n:]
+			if len(chunk)-8 == ChunkSize {
+				if err := write(frameChunk, chunk); err != nil {
+					return err
+				}
+				chunk = chunk[:8]
+			}
+		}
+	}
+	if len(chunk) > 8 {
+		return write(frameChunk, chunk)
+	}
+	return nil
+}
+
+// fill appends chunk data to the formats and reports whether the update is complete
+func (u *update) fill(data []byte) (bool, error) {
+	for i := range u.Formats {
+		f := &u.Formats[i]
+		room := cap(f.Data) - len(f.Data)
+		if room == 0 {
+			continue
+		}
+		n := len(data)
+		if n > room {
+			n = room
+		}
+		f.Data = append(f.Data, data[:n]...)
+		data = data[n:]
+		if len(data) == 0 {
+			break
+		}
+	}
+	if len(data) > 0 {
+		return false, fmt.Errorf("chunk exceeds announced size. %w", ErrProtocol)
+	}
+	return u.complete(), nil
+}
+
+func (u *update) complete() bool {
+	for _, f := range u.Formats {
+		if len(f.Data) != cap(f.Data) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	clipboard "github.com/kirides/go-winclipboard"
+	"github.com/kirides/go-winclipboard/audit"
+)
+
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	verify := fs.Bool("verify", false, "check the hash chain of the log instead of recording")
+	maxSize := fs.Int64("max-size", audit.DefaultMaxSize, "rotate the log after this many bytes, negative to disable")
+	maxBackups := fs.Int("max-backups", 0, "number of rotated files to keep, 0 keeps all")
+	content := fs.Bool("content", false, "store the clipboard contents, not just their hashes")
+	keyFile := fs.String("key-file", "", "file containing the key for HMAC-SHA256 record hashes, keep it outside the log directory")
+	opts := readOptionsFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: audit [flags] <log file>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a log file")
+	}
+	path := fs.Arg(0)
+	var key []byte
+	if *keyFile != "" {
+		b, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		key = bytes.TrimSpace(b)
+	}
+
+	if *verify {
+		n, err := audit.VerifyLog(path, audit.VerifyOptions{Key: key})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d records are valid\n", n)
+		return nil
+	}
+
+	backend, err := systemBackend()
+	if err != nil {
+		return err
+	}
+	l, err := audit.Open(path, audit.Options{MaxSize: *maxSize, MaxBackups: *maxBackups, IncludeContent: *content, Key: key})
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var recordErr error
+	err = clipboard.NewPoller(backend).Run(ctx, func(c clipboard.Change) {
+		s, err := clipboard.TakeSnapshotWithOptions(backend, *opts)
+		if err != nil {
+			log.Printf("audit: %v", err)
+			return
+		}
+		// a record that can not be written ends auditing, the log must not silently miss changes
+		if recordErr = l.Record(s, ownerProcess()); recordErr != nil {
+			cancel()
+		}
+	})
+	if recordErr != nil {
+		return recordErr
+	}
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
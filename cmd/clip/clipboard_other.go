@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"errors"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+var errUnsupported = errors.New("the Windows clipboard is not available on this platform")
+
+func setText(text string) error {
+	return errUnsupported
+}
+
+func systemBackend() (clipboard.Backend, error) {
+	return nil, errUnsupported
+}
+
+func ownerProcess() string {
+	return ""
+}
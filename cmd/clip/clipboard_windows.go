@@ -0,0 +1,19 @@
+package main
+
+import (
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+// setText replaces the clipboard contents with text, the system backend owns the clipboard through its own window
+func setText(text string) error {
+	return clipboard.SystemBackend().Write([]clipboard.Item{{Format: clipboard.CF_UNICODETEXT, Data: clipboard.EncodeUnicodeText(text)}})
+}
+
+func systemBackend() (clipboard.Backend, error) {
+	return clipboard.SystemBackend(), nil
+}
+
+func ownerProcess() string {
+	p, _ := clipboard.OwnerProcess()
+	return p
+}
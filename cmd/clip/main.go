@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"emit", "copy stdin to the clipboard of the terminal via OSC 52", runEmit},
+	{"listen", "apply OSC 52 sequences from stdin to the Windows clipboard, forwarding all other output to stdout", runListen},
+	{"sync", "share the clipboard with other machines", runSync},
+	{"snapshot", "write all clipboard formats as JSON", runSnapshot},
+	{"diff", "compare a snapshot with another one or the current clipboard", runDiff},
+	{"audit", "log every clipboard change to a hash-chained file, or verify one", runAudit},
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-8s %s\n", c.name, c.usage)
+	}
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == flag.Arg(0) {
+			if err := c.run(flag.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", c.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
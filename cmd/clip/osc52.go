@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kirides/go-winclipboard/osc52"
+)
+
+func runEmit(args []string) error {
+	fs := flag.NewFlagSet("emit", flag.ExitOnError)
+	targets := fs.String("targets", osc52.TargetClipboard, "selection targets (c, p, q, s, 0-7)")
+	wrap := fs.String("wrap", "auto", "passthrough wrapping: auto, none, tmux or screen")
+	st := fs.Bool("st", false, "terminate with ST instead of BEL")
+	maxPayload := fs.Int("max", 0, "maximum base64 payload length, 0 for no limit")
+	fs.Parse(args)
+
+	enc := osc52.Encoder{MaxPayload: *maxPayload}
+	if *st {
+		enc.Terminator = osc52.ST
+	}
+	switch *wrap {
+	case "auto":
+		enc.Wrap = detectWrap()
+	case "none":
+		enc.Wrap = osc52.WrapNone
+	case "tmux":
+		enc.Wrap = osc52.WrapTmux
+	case "screen":
+		enc.Wrap = osc52.WrapScreen
+	default:
+		return fmt.Errorf("unknown wrap %q", *wrap)
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	seq, err := enc.Encode(osc52.Sequence{Targets: *targets, Data: data})
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(seq)
+	return err
+}
+
+// detectWrap picks the wrapping for the multiplexer the process runs in
+func detectWrap() osc52.Wrap {
+	if os.Getenv("TMUX") != "" {
+		return osc52.WrapTmux
+	}
+	if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		return osc52.WrapScreen
+	}
+	return osc52.WrapNone
+}
+
+func runListen(args []string) error {
+	fs := flag.NewFlagSet("listen", flag.ExitOnError)
+	maxPayload := fs.Int("max", osc52.DefaultMaxPayload, "maximum base64 payload length")
+	fs.Parse(args)
+
+	r := osc52.NewReader(os.Stdin, os.Stdout)
+	r.MaxPayload = *maxPayload
+	for {
+		seq, err := r.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if errors.Is(err, osc52.ErrInvalidSequence) || errors.Is(err, osc52.ErrTooLarge) {
+				fmt.Fprintf(os.Stderr, "listen: %v\n", err)
+				continue
+			}
+			return err
+		}
+		// queries can not be answered, the response would have to be written to the remote side
+		if seq.Query || !seq.HasTarget('c') {
+			continue
+		}
+		if err := setText(string(seq.Data)); err != nil {
+			fmt.Fprintf(os.Stderr, "listen: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+// readOptionsFlags adds the flags limiting clipboard reads to fs
+func readOptionsFlags(fs *flag.FlagSet) *clipboard.ReadOptions {
+	opts := clipboard.DefaultReadOptions()
+	fs.Int64Var(&opts.MaxFormatSize, "max-format-size", opts.MaxFormatSize, "formats larger than this many bytes are omitted, 0 for no limit")
+	fs.Int64Var(&opts.MaxTotalSize, "max-total-size", opts.MaxTotalSize, "bytes read in total, further formats are omitted, 0 for no limit")
+	return &opts
+}
+
+func runSnapshot(args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	out := fs.String("o", "", "file to write the snapshot to, stdout if empty")
+	opts := readOptionsFlags(fs)
+	fs.Parse(args)
+
+	s, err := takeSnapshot(*opts)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = fmt.Printf("%s\n", data)
+		return err
+	}
+	return os.WriteFile(*out, data, 0600)
+}
+
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "write the difference as JSON")
+	opts := readOptionsFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: diff [flags] <old snapshot> [new snapshot]\n\nWithout a new snapshot, the current clipboard is compared.\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return errors.New("expected one or two snapshot files")
+	}
+
+	a, err := readSnapshot(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var b *clipboard.Snapshot
+	if fs.NArg() == 2 {
+		b, err = readSnapshot(fs.Arg(1))
+	} else {
+		b, err = takeSnapshot(*opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	d := clipboard.Diff(a, b)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+	_, err = fmt.Print(d)
+	return err
+}
+
+func takeSnapshot(opts clipboard.ReadOptions) (*clipboard.Snapshot, error) {
+	backend, err := systemBackend()
+	if err != nil {
+		return nil, err
+	}
+	return clipboard.TakeSnapshotWithOptions(backend, opts)
+}
+
+func readSnapshot(path string) (*clipboard.Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s clipboard.Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/kirides/go-winclipboard/clipsync"
+)
+
+func runSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	hostname, _ := os.Hostname()
+	id := fs.String("id", hostname, "unique id of this machine")
+	listen := fs.String("listen", "", "TCP address to accept peers on, e.g. :7070")
+	peers := fs.String("peers", "", "comma separated TCP addresses of peers to connect to")
+	keyFile := fs.String("key-file", "", "file containing the pre-shared key")
+	certFile := fs.String("tls-cert", "", "PEM certificate, enables TLS")
+	certKeyFile := fs.String("tls-key", "", "PEM private key of -tls-cert")
+	caFile := fs.String("tls-ca", "", "PEM certificates peers have to present a certificate of")
+	formats := fs.String("formats", strings.Join(clipsync.DefaultFormats, ","), "comma separated format names to synchronize")
+	fs.Parse(args)
+
+	if *keyFile == "" {
+		return errors.New("-key-file is required")
+	}
+	key, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	backend, err := systemBackend()
+	if err != nil {
+		return err
+	}
+
+	n := &clipsync.Node{
+		ID:      *id,
+		Backend: backend,
+		Key:     bytes.TrimSpace(key),
+		Formats: strings.Split(*formats, ","),
+		Logf:    log.Printf,
+	}
+	if *certFile != "" {
+		if n.TLSConfig, err = loadTLSConfig(*certFile, *certKeyFile, *caFile); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if *listen != "" {
+		go func() {
+			if err := n.ListenAndServe(ctx, *listen); err != nil && ctx.Err() == nil {
+				log.Printf("sync: %v", err)
+				cancel()
+			}
+		}()
+	}
+	for _, addr := range strings.Split(*peers, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			go n.Connect(ctx, addr)
+		}
+	}
+	if err := n.Run(ctx); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func loadTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+		cfg.RootCAs = pool
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package main
 
 import (
@@ -10,7 +13,7 @@ import (
 	clipboard "github.com/kirides/go-winclipboard"
 	"github.com/kirides/go-winclipboard/wm"
 
-	"github.com/kirides/hwnd-go"
+	"github.com/kirides/go-winclipboard/hwnd"
 )
 
 func main() {
@@ -34,7 +37,7 @@ func main() {
 			}
 		}
 
-		fmt.Printf("WndProc(%v, %s, %v, %v)\n", h, wm.String(msg), wParam, lParam)
+		fmt.Printf("WndProc(%v, %s)\n", h, wm.Describe(msg, wParam, lParam))
 
 		// Should always call hwnd.DefWindowProc if not handled otherwise
 		// refer to general Win32 API programming
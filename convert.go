@@ -0,0 +1,279 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+var ErrNoConversion = errors.New("no conversion available")
+
+// Converter converts the data of one clipboard format into another, both referred to by name
+type Converter struct {
+	From, To string
+	// Cost rates the conversion, lossy conversions should cost more than lossless ones
+	Cost    int
+	Convert func(data []byte) ([]byte, error)
+}
+
+// Names of registered image formats
+const (
+	PNGFormatName  = "PNG"
+	GIFFormatName  = "GIF"
+	JFIFFormatName = "JFIF"
+)
+
+var (
+	convertersMu sync.RWMutex
+	converters   = []Converter{
+		{From: "CF_UNICODETEXT", To: "CF_TEXT", Cost: 1, Convert: encodeCodePageText(charmap.Windows1252)},
+		{From: "CF_UNICODETEXT", To: "CF_OEMTEXT", Cost: 1, Convert: encodeCodePageText(charmap.CodePage437)},
+		{From: "CF_TEXT", To: "CF_UNICODETEXT", Cost: 1, Convert: decodeCodePageText(charmap.Windows1252)},
+		{From: "CF_OEMTEXT", To: "CF_UNICODETEXT", Cost: 1, Convert: decodeCodePageText(charmap.CodePage437)},
+		{From: HTMLFormatName, To: "CF_UNICODETEXT", Cost: 4, Convert: htmlToUnicodeText},
+		{From: RTFFormatName, To: "CF_UNICODETEXT", Cost: 4, Convert: rtfToUnicodeText},
+		{From: "CF_HDROP", To: "CF_UNICODETEXT", Cost: 4, Convert: dropFilesToUnicodeText},
+
+		{From: "CF_DIBV5", To: "CF_DIB", Cost: 1, Convert: convertImage(decodeDIB, encodeDIB)},
+		{From: "CF_DIB", To: "CF_DIBV5", Cost: 1, Convert: convertImage(decodeDIB, encodeDIBV5)},
+		{From: "CF_DIBV5", To: PNGFormatName, Cost: 2, Convert: convertImage(decodeDIB, png.Encode)},
+		{From: "CF_DIB", To: PNGFormatName, Cost: 2, Convert: convertImage(decodeDIB, png.Encode)},
+		{From: PNGFormatName, To: "CF_DIBV5", Cost: 2, Convert: convertImage(png.Decode, encodeDIBV5)},
+		// CF_DIB drops transparency
+		{From: PNGFormatName, To: "CF_DIB", Cost: 3, Convert: convertImage(png.Decode, encodeDIB)},
+		{From: "image/png", To: PNGFormatName, Cost: 1, Convert: func(data []byte) ([]byte, error) { return data, nil }},
+		{From: GIFFormatName, To: PNGFormatName, Cost: 2, Convert: convertImage(gif.Decode, png.Encode)},
+		{From: JFIFFormatName, To: PNGFormatName, Cost: 2, Convert: convertImage(jpeg.Decode, png.Encode)},
+	}
+)
+
+// RegisterConverter adds c, replacing an existing converter between the same formats
+func RegisterConverter(c Converter) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	for i, v := range converters {
+		if v.From == c.From && v.To == c.To {
+			converters[i] = c
+			return
+		}
+	}
+	converters = append(converters, c)
+}
+
+// Converters returns all registered converters
+func Converters() []Converter {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	return append([]Converter(nil), converters...)
+}
+
+// ConversionPath returns the cheapest chain of converters from one of the available formats to target.
+// The chain is empty if target is available itself.
+func ConversionPath(available []string, target string) ([]Converter, error) {
+	edges := Converters()
+
+	type node struct {
+		cost int
+		via  *Converter
+		done bool
+	}
+	nodes := map[string]*node{}
+	for _, name := range available {
+		nodes[name] = &node{}
+	}
+	// Dijkstra, the graph is far too small to need a priority queue
+	for {
+		var name string
+		var cur *node
+		for k, n := range nodes {
+			if !n.done && (cur == nil || n.cost < cur.cost || n.cost == cur.cost && k < name) {
+				name, cur = k, n
+			}
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("%s from %v. %w", target, available, ErrNoConversion)
+		}
+		if name == target {
+			break
+		}
+		cur.done = true
+		for i := range edges {
+			e := &edges[i]
+			if e.From != name {
+				continue
+			}
+			n, ok := nodes[e.To]
+			if !ok {
+				n = &node{cost: -1}
+				nodes[e.To] = n
+			}
+			if !n.done && (n.cost < 0 || cur.cost+e.Cost < n.cost) {
+				n.cost, n.via = cur.cost+e.Cost, e
+			}
+		}
+	}
+
+	var path []Converter
+	for n := nodes[target]; n.via != nil; n = nodes[n.via.From] {
+		path = append([]Converter{*n.via}, path...)
+	}
+	return path, nil
+}
+
+// runConversion applies every converter of path to data
+func runConversion(path []Converter, data []byte) ([]byte, error) {
+	for _, c := range path {
+		var err error
+		if data, err = c.Convert(data); err != nil {
+			return nil, fmt.Errorf("converting %s to %s. %w", c.From, c.To, err)
+		}
+	}
+	return data, nil
+}
+
+// GetAsFrom returns the data of the format target from b, converting it from the cheapest available format.
+//
+// target might also be a MIME type, which is converted from its clipboard format, see ToMIME.
+func GetAsFrom(b Backend, target string) ([]byte, error) {
+	if strings.Contains(target, "/") {
+		if format, ok := FormatOfMIMEType(target); ok {
+			data, err := GetAsFrom(b, format)
+			if err != nil {
+				return nil, err
+			}
+			_, data, err = ToMIME(format, data)
+			return data, err
+		}
+	}
+
+	ids, err := b.Formats()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(ids))
+	byName := make(map[string]Format, len(ids))
+	for _, id := range ids {
+		name, err := b.FormatName(id)
+		if err != nil {
+			continue
+		}
+		names = append(names, name)
+		byName[name] = id
+	}
+
+	path, err := ConversionPath(names, target)
+	if err != nil {
+		return nil, err
+	}
+	source := target
+	if len(path) > 0 {
+		source = path[0].From
+	}
+	data, err := b.Data(byName[source])
+	if err != nil {
+		return nil, err
+	}
+	return runConversion(path, data)
+}
+
+// encodeCodePageText converts CF_UNICODETEXT into NUL terminated text of a code page,
+// replacing characters it can not represent with '?' like Windows does
+func encodeCodePageText(cm *charmap.Charmap) func([]byte) ([]byte, error) {
+	return func(data []byte) ([]byte, error) {
+		text := DecodeUnicodeText(data)
+		result := make([]byte, 0, len(text)+1)
+		for _, r := range text {
+			b, ok := cm.EncodeRune(r)
+			if !ok {
+				b = '?'
+			}
+			result = append(result, b)
+		}
+		return append(result, 0), nil
+	}
+}
+
+// decodeCodePageText converts NUL terminated text of a code page into CF_UNICODETEXT
+func decodeCodePageText(cm *charmap.Charmap) func([]byte) ([]byte, error) {
+	return func(data []byte) ([]byte, error) {
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			data = data[:i]
+		}
+		result, err := cm.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, err
+		}
+		return EncodeUnicodeText(string(result)), nil
+	}
+}
+
+// crlf converts line breaks to CRLF, as expected in text formats
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
+
+func htmlToUnicodeText(data []byte) ([]byte, error) {
+	h, err := ParseHTML(data)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeUnicodeText(crlf(HTMLToText(h.Fragment))), nil
+}
+
+func rtfToUnicodeText(data []byte) ([]byte, error) {
+	text, err := RTFToText(data)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeUnicodeText(crlf(strings.TrimRight(text, "\n"))), nil
+}
+
+func dropFilesToUnicodeText(data []byte) ([]byte, error) {
+	paths, err := DecodeDropFiles(data)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeUnicodeText(strings.Join(paths, "\r\n")), nil
+}
+
+func decodeDIB(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeDIB(data)
+}
+
+func encodeDIB(w io.Writer, img image.Image) error {
+	_, err := w.Write(EncodeDIB(img))
+	return err
+}
+
+func encodeDIBV5(w io.Writer, img image.Image) error {
+	_, err := w.Write(EncodeDIBV5(img))
+	return err
+}
+
+// convertImage decodes data and encodes the resulting image
+func convertImage(decode func(io.Reader) (image.Image, error), encode func(io.Writer, image.Image) error) func([]byte) ([]byte, error) {
+	return func(data []byte) ([]byte, error) {
+		img, err := decodeImage(decode)(data)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := encode(&buf, img); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
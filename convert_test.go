@@ -0,0 +1,127 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// appendConverter appends the last letter of its target, so the data records the path taken
+func appendConverter(from, to string, cost int) Converter {
+	return Converter{From: from, To: to, Cost: cost, Convert: func(data []byte) ([]byte, error) {
+		return append(data, to[len(to)-1]), nil
+	}}
+}
+
+func init() {
+	// A -> B -> C is cheaper than A -> C, B -> A and C -> A close cycles
+	for _, c := range []Converter{
+		appendConverter("Test A", "Test B", 1),
+		appendConverter("Test B", "Test C", 1),
+		appendConverter("Test A", "Test C", 5),
+		appendConverter("Test B", "Test A", 1),
+		appendConverter("Test C", "Test A", 1),
+		appendConverter("Test C", "Test E", 1),
+		{From: "Test A", To: "Test F", Cost: 1, Convert: func([]byte) ([]byte, error) { return nil, errors.New("broken") }},
+	} {
+		RegisterConverter(c)
+	}
+}
+
+func TestConversionPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		available []string
+		target    string
+		// want lists the formats along the path, nil if there is none
+		want []string
+	}{
+		{"available", []string{"Test A", "Test C"}, "Test C", []string{"Test C"}},
+		{"shortest", []string{"Test A"}, "Test C", []string{"Test A", "Test B", "Test C"}},
+		{"cheapest source", []string{"Test A", "Test B"}, "Test C", []string{"Test B", "Test C"}},
+		{"through cycle", []string{"Test B"}, "Test E", []string{"Test B", "Test C", "Test E"}},
+		{"back", []string{"Test C"}, "Test B", []string{"Test C", "Test A", "Test B"}},
+		{"unreachable", []string{"Test A"}, "Test D", nil},
+		{"unreachable in cycle", []string{"Test E"}, "Test A", nil},
+		{"nothing available", nil, "Test A", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := ConversionPath(tt.available, tt.target)
+			if tt.want == nil {
+				if !errors.Is(err, ErrNoConversion) {
+					t.Errorf("got %v, %v, want ErrNoConversion", path, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{tt.target}
+			if len(path) > 0 {
+				got = []string{path[0].From}
+				for i, c := range path {
+					if i > 0 && path[i-1].To != c.From {
+						t.Fatalf("path is not connected: %v", path)
+					}
+					got = append(got, c.To)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAsFrom(t *testing.T) {
+	b := NewMemoryBackend()
+	a, err := b.RegisterFormat("Test A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Write([]Item{{Format: a, Data: []byte("x")}, {Format: CF_UNICODETEXT, Data: EncodeUnicodeText("ä€")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target string
+		want   []byte
+	}{
+		{"Test A", []byte("x")},
+		{"Test C", []byte("xBC")},
+		{"Test E", []byte("xBCE")},
+		// Windows-1252 has the euro sign
+		{"CF_TEXT", []byte{0xE4, 0x80, 0}},
+		{"CF_OEMTEXT", []byte{0x84, '?', 0}},
+	}
+	for _, tt := range tests {
+		data, err := GetAsFrom(b, tt.target)
+		if err != nil {
+			t.Errorf("%s: %v", tt.target, err)
+		} else if !bytes.Equal(data, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.target, data, tt.want)
+		}
+	}
+
+	if _, err := GetAsFrom(b, "Test D"); !errors.Is(err, ErrNoConversion) {
+		t.Errorf("unreachable: got %v, want ErrNoConversion", err)
+	}
+	if _, err := GetAsFrom(b, "Test F"); err == nil || err.Error() != "converting Test A to Test F. broken" {
+		t.Errorf("failing converter: got %v", err)
+	}
+}
+
+func TestRegisterConverterReplaces(t *testing.T) {
+	count := len(Converters())
+	RegisterConverter(appendConverter("Test G", "Test H", 1))
+	RegisterConverter(appendConverter("Test G", "Test H", 7))
+	if n := len(Converters()); n != count+1 {
+		t.Fatalf("%d converters after registering one twice, want %d", n, count+1)
+	}
+	path, err := ConversionPath([]string{"Test G"}, "Test H")
+	if err != nil || len(path) != 1 || path[0].Cost != 7 {
+		t.Errorf("got %v, %v, want the replacement", path, err)
+	}
+}
@@ -0,0 +1,294 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+)
+
+var ErrInvalidDIB = errors.New("invalid device independent bitmap")
+
+const (
+	// bitmapFileHeaderSize is the size of a BITMAPFILEHEADER, the only difference between CF_DIB and a .bmp file
+	bitmapFileHeaderSize = 14
+	bitmapInfoHeaderSize = 40
+	bitmapV5HeaderSize   = 124
+
+	_BI_RGB            = 0
+	_BI_RLE8           = 1
+	_BI_RLE4           = 2
+	_BI_BITFIELDS      = 3
+	_BI_JPEG           = 4
+	_BI_PNG            = 5
+	_BI_ALPHABITFIELDS = 6
+
+	_LCS_sRGB      = 0x73524742
+	_LCS_GM_IMAGES = 4
+)
+
+// dibPixelOffset returns the offset of the pixel data in CF_DIB data,
+// following the header, color masks and color table
+func dibPixelOffset(dib []byte) (int, error) {
+	if len(dib) < bitmapInfoHeaderSize {
+		return 0, fmt.Errorf("%d bytes are too short for BITMAPINFOHEADER. %w", len(dib), ErrInvalidDIB)
+	}
+	size := binary.LittleEndian.Uint32(dib)
+	if size < bitmapInfoHeaderSize || uint64(size) > uint64(len(dib)) {
+		return 0, fmt.Errorf("header size %d out of range. %w", size, ErrInvalidDIB)
+	}
+	bitCount := binary.LittleEndian.Uint16(dib[14:])
+	compression := binary.LittleEndian.Uint32(dib[16:])
+	clrUsed := binary.LittleEndian.Uint32(dib[32:])
+
+	offset := uint64(size)
+	if size == bitmapInfoHeaderSize {
+		// the color masks follow a plain BITMAPINFOHEADER
+		switch compression {
+		case _BI_BITFIELDS:
+			offset += 12
+		case _BI_ALPHABITFIELDS:
+			offset += 16
+		}
+	}
+	switch {
+	case clrUsed != 0:
+		offset += uint64(clrUsed) * 4
+	case bitCount <= 8:
+		offset += (1 << bitCount) * 4
+	}
+	if offset > uint64(len(dib)) {
+		return 0, fmt.Errorf("color table exceeds %d bytes. %w", len(dib), ErrInvalidDIB)
+	}
+	return int(offset), nil
+}
+
+// DIBToBMP converts CF_DIB data into the contents of a .bmp file (image/bmp)
+func DIBToBMP(dib []byte) ([]byte, error) {
+	offset, err := dibPixelOffset(dib)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, bitmapFileHeaderSize+len(dib))
+	buf[0], buf[1] = 'B', 'M'
+	binary.LittleEndian.PutUint32(buf[2:], uint32(len(buf)))
+	binary.LittleEndian.PutUint32(buf[10:], uint32(bitmapFileHeaderSize+offset))
+	copy(buf[bitmapFileHeaderSize:], dib)
+	return buf, nil
+}
+
+// BMPToDIB converts the contents of a .bmp file (image/bmp) into CF_DIB data
+func BMPToDIB(bmp []byte) ([]byte, error) {
+	if len(bmp) < bitmapFileHeaderSize || bmp[0] != 'B' || bmp[1] != 'M' {
+		return nil, fmt.Errorf("missing BITMAPFILEHEADER. %w", ErrInvalidDIB)
+	}
+	dib := bmp[bitmapFileHeaderSize:]
+	offset, err := dibPixelOffset(dib)
+	if err != nil {
+		return nil, err
+	}
+	bits := binary.LittleEndian.Uint32(bmp[10:])
+	if uint64(bits) < uint64(bitmapFileHeaderSize+offset) || uint64(bits) > uint64(len(bmp)) {
+		return nil, fmt.Errorf("pixel offset %d out of range. %w", bits, ErrInvalidDIB)
+	}
+	// files might contain a gap between the color table and the pixels, CF_DIB does not
+	result := make([]byte, 0, offset+len(bmp)-int(bits))
+	result = append(result, dib[:offset]...)
+	result = append(result, bmp[bits:]...)
+	return result, nil
+}
+
+// DecodeDIB decodes CF_DIB or CF_DIBV5 data.
+//
+// Uncompressed bitmaps of 1, 4, 8, 16, 24 and 32 bits per pixel are supported,
+// as well as embedded JPEG and PNG images. Run-length encoded bitmaps are not.
+func DecodeDIB(dib []byte) (image.Image, error) {
+	offset, err := dibPixelOffset(dib)
+	if err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(dib)
+	width := int32(binary.LittleEndian.Uint32(dib[4:]))
+	height := int32(binary.LittleEndian.Uint32(dib[8:]))
+	bitCount := binary.LittleEndian.Uint16(dib[14:])
+	compression := binary.LittleEndian.Uint32(dib[16:])
+	pixels := dib[offset:]
+
+	switch compression {
+	case _BI_JPEG, _BI_PNG:
+		if err := checkImageSize(pixels); err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(bytes.NewReader(pixels))
+		return img, err
+	case _BI_RGB, _BI_BITFIELDS, _BI_ALPHABITFIELDS:
+	default:
+		return nil, fmt.Errorf("unsupported compression %d. %w", compression, ErrInvalidDIB)
+	}
+	// checked before allocating, a bit count of zero would let any dimensions pass the size check below
+	switch bitCount {
+	case 1, 2, 4, 8, 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("unsupported bit count %d. %w", bitCount, ErrInvalidDIB)
+	}
+
+	topDown := height < 0
+	if topDown {
+		height = -height
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid dimensions %dx%d. %w", width, height, ErrInvalidDIB)
+	}
+	stride := (uint64(width)*uint64(bitCount) + 31) / 32 * 4
+	if stride*uint64(height) > uint64(len(pixels)) {
+		return nil, fmt.Errorf("%dx%d pixels exceed %d bytes. %w", width, height, len(pixels), ErrInvalidDIB)
+	}
+
+	var palette color.Palette
+	if bitCount <= 8 {
+		table := dib[size:offset]
+		for i := 0; i+3 < len(table); i += 4 {
+			palette = append(palette, color.RGBA{R: table[i+2], G: table[i+1], B: table[i], A: 0xFF})
+		}
+		if len(palette) < 1<<bitCount {
+			palette = append(palette, make(color.Palette, 1<<bitCount-len(palette))...)
+			for i := range palette {
+				if palette[i] == nil {
+					palette[i] = color.Black
+				}
+			}
+		}
+	}
+
+	// masks, either from the header or the defaults of BI_RGB
+	var rMask, gMask, bMask, aMask uint32
+	switch {
+	case compression != _BI_RGB:
+		if len(dib) < 52 {
+			return nil, fmt.Errorf("missing color masks. %w", ErrInvalidDIB)
+		}
+		rMask = binary.LittleEndian.Uint32(dib[40:])
+		gMask = binary.LittleEndian.Uint32(dib[44:])
+		bMask = binary.LittleEndian.Uint32(dib[48:])
+		if (size >= 56 || compression == _BI_ALPHABITFIELDS) && len(dib) >= 56 {
+			aMask = binary.LittleEndian.Uint32(dib[52:])
+		}
+	case bitCount == 16:
+		rMask, gMask, bMask = 0x7C00, 0x03E0, 0x001F
+	case bitCount == 32:
+		rMask, gMask, bMask = 0x00FF0000, 0x0000FF00, 0x000000FF
+		// BI_RGB has no alpha, yet some applications store it in the unused byte
+		for y := 0; y < int(height) && aMask == 0; y++ {
+			row := pixels[uint64(y)*stride:]
+			for x := 0; x < int(width); x++ {
+				if row[x*4+3] != 0 {
+					aMask = 0xFF000000
+					break
+				}
+			}
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+	for y := 0; y < int(height); y++ {
+		row := pixels[uint64(y)*stride:][:stride]
+		dy := int(height) - 1 - y
+		if topDown {
+			dy = y
+		}
+		for x := 0; x < int(width); x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 1, 2, 4, 8:
+				bit := x * int(bitCount)
+				idx := row[bit/8] >> (8 - int(bitCount) - bit%8) & (1<<bitCount - 1)
+				c = color.NRGBAModel.Convert(palette[idx]).(color.NRGBA)
+			case 24:
+				c = color.NRGBA{R: row[x*3+2], G: row[x*3+1], B: row[x*3], A: 0xFF}
+			case 16:
+				v := uint32(binary.LittleEndian.Uint16(row[x*2:]))
+				c = color.NRGBA{R: maskedValue(v, rMask), G: maskedValue(v, gMask), B: maskedValue(v, bMask), A: 0xFF}
+			case 32:
+				v := binary.LittleEndian.Uint32(row[x*4:])
+				c = color.NRGBA{R: maskedValue(v, rMask), G: maskedValue(v, gMask), B: maskedValue(v, bMask), A: 0xFF}
+				if aMask != 0 {
+					c.A = maskedValue(v, aMask)
+				}
+			}
+			img.SetNRGBA(x, dy, c)
+		}
+	}
+	return img, nil
+}
+
+// maskedValue extracts the bits of mask from v, scaled to 8 bits
+func maskedValue(v, mask uint32) uint8 {
+	if mask == 0 {
+		return 0
+	}
+	shift := bits.TrailingZeros32(mask)
+	full := uint64(1)<<bits.OnesCount32(mask) - 1
+	return uint8(uint64(v&mask>>shift) * 0xFF / full)
+}
+
+// EncodeDIB encodes img as CF_DIB data, a bottom-up 24 bits per pixel bitmap.
+// CF_DIB has no transparency, use EncodeDIBV5 to keep it.
+func EncodeDIB(img image.Image) []byte {
+	b := img.Bounds()
+	stride := (b.Dx()*3 + 3) &^ 3
+	buf := make([]byte, bitmapInfoHeaderSize+stride*b.Dy())
+	putBitmapHeader(buf, bitmapInfoHeaderSize, b.Dx(), b.Dy(), 24, _BI_RGB, stride*b.Dy())
+
+	pixels := buf[bitmapInfoHeaderSize:]
+	for y := 0; y < b.Dy(); y++ {
+		row := pixels[(b.Dy()-1-y)*stride:]
+		for x := 0; x < b.Dx(); x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			row[x*3], row[x*3+1], row[x*3+2] = c.B, c.G, c.R
+		}
+	}
+	return buf
+}
+
+// EncodeDIBV5 encodes img as CF_DIBV5 data,
+// a bottom-up 32 bits per pixel sRGB bitmap with a (non-premultiplied) alpha channel
+func EncodeDIBV5(img image.Image) []byte {
+	b := img.Bounds()
+	stride := b.Dx() * 4
+	buf := make([]byte, bitmapV5HeaderSize+stride*b.Dy())
+	putBitmapHeader(buf, bitmapV5HeaderSize, b.Dx(), b.Dy(), 32, _BI_BITFIELDS, stride*b.Dy())
+	binary.LittleEndian.PutUint32(buf[40:], 0x00FF0000)
+	binary.LittleEndian.PutUint32(buf[44:], 0x0000FF00)
+	binary.LittleEndian.PutUint32(buf[48:], 0x000000FF)
+	binary.LittleEndian.PutUint32(buf[52:], 0xFF000000)
+	binary.LittleEndian.PutUint32(buf[56:], _LCS_sRGB)
+	binary.LittleEndian.PutUint32(buf[108:], _LCS_GM_IMAGES)
+
+	pixels := buf[bitmapV5HeaderSize:]
+	for y := 0; y < b.Dy(); y++ {
+		row := pixels[(b.Dy()-1-y)*stride:]
+		for x := 0; x < b.Dx(); x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			row[x*4], row[x*4+1], row[x*4+2], row[x*4+3] = c.B, c.G, c.R, c.A
+		}
+	}
+	return buf
+}
+
+func putBitmapHeader(buf []byte, size uint32, width, height int, bitCount uint16, compression uint32, imageSize int) {
+	binary.LittleEndian.PutUint32(buf, size)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(width))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(height))
+	binary.LittleEndian.PutUint16(buf[12:], 1)
+	binary.LittleEndian.PutUint16(buf[14:], bitCount)
+	binary.LittleEndian.PutUint32(buf[16:], compression)
+	binary.LittleEndian.PutUint32(buf[20:], uint32(imageSize))
+	// 96 DPI
+	binary.LittleEndian.PutUint32(buf[24:], 3780)
+	binary.LittleEndian.PutUint32(buf[28:], 3780)
+}
@@ -0,0 +1,290 @@
+package clipboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+)
+
+// DiffKind tells how a format differs between two snapshots
+type DiffKind string
+
+const (
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+	DiffChanged DiffKind = "changed"
+)
+
+// SnapshotDiff lists the formats that differ between two snapshots, in the order of the newer snapshot
+type SnapshotDiff struct {
+	Formats []FormatDiff `json:"formats"`
+}
+
+// FormatDiff describes the difference of a single format.
+// Details are only set for changed formats of a known kind.
+type FormatDiff struct {
+	Format  string        `json:"format"`
+	Kind    DiffKind      `json:"kind"`
+	OldSize int           `json:"oldSize"`
+	NewSize int           `json:"newSize"`
+	Lines   []LineDiff    `json:"lines,omitempty"`
+	Image   *ImageDiff    `json:"image,omitempty"`
+	Files   *FileListDiff `json:"files,omitempty"`
+}
+
+// LineDiff is a removed ("-") or added ("+") line of a text format
+type LineDiff struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// ImageDiff compares the dimensions and SHA-256 hashes of an image format
+type ImageDiff struct {
+	Old ImageInfo `json:"old"`
+	New ImageInfo `json:"new"`
+}
+
+// ImageInfo is zero sized if the image could not be decoded
+type ImageInfo struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	SHA256 string `json:"sha256"`
+}
+
+// FileListDiff lists the paths only present in one of the snapshots
+type FileListDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// maxLineDiffCells bounds the memory of a line diff, larger texts are reported as entirely replaced
+const maxLineDiffCells = 1 << 22
+
+// textDecoders extract the text of text-like formats for line diffs
+var textDecoders = map[string]func([]byte) string{
+	"CF_UNICODETEXT":          DecodeUnicodeText,
+	"UniformResourceLocatorW": DecodeUnicodeText,
+	"CF_TEXT":                 nulTerminated,
+	"CF_OEMTEXT":              nulTerminated,
+	"UniformResourceLocator":  nulTerminated,
+	RTFFormatName:             nulTerminated,
+	HTMLFormatName: func(data []byte) string {
+		if h, err := ParseHTML(data); err == nil {
+			return h.Fragment
+		}
+		return nulTerminated(data)
+	},
+}
+
+// imageDecoders return the size of image formats
+var imageDecoders = map[string]func([]byte) (image.Point, error){
+	PNGFormatName:  decodeImageSize,
+	GIFFormatName:  decodeImageSize,
+	JFIFFormatName: decodeImageSize,
+	"image/png":    decodeImageSize,
+	"CF_DIB":       decodeDIBSize,
+	"CF_DIBV5":     decodeDIBSize,
+}
+
+// fileListDecoders return the paths of file list formats
+var fileListDecoders = map[string]func([]byte) ([]string, error){
+	"CF_HDROP": DecodeDropFiles,
+	"FileGroupDescriptorW": func(data []byte) ([]string, error) {
+		files, err := DecodeFileGroupDescriptor(data)
+		names := make([]string, len(files))
+		for i, f := range files {
+			names[i] = f.Name
+		}
+		return names, err
+	},
+}
+
+func nulTerminated(data []byte) string {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	return string(data)
+}
+
+func decodeImageSize(data []byte) (image.Point, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	return image.Pt(cfg.Width, cfg.Height), err
+}
+
+func decodeDIBSize(data []byte) (image.Point, error) {
+	img, err := DecodeDIB(data)
+	if err != nil {
+		return image.Point{}, err
+	}
+	return img.Bounds().Size(), nil
+}
+
+// Diff compares the snapshots a and b, formats are matched by name
+func Diff(a, b *Snapshot) *SnapshotDiff {
+	d := &SnapshotDiff{Formats: []FormatDiff{}}
+	for _, newItem := range b.Formats {
+		oldItem, ok := a.Item(newItem.Name)
+		if !ok {
+			d.Formats = append(d.Formats, FormatDiff{Format: newItem.Name, Kind: DiffAdded, NewSize: len(newItem.Data)})
+			continue
+		}
+		if bytes.Equal(oldItem.Data, newItem.Data) {
+			continue
+		}
+		d.Formats = append(d.Formats, diffFormat(newItem.Name, oldItem.Data, newItem.Data))
+	}
+	for _, oldItem := range a.Formats {
+		if _, ok := b.Item(oldItem.Name); !ok {
+			d.Formats = append(d.Formats, FormatDiff{Format: oldItem.Name, Kind: DiffRemoved, OldSize: len(oldItem.Data)})
+		}
+	}
+	return d
+}
+
+func diffFormat(name string, before, after []byte) FormatDiff {
+	fd := FormatDiff{Format: name, Kind: DiffChanged, OldSize: len(before), NewSize: len(after)}
+	if decode, ok := textDecoders[name]; ok || strings.HasPrefix(name, "text/") {
+		if !ok {
+			decode = func(data []byte) string { return string(data) }
+		}
+		fd.Lines = diffLines(splitLines(decode(before)), splitLines(decode(after)))
+	}
+	if decode, ok := imageDecoders[name]; ok {
+		fd.Image = &ImageDiff{Old: imageInfo(decode, before), New: imageInfo(decode, after)}
+	}
+	if decode, ok := fileListDecoders[name]; ok {
+		oldFiles, _ := decode(before)
+		newFiles, _ := decode(after)
+		fd.Files = diffFileLists(oldFiles, newFiles)
+	}
+	return fd
+}
+
+func imageInfo(decode func([]byte) (image.Point, error), data []byte) ImageInfo {
+	sum := sha256.Sum256(data)
+	info := ImageInfo{SHA256: hex.EncodeToString(sum[:])}
+	if size, err := decode(data); err == nil {
+		info.Width, info.Height = size.X, size.Y
+	}
+	return info
+}
+
+func diffFileLists(before, after []string) *FileListDiff {
+	inOld := make(map[string]bool, len(before))
+	for _, p := range before {
+		inOld[p] = true
+	}
+	inNew := make(map[string]bool, len(after))
+	fd := &FileListDiff{}
+	for _, p := range after {
+		inNew[p] = true
+		if !inOld[p] {
+			fd.Added = append(fd.Added, p)
+		}
+	}
+	for _, p := range before {
+		if !inNew[p] {
+			fd.Removed = append(fd.Removed, p)
+		}
+	}
+	sort.Strings(fd.Added)
+	sort.Strings(fd.Removed)
+	return fd
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the removed and added lines of a longest common subsequence diff
+func diffLines(a, b []string) []LineDiff {
+	// common prefix and suffix do not need the quadratic part
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	var result []LineDiff
+	if int64(len(a))*int64(len(b)) > maxLineDiffCells {
+		for _, l := range a {
+			result = append(result, LineDiff{Op: "-", Text: l})
+		}
+		for _, l := range b {
+			result = append(result, LineDiff{Op: "+", Text: l})
+		}
+		return result
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			result = append(result, LineDiff{Op: "-", Text: a[i]})
+			i++
+		default:
+			result = append(result, LineDiff{Op: "+", Text: b[j]})
+			j++
+		}
+	}
+	return result
+}
+
+// String renders d for humans, one format per line followed by its indented details
+func (d *SnapshotDiff) String() string {
+	var sb strings.Builder
+	for _, f := range d.Formats {
+		switch f.Kind {
+		case DiffAdded:
+			fmt.Fprintf(&sb, "+ %s (%d bytes)\n", f.Format, f.NewSize)
+		case DiffRemoved:
+			fmt.Fprintf(&sb, "- %s (%d bytes)\n", f.Format, f.OldSize)
+		default:
+			fmt.Fprintf(&sb, "~ %s (%d -> %d bytes)\n", f.Format, f.OldSize, f.NewSize)
+		}
+		for _, l := range f.Lines {
+			fmt.Fprintf(&sb, "    %s %s\n", l.Op, l.Text)
+		}
+		if img := f.Image; img != nil {
+			fmt.Fprintf(&sb, "    image %dx%d -> %dx%d, sha256 %.12s -> %.12s\n",
+				img.Old.Width, img.Old.Height, img.New.Width, img.New.Height, img.Old.SHA256, img.New.SHA256)
+		}
+		if files := f.Files; files != nil {
+			for _, p := range files.Removed {
+				fmt.Fprintf(&sb, "    - %s\n", p)
+			}
+			for _, p := range files.Added {
+				fmt.Fprintf(&sb, "    + %s\n", p)
+			}
+		}
+	}
+	return sb.String()
+}
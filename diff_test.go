@@ -0,0 +1,269 @@
+package clipboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func snapshotOf(items ...SnapshotItem) *Snapshot {
+	return &Snapshot{Formats: items}
+}
+
+func TestDiffFormats(t *testing.T) {
+	a := snapshotOf(
+		SnapshotItem{Name: "Same", Data: []byte("same")},
+		SnapshotItem{Name: "Changed", Data: []byte("old")},
+		SnapshotItem{Name: "Removed", Data: []byte("gone")},
+	)
+	b := snapshotOf(
+		SnapshotItem{Name: "Added", Data: []byte("new!")},
+		SnapshotItem{Name: "Changed", Data: []byte("newer")},
+		SnapshotItem{Name: "Same", Data: []byte("same")},
+	)
+	want := []FormatDiff{
+		{Format: "Added", Kind: DiffAdded, NewSize: 4},
+		{Format: "Changed", Kind: DiffChanged, OldSize: 3, NewSize: 5},
+		{Format: "Removed", Kind: DiffRemoved, OldSize: 4},
+	}
+	if d := Diff(a, b); !reflect.DeepEqual(d.Formats, want) {
+		t.Errorf("got %+v, want %+v", d.Formats, want)
+	}
+	if d := Diff(a, a); d.Formats == nil || len(d.Formats) != 0 {
+		t.Errorf("identical snapshots: got %#v", d.Formats)
+	}
+}
+
+func lineDiffs(s ...string) []LineDiff {
+	var result []LineDiff
+	for _, v := range s {
+		result = append(result, LineDiff{Op: v[:1], Text: v[1:]})
+	}
+	return result
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []LineDiff
+	}{
+		{"equal", "a\nb", "a\nb", nil},
+		{"append", "a\nb", "a\nb\nc", lineDiffs("+c")},
+		{"prepend", "b\nc", "a\nb\nc", lineDiffs("+a")},
+		{"remove middle", "a\nb\nc", "a\nc", lineDiffs("-b")},
+		{"replace", "a\nb\nc", "a\nx\nc", lineDiffs("-b", "+x")},
+		{"from empty", "", "a\nb", lineDiffs("+a", "+b")},
+		{"to empty", "a\nb", "", lineDiffs("-a", "-b")},
+		// the LCS is a, c, e; the removals come before the additions at each point
+		{"interleaved", "a\nb\nc\nd\ne", "a\nc\nx\ne\ny", lineDiffs("-b", "-d", "+x", "+y")},
+		{"moved", "a\nb\nc", "c\na\nb", lineDiffs("+c", "-c")},
+		{"crlf", "a\r\nb\r\n", "a\nb\nc", lineDiffs("+c")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffLines(splitLines(tt.a), splitLines(tt.b))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesFallback(t *testing.T) {
+	// 2049 x 2049 differing lines exceed maxLineDiffCells once the common lines are trimmed
+	var a, b []string
+	a = append(a, "head")
+	b = append(b, "head")
+	for i := 0; i < 2049; i++ {
+		a = append(a, fmt.Sprint("a", i))
+		b = append(b, fmt.Sprint("b", i))
+	}
+	// the line in common would be kept by an LCS diff
+	a[1000], b[1000] = "common", "common"
+	a, b = append(a, "tail"), append(b, "tail")
+	if int64(len(a)-2)*int64(len(b)-2) <= maxLineDiffCells {
+		t.Fatal("the texts are too small for the fallback")
+	}
+
+	got := diffLines(a, b)
+	if len(got) != 2*2049 {
+		t.Fatalf("got %d lines, want %d", len(got), 2*2049)
+	}
+	for i, l := range got {
+		var want LineDiff
+		if i < 2049 {
+			want = LineDiff{Op: "-", Text: a[1+i]}
+		} else {
+			want = LineDiff{Op: "+", Text: b[1+i-2049]}
+		}
+		if l != want {
+			t.Fatalf("line %d: got %v, want %v", i, l, want)
+		}
+	}
+}
+
+func TestDiffText(t *testing.T) {
+	a := snapshotOf(
+		SnapshotItem{Name: "CF_UNICODETEXT", Data: EncodeUnicodeText("a\r\nb")},
+		SnapshotItem{Name: HTMLFormatName, Data: HTML{Fragment: "<b>x</b>", SourceURL: "https://a/"}.Bytes()},
+		SnapshotItem{Name: "text/x-custom", Data: []byte("one")},
+		SnapshotItem{Name: "Binary", Data: []byte{1}},
+	)
+	b := snapshotOf(
+		SnapshotItem{Name: "CF_UNICODETEXT", Data: EncodeUnicodeText("a\r\nc")},
+		// only the fragment is compared
+		SnapshotItem{Name: HTMLFormatName, Data: HTML{Fragment: "<b>x</b>", SourceURL: "https://b/"}.Bytes()},
+		SnapshotItem{Name: "text/x-custom", Data: []byte("two")},
+		SnapshotItem{Name: "Binary", Data: []byte{2}},
+	)
+	d := Diff(a, b)
+	want := map[string][]LineDiff{
+		"CF_UNICODETEXT": lineDiffs("-b", "+c"),
+		HTMLFormatName:   nil,
+		"text/x-custom":  lineDiffs("-one", "+two"),
+		"Binary":         nil,
+	}
+	if len(d.Formats) != len(want) {
+		t.Fatalf("got %+v", d.Formats)
+	}
+	for _, f := range d.Formats {
+		if !reflect.DeepEqual(f.Lines, want[f.Format]) || f.Image != nil || f.Files != nil {
+			t.Errorf("%s: got %+v, want lines %v", f.Format, f, want[f.Format])
+		}
+	}
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDiffImage(t *testing.T) {
+	small, large := encodePNG(t, 2, 3), encodePNG(t, 4, 1)
+	dib := EncodeDIB(image.NewNRGBA(image.Rect(0, 0, 5, 6)))
+	garbage := []byte("not a DIB")
+	a := snapshotOf(
+		SnapshotItem{Name: PNGFormatName, Data: small},
+		SnapshotItem{Name: "CF_DIB", Data: dib},
+	)
+	b := snapshotOf(
+		SnapshotItem{Name: PNGFormatName, Data: large},
+		SnapshotItem{Name: "CF_DIB", Data: garbage},
+	)
+	want := []*ImageDiff{
+		{Old: ImageInfo{2, 3, sha256Hex(small)}, New: ImageInfo{4, 1, sha256Hex(large)}},
+		// undecodable images are zero sized
+		{Old: ImageInfo{5, 6, sha256Hex(dib)}, New: ImageInfo{0, 0, sha256Hex(garbage)}},
+	}
+	d := Diff(a, b)
+	if len(d.Formats) != 2 {
+		t.Fatalf("got %+v", d.Formats)
+	}
+	for i, f := range d.Formats {
+		if !reflect.DeepEqual(f.Image, want[i]) || f.Lines != nil {
+			t.Errorf("%s: got %+v, want %+v", f.Format, f.Image, want[i])
+		}
+	}
+
+	// equal dimensions, only the hash changes
+	other := encodePNG(t, 2, 3)
+	other = append(other[:len(other):len(other)], 0)
+	d = Diff(a, snapshotOf(SnapshotItem{Name: PNGFormatName, Data: other}))
+	if img := d.Formats[0].Image; img.Old.Width != img.New.Width || img.Old.Height != img.New.Height || img.Old.SHA256 == img.New.SHA256 {
+		t.Errorf("got %+v", img)
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	a := snapshotOf(SnapshotItem{Name: "CF_HDROP", Data: EncodeDropFiles([]string{`C:\b`, `C:\a`, `C:\c`})})
+	b := snapshotOf(SnapshotItem{Name: "CF_HDROP", Data: EncodeDropFiles([]string{`C:\a`, `C:\e`, `C:\d`})})
+	want := &FileListDiff{Added: []string{`C:\d`, `C:\e`}, Removed: []string{`C:\b`, `C:\c`}}
+	if f := Diff(a, b).Formats[0]; !reflect.DeepEqual(f.Files, want) {
+		t.Errorf("got %+v, want %+v", f.Files, want)
+	}
+}
+
+func TestSnapshotDiffString(t *testing.T) {
+	small, large := encodePNG(t, 2, 3), encodePNG(t, 4, 1)
+	a := snapshotOf(
+		SnapshotItem{Name: "CF_UNICODETEXT", Data: EncodeUnicodeText("a\nb")},
+		SnapshotItem{Name: PNGFormatName, Data: small},
+		SnapshotItem{Name: "CF_HDROP", Data: EncodeDropFiles([]string{`C:\a`})},
+		SnapshotItem{Name: "Removed", Data: []byte("xy")},
+	)
+	b := snapshotOf(
+		SnapshotItem{Name: "Added", Data: []byte("x")},
+		SnapshotItem{Name: "CF_UNICODETEXT", Data: EncodeUnicodeText("a\nc")},
+		SnapshotItem{Name: PNGFormatName, Data: large},
+		SnapshotItem{Name: "CF_HDROP", Data: EncodeDropFiles([]string{`C:\b`})},
+	)
+	d := Diff(a, b)
+	want := strings.Join([]string{
+		"+ Added (1 bytes)",
+		"~ CF_UNICODETEXT (8 -> 8 bytes)",
+		"    - b",
+		"    + c",
+		fmt.Sprintf("~ PNG (%d -> %d bytes)", len(small), len(large)),
+		fmt.Sprintf("    image 2x3 -> 4x1, sha256 %s -> %s", sha256Hex(small)[:12], sha256Hex(large)[:12]),
+		fmt.Sprintf("~ CF_HDROP (%d -> %d bytes)", len(a.Formats[2].Data), len(b.Formats[3].Data)),
+		`    - C:\a`,
+		`    + C:\b`,
+		"- Removed (2 bytes)",
+		"",
+	}, "\n")
+	if s := d.String(); s != want {
+		t.Errorf("got\n%s\nwant\n%s", s, want)
+	}
+	if s := Diff(a, a).String(); s != "" {
+		t.Errorf("identical snapshots: got %q", s)
+	}
+}
+
+func TestSnapshotDiffJSON(t *testing.T) {
+	d := &SnapshotDiff{Formats: []FormatDiff{
+		{Format: "Added", Kind: DiffAdded, NewSize: 1},
+		{Format: "CF_UNICODETEXT", Kind: DiffChanged, OldSize: 4, NewSize: 6, Lines: lineDiffs("-a", "+b")},
+		{Format: "PNG", Kind: DiffChanged, OldSize: 1, NewSize: 2, Image: &ImageDiff{Old: ImageInfo{1, 2, "aa"}, New: ImageInfo{3, 4, "bb"}}},
+		{Format: "CF_HDROP", Kind: DiffChanged, OldSize: 1, NewSize: 2, Files: &FileListDiff{Added: []string{`C:\a`}}},
+	}}
+	want := `{"formats":[` +
+		`{"format":"Added","kind":"added","oldSize":0,"newSize":1},` +
+		`{"format":"CF_UNICODETEXT","kind":"changed","oldSize":4,"newSize":6,"lines":[{"op":"-","text":"a"},{"op":"+","text":"b"}]},` +
+		`{"format":"PNG","kind":"changed","oldSize":1,"newSize":2,"image":{"old":{"width":1,"height":2,"sha256":"aa"},"new":{"width":3,"height":4,"sha256":"bb"}}},` +
+		`{"format":"CF_HDROP","kind":"changed","oldSize":1,"newSize":2,"files":{"added":["C:\\a"]}}]}`
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("got\n%s\nwant\n%s", b, want)
+	}
+	var again SnapshotDiff
+	if err := json.Unmarshal(b, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&again, d) {
+		t.Errorf("decoded %+v, want %+v", again, d)
+	}
+
+	// no differences are an empty list rather than null
+	if b, _ := json.Marshal(Diff(snapshotOf(), snapshotOf())); string(b) != `{"formats":[]}` {
+		t.Errorf("got %s", b)
+	}
+}
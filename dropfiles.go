@@ -0,0 +1,76 @@
+package clipboard
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+var ErrInvalidDropFiles = errors.New("invalid CF_HDROP data")
+
+// dropFilesSize is the size of a DROPFILES header
+const dropFilesSize = 20
+
+// DecodeDropFiles parses DROPFILES data, the CF_HDROP representation, into a list of paths
+func DecodeDropFiles(data []byte) ([]string, error) {
+	if len(data) < dropFilesSize {
+		return nil, fmt.Errorf("%d bytes are too short for DROPFILES. %w", len(data), ErrInvalidDropFiles)
+	}
+	offset := binary.LittleEndian.Uint32(data)
+	wide := binary.LittleEndian.Uint32(data[16:]) != 0
+	if offset < dropFilesSize || uint64(offset) > uint64(len(data)) {
+		return nil, fmt.Errorf("file list offset %d out of range. %w", offset, ErrInvalidDropFiles)
+	}
+	data = data[offset:]
+
+	var result []string
+	if wide {
+		var name []uint16
+		for i := 0; i+1 < len(data); i += 2 {
+			c := binary.LittleEndian.Uint16(data[i:])
+			if c != 0 {
+				name = append(name, c)
+				continue
+			}
+			if len(name) == 0 {
+				return result, nil
+			}
+			result = append(result, string(utf16.Decode(name)))
+			name = name[:0]
+		}
+	} else {
+		var name []rune
+		for _, c := range data {
+			if c != 0 {
+				// the ANSI code page is unknown, treat it as Latin-1
+				name = append(name, rune(c))
+				continue
+			}
+			if len(name) == 0 {
+				return result, nil
+			}
+			result = append(result, string(name))
+			name = name[:0]
+		}
+	}
+	return nil, fmt.Errorf("file list is not terminated. %w", ErrInvalidDropFiles)
+}
+
+// EncodeDropFiles creates wide DROPFILES data, the CF_HDROP representation, listing paths
+func EncodeDropFiles(paths []string) []byte {
+	var names []uint16
+	for _, p := range paths {
+		names = append(names, utf16.Encode([]rune(p))...)
+		names = append(names, 0)
+	}
+	names = append(names, 0)
+
+	buf := make([]byte, dropFilesSize+len(names)*2)
+	binary.LittleEndian.PutUint32(buf, dropFilesSize)
+	binary.LittleEndian.PutUint32(buf[16:], 1)
+	for i, c := range names {
+		binary.LittleEndian.PutUint16(buf[dropFilesSize+i*2:], c)
+	}
+	return buf
+}
@@ -0,0 +1,181 @@
+package clipboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnsafePath = errors.New("unsafe path")
+
+// ExtractOptions configure ExtractTo and ExtractFiles
+type ExtractOptions struct {
+	// Overwrite replaces existing files, otherwise they are reported as failed
+	Overwrite bool
+	// Progress is called once for every entry, after it was extracted or failed
+	Progress func(index, count int, result ExtractResult)
+	// FileProgress is called while the contents of the entry index are copied,
+	// total is its descriptor Size, negative if unknown
+	FileProgress func(index int, read, total int64)
+	// Context cancels the extraction between reads, remaining entries fail with its error
+	Context context.Context
+	// BytesPerSecond limits the throughput of every copy, zero is unlimited
+	BytesPerSecond int64
+}
+
+// ExtractResult is the outcome of extracting a single entry
+type ExtractResult struct {
+	FileInfo
+	// Path is the destination on disk, empty if the name was rejected
+	Path string
+	// Written is the number of bytes copied
+	Written int64
+	Err     error
+}
+
+// ExtractFiles writes files below dir, reading the contents of the file at index i from open(i).
+//
+// Names are relative paths separated by backslashes, directories are created as needed.
+// Entries that would escape dir, use reserved device names or invalid characters are rejected.
+// Timestamps and attributes of the descriptors are applied where known.
+//
+// Every entry is attempted, the returned error is the first one that occurred.
+func ExtractFiles(dir string, files []FileInfo, open func(index int) (io.ReadCloser, error), opts ExtractOptions) ([]ExtractResult, error) {
+	results := make([]ExtractResult, len(files))
+	var firstErr error
+	var dirs []int
+	for i, f := range files {
+		r := &results[i]
+		r.FileInfo = f
+		if opts.Context != nil && opts.Context.Err() != nil {
+			r.Err = opts.Context.Err()
+		} else {
+			r.Path, r.Err = safeJoin(dir, f.Name)
+		}
+		if r.Err == nil {
+			if f.IsDir() {
+				r.Err = os.MkdirAll(r.Path, 0755)
+				dirs = append(dirs, i)
+			} else {
+				r.Written, r.Err = extractFile(r.Path, i, f, open, opts)
+				if r.Err == nil {
+					r.Err = applyFileInfo(r.Path, f)
+				}
+			}
+		}
+		if r.Err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("extracting %s. %w", f.Name, r.Err)
+		}
+		if opts.Progress != nil {
+			opts.Progress(i, len(files), *r)
+		}
+	}
+	// writing files changes the times of their directory
+	for _, i := range dirs {
+		if r := &results[i]; r.Err == nil {
+			if err := applyFileInfo(r.Path, r.FileInfo); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("extracting %s. %w", r.Name, err)
+			}
+		}
+	}
+	return results, firstErr
+}
+
+func extractFile(path string, index int, f FileInfo, open func(index int) (io.ReadCloser, error), opts ExtractOptions) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	src, err := open(index)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	var r io.Reader = src
+	if opts.Context != nil {
+		r = NewContextReader(opts.Context, r)
+	}
+	if opts.BytesPerSecond > 0 {
+		r = NewThrottledReader(r, opts.BytesPerSecond)
+	}
+	if opts.FileProgress != nil {
+		total := f.Size
+		if total <= 0 {
+			total = -1
+		}
+		r = NewProgressReader(r, total, func(read, total int64) { opts.FileProgress(index, read, total) })
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if opts.Overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	dst, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(dst, r)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// do not leave a truncated file behind
+		os.Remove(path)
+	}
+	return n, err
+}
+
+// safeJoin resolves the backslash separated relative name below dir
+func safeJoin(dir, name string) (string, error) {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '\\' || r == '/' })
+	if len(parts) == 0 || strings.HasPrefix(name, `\`) || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("%q is not a relative path. %w", name, ErrUnsafePath)
+	}
+	for _, p := range parts {
+		if err := checkPathComponent(p); err != nil {
+			return "", fmt.Errorf("%q: %v. %w", name, err, ErrUnsafePath)
+		}
+	}
+	path := filepath.Join(append([]string{dir}, parts...)...)
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q escapes the destination. %w", name, ErrUnsafePath)
+	}
+	return path, nil
+}
+
+// reservedNames are DOS device names, which refer to the device regardless of directory and extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true, "CONIN$": true, "CONOUT$": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"COM¹": true, "COM²": true, "COM³": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+	"LPT¹": true, "LPT²": true, "LPT³": true,
+}
+
+func checkPathComponent(p string) error {
+	if p == "." || p == ".." {
+		return fmt.Errorf("relative component %q", p)
+	}
+	// Windows silently strips trailing dots and spaces, "..." or "a. " would alias another name
+	if strings.HasSuffix(p, ".") || strings.HasSuffix(p, " ") {
+		return fmt.Errorf("trailing dot or space in %q", p)
+	}
+	for _, r := range p {
+		if r < 0x20 || strings.ContainsRune(`<>:"|?*`, r) {
+			return fmt.Errorf("invalid character %q", r)
+		}
+	}
+	base := p
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	if reservedNames[strings.ToUpper(strings.TrimRight(base, " "))] {
+		return fmt.Errorf("reserved name %q", p)
+	}
+	return nil
+}
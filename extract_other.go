@@ -0,0 +1,33 @@
+//go:build !windows
+// +build !windows
+
+package clipboard
+
+import (
+	"os"
+)
+
+// applyFileInfo sets the times of f on path, and removes write permissions of read-only files.
+// Creation times and other attributes have no equivalent.
+func applyFileInfo(path string, f FileInfo) error {
+	if !f.ModTime.IsZero() || !f.AccessTime.IsZero() {
+		atime, mtime := f.AccessTime, f.ModTime
+		if atime.IsZero() {
+			atime = mtime
+		}
+		if mtime.IsZero() {
+			mtime = atime
+		}
+		if err := os.Chtimes(path, atime, mtime); err != nil {
+			return err
+		}
+	}
+	if f.Attributes&_FILE_ATTRIBUTE_READONLY != 0 && !f.IsDir() {
+		st, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		return os.Chmod(path, st.Mode()&^0222)
+	}
+	return nil
+}
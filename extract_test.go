@@ -0,0 +1,156 @@
+package clipboard
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafeJoin(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"a.txt", "a.txt"},
+		{`dir\sub\a.txt`, filepath.Join("dir", "sub", "a.txt")},
+		{"dir/a.txt", filepath.Join("dir", "a.txt")},
+		{`dir\\a.txt`, filepath.Join("dir", "a.txt")},
+		{"..a", "..a"},
+		{"a..b.txt", "a..b.txt"},
+		{"CONSOLE.txt", "CONSOLE.txt"},
+		{"COM0", "COM0"},
+		{"COM10", "COM10"},
+		{"über.txt", "über.txt"},
+
+		{`..\x`, ""},
+		{`a\..\..\x`, ""},
+		{`a\..\x`, ""},
+		{`.\x`, ""},
+		{`\x`, ""},
+		{`/x`, ""},
+		{`\\server\share\x`, ""},
+		{`C:\x`, ""},
+		{`C:x`, ""},
+		{`a.txt:stream`, ""},
+		{`a.txt::$DATA`, ""},
+		{"CON", ""},
+		{"con.txt", ""},
+		{`dir\CON.txt`, ""},
+		{"CON .txt", ""},
+		{"NUL", ""},
+		{"aux.tar.gz", ""},
+		{"COM1", ""},
+		{"COM¹", ""},
+		{"lpt³.log", ""},
+		{"CONIN$", ""},
+		{"a.", ""},
+		{"a ", ""},
+		{`dir.\a`, ""},
+		{"...", ""},
+		{"a\x00b", ""},
+		{"a\nb", ""},
+		{"a\x1fb", ""},
+		{`a<b`, ""},
+		{`a|b`, ""},
+		{`a?`, ""},
+		{`a*`, ""},
+		{`"a"`, ""},
+		{"", ""},
+		{`\`, ""},
+		{`\\`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := safeJoin(dir, tt.name)
+			if tt.want == "" {
+				if !errors.Is(err, ErrUnsafePath) {
+					t.Errorf("got %q, %v, want ErrUnsafePath", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(dir, tt.want); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func openStrings(data ...string) func(int) (io.ReadCloser, error) {
+	return func(i int) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(data[i])), nil
+	}
+}
+
+func TestExtractFilesOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := []FileInfo{{Name: "a.txt", Size: 3}, {Name: "b.txt", Size: 3}}
+
+	results, err := ExtractFiles(dir, files, openStrings("new", "bbb"), ExtractOptions{})
+	if !errors.Is(err, os.ErrExist) || !errors.Is(results[0].Err, os.ErrExist) {
+		t.Errorf("got %v, %v, want os.ErrExist", err, results[0].Err)
+	}
+	// the other entries are still extracted
+	if results[1].Err != nil || results[1].Written != 3 {
+		t.Errorf("b.txt: %+v", results[1])
+	}
+	if b, _ := os.ReadFile(path); string(b) != "existing" {
+		t.Errorf("existing file was modified: %q", b)
+	}
+
+	if _, err := ExtractFiles(dir, files, openStrings("new", "bbb"), ExtractOptions{Overwrite: true}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "new" {
+		t.Errorf("file was not replaced: %q", b)
+	}
+}
+
+// failingReader returns data, then err
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (r *failingReader) Close() error { return nil }
+
+func TestExtractFilesTruncated(t *testing.T) {
+	dir := t.TempDir()
+	errBroken := errors.New("stream broken")
+	files := []FileInfo{{Name: `dir\a.txt`, Size: 100}, {Name: `..\evil.txt`}}
+	open := func(i int) (io.ReadCloser, error) { return &failingReader{data: "partial", err: errBroken}, nil }
+
+	results, err := ExtractFiles(dir, files, open, ExtractOptions{})
+	if !errors.Is(err, errBroken) || !errors.Is(results[0].Err, errBroken) {
+		t.Fatalf("got %v, %v, want the stream error", err, results[0].Err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dir", "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("truncated file was left behind: %v", err)
+	}
+	if !errors.Is(results[1].Err, ErrUnsafePath) || results[1].Path != "" {
+		t.Errorf("unsafe entry: %+v", results[1])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "evil.txt")); !os.IsNotExist(err) {
+		t.Errorf("file escaped the destination: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package clipboard
+
+import (
+	"io"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// ExtractTo writes every FileContents item in the clipboard below dir,
+// using names, times and attributes of the FileGroupDescriptorW slot. See ExtractFiles.
+//
+// Requires a call to Init.
+func ExtractTo(dir string, opts ExtractOptions) ([]ExtractResult, error) {
+	files, err := GetFileGroupDescriptor()
+	if err != nil {
+		return nil, err
+	}
+	return ExtractFiles(dir, files, func(index int) (io.ReadCloser, error) {
+		return GetFileContent(index)
+	}, opts)
+}
+
+// settableAttributes can be applied with SetFileAttributes
+const settableAttributes = _FILE_ATTRIBUTE_READONLY | _FILE_ATTRIBUTE_HIDDEN | _FILE_ATTRIBUTE_SYSTEM | _FILE_ATTRIBUTE_ARCHIVE
+
+// applyFileInfo sets the times and attributes of f on path
+func applyFileInfo(path string, f FileInfo) error {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	if !f.CreationTime.IsZero() || !f.AccessTime.IsZero() || !f.ModTime.IsZero() {
+		// FILE_FLAG_BACKUP_SEMANTICS is required to open directories
+		h, err := windows.CreateFile(p, windows.FILE_WRITE_ATTRIBUTES, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE, nil,
+			windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+		if err != nil {
+			return err
+		}
+		err = windows.SetFileTime(h, filetimePtr(f.CreationTime), filetimePtr(f.AccessTime), filetimePtr(f.ModTime))
+		windows.CloseHandle(h)
+		if err != nil {
+			return err
+		}
+	}
+	if attrs := f.Attributes & settableAttributes; attrs != 0 {
+		if f.IsDir() {
+			attrs |= _FILE_ATTRIBUTE_DIRECTORY
+		}
+		return windows.SetFileAttributes(p, attrs)
+	}
+	return nil
+}
+
+// filetimePtr returns nil for the zero time, leaving the time unchanged
+func filetimePtr(t time.Time) *windows.Filetime {
+	if t.IsZero() {
+		return nil
+	}
+	ft := windows.NsecToFiletime(t.UnixNano())
+	return &ft
+}
@@ -0,0 +1,134 @@
+package clipboard
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf16"
+)
+
+var ErrInvalidFileGroupDescriptor = errors.New("invalid FileGroupDescriptorW data")
+
+const _MAX_PATH = 260
+
+const (
+	// fileDescriptorSize is the size of a FILEDESCRIPTORW
+	fileDescriptorSize = 592
+
+	_FD_ATTRIBUTES = 0x00000004
+	_FD_CREATETIME = 0x00000008
+	_FD_ACCESSTIME = 0x00000010
+	_FD_WRITESTIME = 0x00000020
+	_FD_FILESIZE   = 0x00000040
+
+	_FILE_ATTRIBUTE_READONLY  = 0x00000001
+	_FILE_ATTRIBUTE_HIDDEN    = 0x00000002
+	_FILE_ATTRIBUTE_SYSTEM    = 0x00000004
+	_FILE_ATTRIBUTE_DIRECTORY = 0x00000010
+	_FILE_ATTRIBUTE_ARCHIVE   = 0x00000020
+	_FILE_ATTRIBUTE_NORMAL    = 0x00000080
+
+	// filetimeUnixOffset is the number of seconds between 1601-01-01 and 1970-01-01
+	filetimeUnixOffset = 11644473600
+)
+
+// DecodeFileGroupDescriptor parses FILEGROUPDESCRIPTORW data
+// (as found in the FileGroupDescriptorW slot or an RDP file list)
+func DecodeFileGroupDescriptor(data []byte) ([]FileInfo, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("missing item count. %w", ErrInvalidFileGroupDescriptor)
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	if uint64(count)*fileDescriptorSize > uint64(len(data)) {
+		return nil, fmt.Errorf("%d items do not fit into %d bytes. %w", count, len(data), ErrInvalidFileGroupDescriptor)
+	}
+
+	result := make([]FileInfo, 0, count)
+	for i := uint32(0); i < count; i++ {
+		fd := data[int(i)*fileDescriptorSize:][:fileDescriptorSize]
+		name := make([]uint16, 0, 260)
+		for j := 72; j+1 < len(fd); j += 2 {
+			c := binary.LittleEndian.Uint16(fd[j:])
+			if c == 0 {
+				break
+			}
+			name = append(name, c)
+		}
+		flags := binary.LittleEndian.Uint32(fd)
+		info := FileInfo{
+			Name: string(utf16.Decode(name)),
+			Size: int64(binary.LittleEndian.Uint32(fd[64:]))<<32 | int64(binary.LittleEndian.Uint32(fd[68:])),
+		}
+		if flags&_FD_ATTRIBUTES != 0 {
+			info.Attributes = binary.LittleEndian.Uint32(fd[36:])
+		}
+		if flags&_FD_CREATETIME != 0 {
+			info.CreationTime = decodeFiletime(fd[40:])
+		}
+		if flags&_FD_ACCESSTIME != 0 {
+			info.AccessTime = decodeFiletime(fd[48:])
+		}
+		if flags&_FD_WRITESTIME != 0 {
+			info.ModTime = decodeFiletime(fd[56:])
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// decodeFiletime converts a FILETIME, 100ns intervals since 1601-01-01 UTC. Zero stays zero.
+func decodeFiletime(b []byte) time.Time {
+	ft := uint64(binary.LittleEndian.Uint32(b)) | uint64(binary.LittleEndian.Uint32(b[4:]))<<32
+	if ft == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(ft/1e7)-filetimeUnixOffset, int64(ft%1e7)*100)
+}
+
+func encodeFiletime(b []byte, t time.Time) {
+	ft := uint64(t.Unix()+filetimeUnixOffset)*1e7 + uint64(t.Nanosecond()/100)
+	binary.LittleEndian.PutUint32(b, uint32(ft))
+	binary.LittleEndian.PutUint32(b[4:], uint32(ft>>32))
+}
+
+// EncodeFileGroupDescriptor creates FILEGROUPDESCRIPTORW data for files.
+//
+// Names are truncated to MAX_PATH-1 UTF-16 code units.
+func EncodeFileGroupDescriptor(files []FileInfo) []byte {
+	buf := make([]byte, 4+len(files)*fileDescriptorSize)
+	binary.LittleEndian.PutUint32(buf, uint32(len(files)))
+	for i, f := range files {
+		fd := buf[4+i*fileDescriptorSize:][:fileDescriptorSize]
+		flags := uint32(_FD_ATTRIBUTES | _FD_FILESIZE)
+		attributes := f.Attributes
+		if attributes == 0 {
+			attributes = _FILE_ATTRIBUTE_NORMAL
+		}
+		binary.LittleEndian.PutUint32(fd[36:], attributes)
+		if !f.CreationTime.IsZero() {
+			flags |= _FD_CREATETIME
+			encodeFiletime(fd[40:], f.CreationTime)
+		}
+		if !f.AccessTime.IsZero() {
+			flags |= _FD_ACCESSTIME
+			encodeFiletime(fd[48:], f.AccessTime)
+		}
+		if !f.ModTime.IsZero() {
+			flags |= _FD_WRITESTIME
+			encodeFiletime(fd[56:], f.ModTime)
+		}
+		binary.LittleEndian.PutUint32(fd[0:], flags)
+		binary.LittleEndian.PutUint32(fd[64:], uint32(uint64(f.Size)>>32))
+		binary.LittleEndian.PutUint32(fd[68:], uint32(f.Size))
+		name := utf16.Encode([]rune(f.Name))
+		if len(name) > _MAX_PATH-1 {
+			name = name[:_MAX_PATH-1]
+		}
+		for j, c := range name {
+			binary.LittleEndian.PutUint16(fd[72+j*2:], c)
+		}
+	}
+	return buf
+}
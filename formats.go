@@ -0,0 +1,135 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownClipboardFormat = errors.New("unknown clipboard format")
+
+// Format identifies a clipboard format,
+// either one of the pre-defined CF_ constants or an id returned by RegisterClipboardFormat
+type Format uint32
+
+// Pre-defined clipboard formats
+const (
+	CF_TEXT         Format = 1
+	CF_BITMAP       Format = 2
+	CF_METAFILEPICT Format = 3
+	CF_SYLK         Format = 4
+	CF_DIF          Format = 5
+	CF_TIFF         Format = 6
+	CF_OEMTEXT      Format = 7
+	CF_DIB          Format = 8
+	CF_PALETTE      Format = 9
+	CF_PENDATA      Format = 10
+	CF_RIFF         Format = 11
+	CF_WAVE         Format = 12
+	CF_UNICODETEXT  Format = 13
+	CF_ENHMETAFILE  Format = 14
+	CF_HDROP        Format = 15
+	CF_LOCALE       Format = 16
+	CF_DIBV5        Format = 17
+
+	CF_OWNERDISPLAY    Format = 0x0080
+	CF_DSPTEXT         Format = 0x0081
+	CF_DSPBITMAP       Format = 0x0082
+	CF_DSPMETAFILEPICT Format = 0x0083
+	CF_DSPENHMETAFILE  Format = 0x008E
+	CF_PRIVATEFIRST    Format = 0x0200
+	CF_PRIVATELAST     Format = 0x02FF
+	CF_GDIOBJFIRST     Format = 0x0300
+	CF_GDIOBJLAST      Format = 0x03FF
+)
+
+// Name returns a readable name for f.
+//
+// Being either a pre-defined name, or the name the format was registered with, see DefaultRegistry.
+func (f Format) Name() (string, error) {
+	return DefaultRegistry.Name(f)
+}
+
+// String returns the name of f, resolving registered formats through DefaultRegistry
+func (f Format) String() string {
+	if name, err := f.Name(); err == nil {
+		return name
+	}
+	return fmt.Sprintf("Format(%d)", uint32(f))
+}
+
+// IsPredefined reports whether f is one of the standard formats,
+// CF_TEXT to CF_DIBV5, CF_OWNERDISPLAY or one of the CF_DSP formats
+func (f Format) IsPredefined() bool {
+	_, ok := predefinedFormatNames[f]
+	return ok && !f.IsPrivate() && !f.IsGDIObject()
+}
+
+// IsRegistered reports whether f lies in the range used by RegisterClipboardFormat
+func (f Format) IsRegistered() bool {
+	return f >= 0xC000 && f <= 0xFFFF
+}
+
+// IsPrivate reports whether f lies in the range CF_PRIVATEFIRST to CF_PRIVATELAST.
+// Data of these formats is not freed by the system.
+func (f Format) IsPrivate() bool {
+	return f >= CF_PRIVATEFIRST && f <= CF_PRIVATELAST
+}
+
+// IsGDIObject reports whether f lies in the range CF_GDIOBJFIRST to CF_GDIOBJLAST.
+// Data of these formats is a GDI object handle which is deleted by the system.
+func (f Format) IsGDIObject() bool {
+	return f >= CF_GDIOBJFIRST && f <= CF_GDIOBJLAST
+}
+
+var predefinedFormatNames = map[Format]string{
+	CF_TEXT:         "CF_TEXT",
+	CF_BITMAP:       "CF_BITMAP",
+	CF_METAFILEPICT: "CF_METAFILEPICT",
+	CF_SYLK:         "CF_SYLK",
+	CF_DIF:          "CF_DIF",
+	CF_TIFF:         "CF_TIFF",
+	CF_OEMTEXT:      "CF_OEMTEXT",
+	CF_DIB:          "CF_DIB",
+	CF_PALETTE:      "CF_PALETTE",
+	CF_PENDATA:      "CF_PENDATA",
+	CF_RIFF:         "CF_RIFF",
+	CF_WAVE:         "CF_WAVE",
+	CF_UNICODETEXT:  "CF_UNICODETEXT",
+	CF_ENHMETAFILE:  "CF_ENHMETAFILE",
+	CF_HDROP:        "CF_HDROP",
+	CF_LOCALE:       "CF_LOCALE",
+	CF_DIBV5:        "CF_DIBV5",
+
+	CF_OWNERDISPLAY:    "CF_OWNERDISPLAY",
+	CF_DSPTEXT:         "CF_DSPTEXT",
+	CF_DSPBITMAP:       "CF_DSPBITMAP",
+	CF_DSPMETAFILEPICT: "CF_DSPMETAFILEPICT",
+	CF_DSPENHMETAFILE:  "CF_DSPENHMETAFILE",
+	CF_PRIVATEFIRST:    "CF_PRIVATEFIRST",
+	CF_PRIVATELAST:     "CF_PRIVATELAST",
+	CF_GDIOBJFIRST:     "CF_GDIOBJFIRST",
+	CF_GDIOBJLAST:      "CF_GDIOBJLAST",
+}
+
+func predefinedFormatName(id Format) (string, error) {
+	if v, ok := predefinedFormatNames[id]; ok {
+		return v, nil
+	}
+	if id.IsPrivate() {
+		return "PRIVATE", nil
+	}
+	if id.IsGDIObject() {
+		return "GDIOBJ", nil
+	}
+	return "", fmt.Errorf("unsupported format %d. %w", id, ErrUnknownClipboardFormat)
+}
+
+// predefinedFormatID returns the id of a pre-defined format by its name, e.g. "CF_UNICODETEXT"
+func predefinedFormatID(name string) (Format, bool) {
+	for id, v := range predefinedFormatNames {
+		if v == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,92 @@
+package clipboard
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormatPredicates(t *testing.T) {
+	tests := []struct {
+		f                                          Format
+		predefined, registered, private, gdiObject bool
+	}{
+		{0, false, false, false, false},
+		{CF_TEXT, true, false, false, false},
+		{CF_DIBV5, true, false, false, false},
+		{CF_DIBV5 + 1, false, false, false, false},
+		{CF_OWNERDISPLAY, true, false, false, false},
+		{CF_DSPENHMETAFILE, true, false, false, false},
+		{CF_PRIVATEFIRST - 1, false, false, false, false},
+		{CF_PRIVATEFIRST, false, false, true, false},
+		{CF_PRIVATELAST, false, false, true, false},
+		{CF_GDIOBJFIRST, false, false, false, true},
+		{CF_GDIOBJFIRST + 7, false, false, false, true},
+		{CF_GDIOBJLAST, false, false, false, true},
+		{CF_GDIOBJLAST + 1, false, false, false, false},
+		{0xBFFF, false, false, false, false},
+		{0xC000, false, true, false, false},
+		{0xFFFF, false, true, false, false},
+		{0x10000, false, false, false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.f.IsPredefined(); got != tt.predefined {
+			t.Errorf("Format(%#x).IsPredefined() = %v", uint32(tt.f), got)
+		}
+		if got := tt.f.IsRegistered(); got != tt.registered {
+			t.Errorf("Format(%#x).IsRegistered() = %v", uint32(tt.f), got)
+		}
+		if got := tt.f.IsPrivate(); got != tt.private {
+			t.Errorf("Format(%#x).IsPrivate() = %v", uint32(tt.f), got)
+		}
+		if got := tt.f.IsGDIObject(); got != tt.gdiObject {
+			t.Errorf("Format(%#x).IsGDIObject() = %v", uint32(tt.f), got)
+		}
+	}
+}
+
+func TestFormatName(t *testing.T) {
+	tests := []struct {
+		f    Format
+		want string
+	}{
+		{CF_UNICODETEXT, "CF_UNICODETEXT"},
+		{CF_DSPTEXT, "CF_DSPTEXT"},
+		{CF_PRIVATEFIRST, "CF_PRIVATEFIRST"},
+		{CF_PRIVATEFIRST + 1, "PRIVATE"},
+		{CF_GDIOBJFIRST + 1, "GDIOBJ"},
+	}
+	for _, tt := range tests {
+		if name, err := tt.f.Name(); err != nil || name != tt.want {
+			t.Errorf("Format(%#x).Name() = %q, %v, want %q", uint32(tt.f), name, err, tt.want)
+		}
+		if s := tt.f.String(); s != tt.want {
+			t.Errorf("Format(%#x).String() = %q, want %q", uint32(tt.f), s, tt.want)
+		}
+	}
+
+	if _, err := Format(0x1234).Name(); !errors.Is(err, ErrUnknownClipboardFormat) {
+		t.Errorf("got %v, want ErrUnknownClipboardFormat", err)
+	}
+	if s := Format(0x1234).String(); s != "Format(4660)" {
+		t.Errorf("String() of an unknown format = %q", s)
+	}
+
+	id, err := DefaultRegistry.Register("go-winclipboard test format")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := id.String(); s != "go-winclipboard test format" {
+		t.Errorf("String() of a registered format = %q", s)
+	}
+}
+
+func TestPredefinedFormatID(t *testing.T) {
+	for id, name := range predefinedFormatNames {
+		if got, ok := predefinedFormatID(name); !ok || got != id {
+			t.Errorf("predefinedFormatID(%q) = %#x, %v, want %#x", name, got, ok, id)
+		}
+	}
+	if _, ok := predefinedFormatID("HTML Format"); ok {
+		t.Error("a registered format was found")
+	}
+}
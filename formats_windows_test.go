@@ -0,0 +1,48 @@
+package clipboard
+
+import (
+	"reflect"
+	"testing"
+)
+
+// The deprecated int based wrappers have to agree with the Format API
+
+func TestFormatNameWrapper(t *testing.T) {
+	id, err := RegisterFormat("go-winclipboard test format")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []Format{CF_UNICODETEXT, CF_PRIVATEFIRST + 1, id} {
+		want, err := f.Name()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name, err := FormatName(int(f)); err != nil || name != want {
+			t.Errorf("FormatName(%d) = %q, %v, want %q", f, name, err, want)
+		}
+	}
+}
+
+func TestFormatsWrapper(t *testing.T) {
+	// the clipboard may change between both calls, which is retried
+	for i := 0; i < 3; i++ {
+		formats, err := AvailableFormats()
+		if err != nil {
+			t.Skip(err)
+		}
+		ids, err := Formats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make([]int, len(formats))
+		for j, f := range formats {
+			want[j] = int(f)
+		}
+		if len(ids) == 0 && len(want) == 0 || reflect.DeepEqual(ids, want) {
+			return
+		}
+		if i == 2 {
+			t.Errorf("Formats() = %v, AvailableFormats() = %v", ids, want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The seeds below are complemented by the corpus in testdata/fuzz/<target>.
+
+func FuzzDecodeFileGroupDescriptor(f *testing.F) {
+	f.Add(EncodeFileGroupDescriptor([]FileInfo{
+		{Name: "a.txt", Size: 5, ModTime: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: `dir\b.txt`, Size: 1 << 40, Attributes: _FILE_ATTRIBUTE_READONLY},
+	}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		files, err := DecodeFileGroupDescriptor(data)
+		if err != nil {
+			return
+		}
+		if max := (len(data) - 4) / fileDescriptorSize; len(files) > max {
+			t.Fatalf("%d items from %d bytes", len(files), len(data))
+		}
+		for _, fi := range files {
+			if strings.ContainsRune(fi.Name, 0) {
+				t.Fatalf("name %q contains NUL", fi.Name)
+			}
+		}
+	})
+}
+
+func FuzzDecodeDropFiles(f *testing.F) {
+	f.Add(EncodeDropFiles([]string{`C:\a.txt`, `C:\dir\ü.txt`}))
+	ansi := make([]byte, dropFilesSize, dropFilesSize+16)
+	binary.LittleEndian.PutUint32(ansi, dropFilesSize)
+	f.Add(append(ansi, "C:\\a.txt\x00\x00"...))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		paths, err := DecodeDropFiles(data)
+		if err != nil {
+			return
+		}
+		again, err := DecodeDropFiles(EncodeDropFiles(paths))
+		if err != nil {
+			t.Fatalf("decoding %q again: %v", paths, err)
+		}
+		if len(paths) > 0 && !reflect.DeepEqual(again, paths) {
+			t.Fatalf("decoded %q, then %q", paths, again)
+		}
+	})
+}
+
+func FuzzDecodeDIB(f *testing.F) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.SetNRGBA(1, 1, color.NRGBA{R: 0xFF, A: 0x80})
+	f.Add(EncodeDIB(img))
+	f.Add(EncodeDIBV5(img))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		img, err := DecodeDIB(data)
+		if err != nil {
+			return
+		}
+		b := img.Bounds()
+		if uint64(b.Dx())*uint64(b.Dy()) > maxImagePixels {
+			t.Fatalf("image of %dx%d pixels", b.Dx(), b.Dy())
+		}
+		if _, err := DecodeDIB(EncodeDIBV5(img)); err != nil {
+			t.Fatalf("decoding the image again: %v", err)
+		}
+	})
+}
+
+func FuzzDecodeShellIDList(f *testing.F) {
+	// a parent folder with one item id and two items
+	var cida bytes.Buffer
+	binary.Write(&cida, binary.LittleEndian, []uint32{2, 16, 22, 28})
+	cida.Write([]byte{4, 0, 'P', 'F', 0, 0})
+	cida.Write([]byte{4, 0, 'i', '1', 0, 0})
+	cida.Write([]byte{4, 0, 'i', '2', 0, 0})
+	f.Add(cida.Bytes())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pidls, err := DecodeShellIDList(data)
+		if err != nil {
+			return
+		}
+		if max := (len(data) - 8) / 4; len(pidls) > max {
+			t.Fatalf("%d items from %d bytes", len(pidls), len(data))
+		}
+		for i, pidl := range pidls {
+			if len(pidl) < 2 || pidl[len(pidl)-2] != 0 || pidl[len(pidl)-1] != 0 {
+				t.Fatalf("item %d is not terminated: %x", i, pidl)
+			}
+		}
+	})
+}
+
+func FuzzParseHTML(f *testing.F) {
+	f.Add(HTML{Fragment: "<b>bold</b>", SourceURL: "https://example.com/"}.Bytes())
+	f.Add([]byte("Version:0.9\r\nStartHTML:-1\r\nEndHTML:-1\r\nStartFragment:0000000067\r\nEndFragment:0000000071\r\n<p>text</p>"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		h, err := ParseHTML(data)
+		if err != nil {
+			return
+		}
+		if !bytes.Contains(data, []byte(h.Fragment)) || !bytes.Contains(data, []byte(h.Document)) {
+			t.Fatalf("%+v is not part of the data", h)
+		}
+		again, err := ParseHTML(h.Bytes())
+		if err != nil {
+			t.Fatalf("parsing %+v again: %v", h, err)
+		}
+		if again.Fragment != h.Fragment || again.SourceURL != h.SourceURL {
+			t.Fatalf("parsed %+v, then %+v", h, again)
+		}
+	})
+}
+
+func FuzzDecodeObjectDescriptor(f *testing.F) {
+	f.Add(EncodeObjectDescriptor(&ObjectDescriptor{
+		CLSID: clsidExcelSheet, DrawAspect: DVASPECT_CONTENT, Width: 2540, Height: 1270,
+		FullUserTypeName: "Microsoft Excel Worksheet", SourceOfCopy: `C:\Book1.xlsx`,
+	}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d, err := DecodeObjectDescriptor(data)
+		if err != nil {
+			return
+		}
+		if strings.ContainsRune(d.FullUserTypeName, 0) || strings.ContainsRune(d.SourceOfCopy, 0) {
+			t.Fatalf("strings contain NUL: %+v", d)
+		}
+		again, err := DecodeObjectDescriptor(EncodeObjectDescriptor(d))
+		if err != nil {
+			t.Fatalf("decoding %+v again: %v", d, err)
+		}
+		if !reflect.DeepEqual(again, d) {
+			t.Fatalf("decoded %+v, then %+v", d, again)
+		}
+	})
+}
+
+// checkMoniker verifies the invariants of a successfully decoded moniker
+func checkMoniker(t *testing.T, m Moniker, depth int) {
+	if depth > maxMonikerDepth {
+		t.Fatalf("composite monikers nested %d deep", depth)
+	}
+	if m.Kind != MonikerComposite && len(m.Components) > 0 {
+		t.Fatalf("%s moniker with components", m.Kind)
+	}
+	for _, c := range m.Components {
+		if c.Kind == MonikerUnknown {
+			t.Fatalf("composite contains an unknown moniker")
+		}
+		checkMoniker(t, c, depth+1)
+	}
+	_ = m.String()
+	_ = m.File()
+}
+
+func FuzzDecodeMoniker(f *testing.F) {
+	f.Add(fileMoniker(1, `C:\a.txt`, `C:\ü.txt`))
+	f.Add(compositeMoniker(fileMoniker(0, `C:\Book1.xlsx`, ""), itemMoniker("!", "Sheet1")))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := DecodeMoniker(data)
+		if err != nil {
+			return
+		}
+		checkMoniker(t, m, 0)
+	})
+}
+
+func FuzzDecodeLinkSource(f *testing.F) {
+	f.Add(append(compositeMoniker(fileMoniker(0, `C:\Book1.xlsx`, ""), itemMoniker("!", "R1C1")), clsidExcelSheet[:]...))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ls, err := DecodeLinkSource(data)
+		if err != nil {
+			return
+		}
+		checkMoniker(t, ls.Moniker, 0)
+		if ls.Moniker.Kind == MonikerUnknown && ls.CLSID != ([16]byte{}) {
+			t.Fatalf("class %x after an unknown moniker", ls.CLSID)
+		}
+	})
+}
@@ -1,11 +1,10 @@
 module github.com/kirides/go-winclipboard
 
-go 1.16
+go 1.18
 
 require (
-	github.com/kirides/hwnd-go v0.0.0
+	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
 	golang.org/x/sys v0.0.0-20210415045647-66c3f260301c
 	golang.org/x/text v0.3.6
+	gopkg.in/yaml.v3 v3.0.1
 )
-
-replace github.com/kirides/hwnd-go => ../hwnd-go
@@ -0,0 +1,239 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// HTMLFormatName is the name of the registered CF_HTML clipboard format
+const HTMLFormatName = "HTML Format"
+
+var ErrInvalidHTMLFormat = errors.New("invalid HTML Format data")
+
+// HTML is the decoded content of the "HTML Format" (CF_HTML) slot
+type HTML struct {
+	// Fragment is the selected markup, between the StartFragment and EndFragment offsets
+	Fragment string
+	// Document is the full markup surrounding the fragment, might be empty
+	Document  string
+	SourceURL string
+}
+
+// ParseHTML decodes CF_HTML data, validating every offset in the header against the data.
+func ParseHTML(data []byte) (HTML, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+
+	offsets := map[string]int{
+		"StartHTML":     -1,
+		"EndHTML":       -1,
+		"StartFragment": -1,
+		"EndFragment":   -1,
+	}
+	var result HTML
+	hasVersion := false
+	rest := data
+	headerEnd := 0
+	for len(rest) > 0 && rest[0] != '<' {
+		line := rest
+		next := len(rest)
+		if i := bytes.IndexAny(rest, "\r\n"); i >= 0 {
+			line = rest[:i]
+			next = i + 1
+			if rest[i] == '\r' && i+1 < len(rest) && rest[i+1] == '\n' {
+				next++
+			}
+		}
+		rest = rest[next:]
+		headerEnd += next
+
+		sep := bytes.IndexByte(line, ':')
+		if sep < 0 {
+			break
+		}
+		key, value := string(line[:sep]), string(line[sep+1:])
+		switch key {
+		case "Version":
+			hasVersion = true
+		case "SourceURL":
+			result.SourceURL = value
+		case "StartHTML", "EndHTML", "StartFragment", "EndFragment":
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return HTML{}, fmt.Errorf("%s: %v. %w", key, err, ErrInvalidHTMLFormat)
+			}
+			offsets[key] = n
+		}
+	}
+	if !hasVersion {
+		return HTML{}, fmt.Errorf("missing Version. %w", ErrInvalidHTMLFormat)
+	}
+
+	slice := func(start, end string) (string, error) {
+		s, e := offsets[start], offsets[end]
+		if s < headerEnd || e < s || e > len(data) {
+			return "", fmt.Errorf("%s/%s (%d, %d) out of range. %w", start, end, s, e, ErrInvalidHTMLFormat)
+		}
+		return string(data[s:e]), nil
+	}
+
+	var err error
+	if result.Fragment, err = slice("StartFragment", "EndFragment"); err != nil {
+		return HTML{}, err
+	}
+	// StartHTML and EndHTML are optional and set to -1 when absent
+	if offsets["StartHTML"] >= 0 || offsets["EndHTML"] >= 0 {
+		if result.Document, err = slice("StartHTML", "EndHTML"); err != nil {
+			return HTML{}, err
+		}
+	}
+	return result, nil
+}
+
+// Bytes encodes h as CF_HTML data, wrapping the fragment in a minimal document
+func (h HTML) Bytes() []byte {
+	const (
+		startMarker = "<!--StartFragment-->"
+		endMarker   = "<!--EndFragment-->"
+		// every offset is written with 10 digits, keeping the header length fixed
+		offsetFormat = "%010d"
+	)
+	const prefix = "<html>\r\n<body>\r\n" + startMarker
+	const suffix = endMarker + "\r\n</body>\r\n</html>"
+
+	header := func(startHTML, endHTML, startFragment, endFragment int) string {
+		s := "Version:0.9\r\n" +
+			"StartHTML:" + fmt.Sprintf(offsetFormat, startHTML) + "\r\n" +
+			"EndHTML:" + fmt.Sprintf(offsetFormat, endHTML) + "\r\n" +
+			"StartFragment:" + fmt.Sprintf(offsetFormat, startFragment) + "\r\n" +
+			"EndFragment:" + fmt.Sprintf(offsetFormat, endFragment) + "\r\n"
+		if h.SourceURL != "" {
+			s += "SourceURL:" + h.SourceURL + "\r\n"
+		}
+		return s
+	}
+
+	headerLen := len(header(0, 0, 0, 0))
+	startHTML := headerLen
+	startFragment := startHTML + len(prefix)
+	endFragment := startFragment + len(h.Fragment)
+	endHTML := endFragment + len(suffix)
+
+	buf := bytes.NewBuffer(make([]byte, 0, endHTML))
+	buf.WriteString(header(startHTML, endHTML, startFragment, endFragment))
+	buf.WriteString(prefix)
+	buf.WriteString(h.Fragment)
+	buf.WriteString(suffix)
+	return buf.Bytes()
+}
+
+// blockElements start on a new line when converted to text
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"div": true, "dl": true, "dt": true, "dd": true, "fieldset": true,
+	"figure": true, "footer": true, "form": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hr": true, "li": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "table": true, "tr": true,
+	"ul": true,
+}
+
+// HTMLToText converts an HTML fragment into plain text.
+//
+// Tags are dropped, block elements and <br> become line breaks,
+// table cells are separated by tabs and entities are decoded.
+func HTMLToText(s string) string {
+	var sb strings.Builder
+	newline := func() {
+		str := sb.String()
+		if len(str) > 0 && !strings.HasSuffix(str, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			sb.WriteString(collapseSpace(html.UnescapeString(s)))
+			break
+		}
+		sb.WriteString(collapseSpace(html.UnescapeString(s[:lt])))
+		s = s[lt:]
+
+		if strings.HasPrefix(s, "<!--") {
+			end := strings.Index(s, "-->")
+			if end < 0 {
+				break
+			}
+			s = s[end+3:]
+			continue
+		}
+		gt := strings.IndexByte(s, '>')
+		if gt < 0 {
+			break
+		}
+		tag := s[1:gt]
+		s = s[gt+1:]
+
+		closing := strings.HasPrefix(tag, "/")
+		name := strings.ToLower(strings.TrimPrefix(tag, "/"))
+		if i := strings.IndexAny(name, " \t\r\n/"); i >= 0 {
+			name = name[:i]
+		}
+
+		switch {
+		case !closing && (name == "script" || name == "style"):
+			end := strings.Index(strings.ToLower(s), "</"+name)
+			if end < 0 {
+				s = ""
+				continue
+			}
+			s = s[end:]
+		case name == "br":
+			sb.WriteString("\n")
+		case name == "td" || name == "th":
+			if !closing {
+				str := sb.String()
+				if len(str) > 0 && !strings.HasSuffix(str, "\n") {
+					sb.WriteString("\t")
+				}
+			}
+		case blockElements[name]:
+			newline()
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// collapseSpace replaces runs of whitespace with a single space, like a browser would
+func collapseSpace(s string) string {
+	if strings.TrimSpace(s) == "" {
+		if s == "" || strings.ContainsAny(s, "\r\n") {
+			return ""
+		}
+		return " "
+	}
+	var sb strings.Builder
+	space := false
+	for _, r := range s {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			space = true
+			continue
+		}
+		if space {
+			sb.WriteByte(' ')
+			space = false
+		}
+		sb.WriteRune(r)
+	}
+	if space {
+		sb.WriteByte(' ')
+	}
+	return sb.String()
+}
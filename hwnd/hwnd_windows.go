@@ -0,0 +1,259 @@
+// Package hwnd creates message-only windows, which receive clipboard notifications
+// and delayed rendering requests without showing any user interface.
+package hwnd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/kirides/go-winclipboard/internal/winsys"
+	"github.com/kirides/go-winclipboard/wm"
+)
+
+var ErrClosed = errors.New("window closed")
+
+// WndProc handles a window message, see the WindowProc callback of the Win32 API
+type WndProc func(h syscall.Handle, msg uint32, wParam, lParam uintptr) uintptr
+
+// Window is a message-only window.
+//
+// The window lives on a dedicated OS thread, which also runs its message loop,
+// so every WndProc is called on that thread.
+type Window struct {
+	Handle syscall.Handle
+
+	proc     WndProc
+	mu       sync.RWMutex
+	handlers map[uint32]WndProc
+	invoke   func()
+	invokeMu sync.Mutex
+	done     chan struct{}
+	err      error
+}
+
+const className = "go-winclipboard message window"
+
+var (
+	classOnce sync.Once
+	classErr  error
+	instance  syscall.Handle
+	// invokeMsg is sent by Invoke, registered so that it never collides with messages of the application
+	invokeMsg uint32
+
+	windowsMu sync.RWMutex
+	windows   = map[syscall.Handle]*Window{}
+)
+
+func registerClass() error {
+	classOnce.Do(func() {
+		if instance, classErr = winsys.GetModuleHandle(nil); classErr != nil {
+			return
+		}
+		wc := winsys.WNDCLASSEX{
+			WndProc:   syscall.NewCallback(wndProc),
+			Instance:  instance,
+			ClassName: syscall.StringToUTF16Ptr(className),
+		}
+		wc.Size = uint32(unsafe.Sizeof(wc))
+		if _, classErr = winsys.RegisterClassEx(&wc); classErr != nil {
+			classErr = fmt.Errorf("RegisterClassEx. %w", classErr)
+			return
+		}
+		if invokeMsg, classErr = winsys.RegisterWindowMessage(syscall.StringToUTF16Ptr(className + " invoke")); classErr != nil {
+			classErr = fmt.Errorf("RegisterWindowMessage. %w", classErr)
+		}
+	})
+	return classErr
+}
+
+// New creates a message-only window, calling proc for every message without a handler registered through On.
+// A nil proc passes those messages to DefWindowProc.
+//
+// Messages sent while the window is created, like WM_CREATE, are not dispatched.
+func New(proc WndProc) (*Window, error) {
+	w := &Window{
+		proc:     proc,
+		handlers: make(map[uint32]WndProc),
+		done:     make(chan struct{}),
+	}
+	created := make(chan error, 1)
+	go w.loop(created)
+	if err := <-created; err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Window) loop(created chan<- error) {
+	// the thread is never unlocked, it terminates together with the goroutine
+	runtime.LockOSThread()
+	defer close(w.done)
+
+	if err := registerClass(); err != nil {
+		created <- err
+		return
+	}
+	h, err := winsys.CreateWindowEx(0, syscall.StringToUTF16Ptr(className), nil, 0, 0, 0, 0, 0, winsys.HWND_MESSAGE, 0, instance, 0)
+	if err != nil {
+		created <- fmt.Errorf("CreateWindowEx. %w", err)
+		return
+	}
+	w.Handle = h
+	windowsMu.Lock()
+	windows[h] = w
+	windowsMu.Unlock()
+	created <- nil
+
+	var msg winsys.MSG
+	for {
+		ret, err := winsys.GetMessage(&msg, 0, 0, 0)
+		if err != nil {
+			w.err = fmt.Errorf("GetMessage. %w", err)
+			winsys.DestroyWindow(h)
+			return
+		}
+		if ret == 0 {
+			return
+		}
+		winsys.TranslateMessage(&msg)
+		winsys.DispatchMessage(&msg)
+	}
+}
+
+func wndProc(h, msg, wParam, lParam uintptr) uintptr {
+	windowsMu.RLock()
+	w := windows[syscall.Handle(h)]
+	windowsMu.RUnlock()
+	if w == nil {
+		return winsys.DefWindowProc(syscall.Handle(h), uint32(msg), wParam, lParam)
+	}
+	return w.dispatch(uint32(msg), wParam, lParam)
+}
+
+func (w *Window) dispatch(msg uint32, wParam, lParam uintptr) uintptr {
+	switch msg {
+	case wm.CLOSE:
+		// posted by Close, handlers must not be able to keep the window alive
+		winsys.DestroyWindow(w.Handle)
+		return 0
+	case wm.NCDESTROY:
+		windowsMu.Lock()
+		delete(windows, w.Handle)
+		windowsMu.Unlock()
+		winsys.PostQuitMessage(0)
+	case invokeMsg:
+		w.mu.RLock()
+		fn := w.invoke
+		w.mu.RUnlock()
+		if fn != nil {
+			fn()
+			return 0
+		}
+	}
+
+	w.mu.RLock()
+	fn, ok := w.handlers[msg]
+	w.mu.RUnlock()
+	if !ok {
+		fn = w.proc
+	}
+	if fn == nil {
+		return DefWindowProc(w.Handle, msg, wParam, lParam)
+	}
+	return fn(w.Handle, msg, wParam, lParam)
+}
+
+// On registers fn for msg, replacing the proc passed to New for this message. A nil fn removes the handler.
+//
+//	w.On(wm.CLIPBOARDUPDATE, func(h syscall.Handle, msg uint32, wParam, lParam uintptr) uintptr { ...; return 0 })
+func (w *Window) On(msg uint32, fn WndProc) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if fn == nil {
+		delete(w.handlers, msg)
+		return
+	}
+	w.handlers[msg] = fn
+}
+
+// Invoke calls fn on the thread of the window and waits for it to return.
+// No other message is dispatched while fn runs, unless fn sends one to the window itself.
+// It must not be called from fn.
+func (w *Window) Invoke(fn func()) error {
+	w.invokeMu.Lock()
+	defer w.invokeMu.Unlock()
+	called := make(chan struct{})
+	w.mu.Lock()
+	w.invoke = func() {
+		fn()
+		close(called)
+	}
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.invoke = nil
+		w.mu.Unlock()
+	}()
+
+	// SendMessage fails right away for a destroyed window
+	winsys.SendMessage(w.Handle, invokeMsg, 0, 0)
+	select {
+	case <-called:
+		return nil
+	default:
+		return ErrClosed
+	}
+}
+
+// Done is closed once the message loop ended
+func (w *Window) Done() <-chan struct{} {
+	return w.done
+}
+
+// Close destroys the window and waits for its message loop to end.
+// It must not be called from a WndProc of w.
+func (w *Window) Close() error {
+	select {
+	case <-w.done:
+		return nil
+	default:
+	}
+	if err := winsys.PostMessage(w.Handle, wm.CLOSE, 0, 0); err != nil {
+		// the window might have been destroyed in the meantime
+		select {
+		case <-w.done:
+			return nil
+		default:
+			return fmt.Errorf("PostMessage. %w", err)
+		}
+	}
+	<-w.done
+	return nil
+}
+
+// ProcessMessagesContext blocks until ctx is done, then closes the window.
+// It returns early if the message loop failed or the window was destroyed.
+func (w *Window) ProcessMessagesContext(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		if err := w.Close(); err != nil {
+			return err
+		}
+		return ctx.Err()
+	case <-w.done:
+		if w.err != nil {
+			return w.err
+		}
+		return ErrClosed
+	}
+}
+
+// DefWindowProc performs the default handling of a message
+func DefWindowProc(h syscall.Handle, msg uint32, wParam, lParam uintptr) uintptr {
+	return winsys.DefWindowProc(h, msg, wParam, lParam)
+}
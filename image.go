@@ -0,0 +1,102 @@
+package clipboard
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// imageFormats are the formats GetImageFrom reads, best representation first
+var imageFormats = []struct {
+	name   string
+	decode func(data []byte) (image.Image, error)
+}{
+	{PNGFormatName, decodeImage(png.Decode)},
+	{"image/png", decodeImage(png.Decode)},
+	{GIFFormatName, decodeImage(gif.Decode)},
+	{JFIFFormatName, decodeImage(jpeg.Decode)},
+	{"CF_DIBV5", DecodeDIB},
+	{"CF_DIB", DecodeDIB},
+}
+
+// GetImageFrom returns the image stored in b.
+//
+// Registered formats like "PNG" are preferred over CF_DIBV5 and CF_DIB, as they keep transparency.
+// If the data of a format can not be decoded, the next one is tried.
+func GetImageFrom(b Backend) (image.Image, error) {
+	ids, err := b.Formats()
+	if err != nil {
+		return nil, err
+	}
+	available := make(map[string]Format, len(ids))
+	for _, id := range ids {
+		if name, err := b.FormatName(id); err == nil {
+			available[name] = id
+		}
+	}
+
+	err = fmt.Errorf("no image. %w", ErrUnknownClipboardFormat)
+	for _, f := range imageFormats {
+		id, ok := available[f.name]
+		if !ok {
+			continue
+		}
+		var data []byte
+		if data, err = b.Data(id); err != nil {
+			continue
+		}
+		var img image.Image
+		if img, err = f.decode(data); err == nil {
+			return img, nil
+		}
+		err = fmt.Errorf("decoding %s. %w", f.name, err)
+	}
+	return nil, err
+}
+
+// SetImageTo replaces the contents of b with img,
+// stored as PNG, CF_DIBV5 and CF_DIB so every consumer finds a suitable representation
+func SetImageTo(b Backend, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	id, err := b.RegisterFormat(PNGFormatName)
+	if err != nil {
+		return err
+	}
+	return b.Write([]Item{
+		{Format: id, Data: buf.Bytes()},
+		{Format: CF_DIBV5, Data: EncodeDIBV5(img)},
+		{Format: CF_DIB, Data: EncodeDIB(img)},
+	})
+}
+
+// maxImagePixels bounds the size of decoded PNG, GIF and JPEG images, whose data can be far smaller than their pixels
+const maxImagePixels = 1 << 27
+
+func decodeImage(decode func(io.Reader) (image.Image, error)) func([]byte) (image.Image, error) {
+	return func(data []byte) (image.Image, error) {
+		if err := checkImageSize(data); err != nil {
+			return nil, err
+		}
+		return decode(bytes.NewReader(data))
+	}
+}
+
+// checkImageSize rejects images of a registered codec exceeding maxImagePixels, before any pixels are decoded.
+// Data of other formats is left to its decoder.
+func checkImageSize(data []byte) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	if cfg.Width < 0 || cfg.Height < 0 || uint64(cfg.Width)*uint64(cfg.Height) > maxImagePixels {
+		return fmt.Errorf("image of %dx%d pixels. %w", cfg.Width, cfg.Height, ErrTooLarge)
+	}
+	return nil
+}
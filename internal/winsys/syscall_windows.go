@@ -35,6 +35,38 @@ type (
 	KNOWNFOLDERID windows.GUID
 )
 
+// HWND_MESSAGE is the parent of message-only windows
+const HWND_MESSAGE = ^syscall.Handle(2)
+
+// WNDCLASSEX is WNDCLASSEXW
+type WNDCLASSEX struct {
+	Size       uint32
+	Style      uint32
+	WndProc    uintptr
+	ClsExtra   int32
+	WndExtra   int32
+	Instance   syscall.Handle
+	Icon       syscall.Handle
+	Cursor     syscall.Handle
+	Background syscall.Handle
+	MenuName   *uint16
+	ClassName  *uint16
+	IconSm     syscall.Handle
+}
+
+type POINT struct {
+	X, Y int32
+}
+
+type MSG struct {
+	Hwnd    syscall.Handle
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      POINT
+}
+
 var (
 	_S_OK    = uintptr(0)
 	_S_FALSE = uintptr(1)
@@ -71,8 +103,24 @@ func (hr HRESULT) Error() string {
 //sys	IsClipboardFormatAvailable(uFormat uint32) (err error) = User32.IsClipboardFormatAvailable
 //sys	AddClipboardFormatListener(hWnd syscall.Handle) (err error) = User32.AddClipboardFormatListener
 //sys	RemoveClipboardFormatListener(hWnd syscall.Handle) (err error) = User32.RemoveClipboardFormatListener
+//sys	GetClipboardOwner() (h syscall.Handle, err error) = User32.GetClipboardOwner
+//sys	GetClipboardSequenceNumber() (n uint32) = User32.GetClipboardSequenceNumber
+//sys	RegisterClassEx(wc *WNDCLASSEX) (atom uint16, err error) = User32.RegisterClassExW
+//sys	CreateWindowEx(exStyle uint32, className *uint16, windowName *uint16, style uint32, x int32, y int32, width int32, height int32, parent syscall.Handle, menu syscall.Handle, instance syscall.Handle, param uintptr) (h syscall.Handle, err error) = User32.CreateWindowExW
+//sys	DestroyWindow(hWnd syscall.Handle) (err error) = User32.DestroyWindow
+//sys	DefWindowProc(hWnd syscall.Handle, msg uint32, wParam uintptr, lParam uintptr) (ret uintptr) = User32.DefWindowProcW
+//sys	GetMessage(msg *MSG, hWnd syscall.Handle, msgFilterMin uint32, msgFilterMax uint32) (ret int32, err error) [failretval==-1] = User32.GetMessageW
+//sys	TranslateMessage(msg *MSG) (ok bool) = User32.TranslateMessage
+//sys	DispatchMessage(msg *MSG) (ret uintptr) = User32.DispatchMessageW
+//sys	PostMessage(hWnd syscall.Handle, msg uint32, wParam uintptr, lParam uintptr) (err error) = User32.PostMessageW
+//sys	PostQuitMessage(exitCode int32) = User32.PostQuitMessage
+//sys	RegisterWindowMessage(name *uint16) (msg uint32, err error) = User32.RegisterWindowMessageW
+//sys	SendMessage(hWnd syscall.Handle, msg uint32, wParam uintptr, lParam uintptr) (ret uintptr) = User32.SendMessageW
+//sys	SetClipboardViewer(hWndNewViewer syscall.Handle) (next syscall.Handle) = User32.SetClipboardViewer
+//sys	ChangeClipboardChain(hWndRemove syscall.Handle, hWndNewNext syscall.Handle) (ok bool) = User32.ChangeClipboardChain
 
 // --- Kernel32 ---
+//sys	GetModuleHandle(moduleName *uint16) (h syscall.Handle, err error) = Kernel32.GetModuleHandleW
 //sys	GetProcessHeap() (hHeap syscall.Handle, err error) = Kernel32.GetProcessHeap
 //sys	HeapAlloc(hHeap syscall.Handle, dwFlags uint32, dwSize uintptr) (lpMem uintptr, err error) = Kernel32.HeapAlloc
 //sys	HeapFree(hHeap syscall.Handle, dwFlags uint32, lpMem uintptr) (err error) = Kernel32.HeapFree
@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package winsys
 
 import (
@@ -5,6 +8,8 @@ import (
 	"io"
 	"syscall"
 	"unsafe"
+
+	"golang.org/x/sys/windows"
 )
 
 type iUnknownVtbl struct {
@@ -130,7 +135,8 @@ func (m STGMEDIUM) Stream() (*IStream, error) {
 	if m.Tymed != TymedISTREAM {
 		return nil, fmt.Errorf("invalid Tymed")
 	}
-	return (*IStream)(unsafe.Pointer(m.UnionMember)), nil
+	// UnionMember holds the interface pointer, read it as one instead of converting a uintptr
+	return *(**IStream)(unsafe.Pointer(&m.UnionMember)), nil
 }
 
 func (m STGMEDIUM) Bytes() ([]byte, error) {
@@ -142,9 +148,14 @@ func (m STGMEDIUM) Bytes() ([]byte, error) {
 		return nil, fmt.Errorf("could not determine size")
 	}
 
+	// the array type below limits the size, larger memory objects would panic
+	if size > 1<<30 {
+		return nil, fmt.Errorf("memory object of %d bytes is too large", size)
+	}
+
 	lpMem, err := GlobalLock(m.UnionMember)
 	if err != nil {
-		return nil, fmt.Errorf("could not determine size")
+		return nil, fmt.Errorf("could not lock memory")
 	}
 	defer GlobalUnlock(m.UnionMember)
 
@@ -275,3 +286,213 @@ func (obj *IEnumFORMATETC) Next(formatEtc []FORMATETC, celtFetched *uint32) erro
 	}
 	return nil
 }
+
+func (m STGMEDIUM) Storage() (*IStorage, error) {
+	if m.Tymed != TymedISTORAGE {
+		return nil, fmt.Errorf("invalid Tymed")
+	}
+	return *(**IStorage)(unsafe.Pointer(&m.UnionMember)), nil
+}
+
+// STATSTG types
+const (
+	STGTY_STORAGE = 1
+	STGTY_STREAM  = 2
+)
+
+const (
+	STGM_READ            = 0x00000000
+	STGM_SHARE_EXCLUSIVE = 0x00000010
+
+	STATFLAG_DEFAULT = 0
+	STATFLAG_NONAME  = 1
+)
+
+type STATSTG struct {
+	Name              *uint16
+	Type              uint32
+	Size              uint64
+	Mtime             windows.Filetime
+	Ctime             windows.Filetime
+	Atime             windows.Filetime
+	GrfMode           uint32
+	GrfLocksSupported uint32
+	Clsid             windows.GUID
+	GrfStateBits      uint32
+	Reserved          uint32
+}
+
+// TakeName returns the name and frees the memory allocated for it
+func (s *STATSTG) TakeName() string {
+	if s.Name == nil {
+		return ""
+	}
+	name := windows.UTF16PtrToString(s.Name)
+	windows.CoTaskMemFree(unsafe.Pointer(s.Name))
+	s.Name = nil
+	return name
+}
+
+type iStorageVtbl struct {
+	iUnknownVtbl
+	CreateStream    uintptr
+	OpenStream      uintptr
+	CreateStorage   uintptr
+	OpenStorage     uintptr
+	CopyTo          uintptr
+	MoveElementTo   uintptr
+	Commit          uintptr
+	Revert          uintptr
+	EnumElements    uintptr
+	DestroyElement  uintptr
+	RenameElement   uintptr
+	SetElementTimes uintptr
+	SetClass        uintptr
+	SetStateBits    uintptr
+	Stat            uintptr
+}
+
+type IStorage struct {
+	vtbl *iStorageVtbl
+}
+
+func (obj *IStorage) Release() error {
+	ret, _, _ := syscall.Syscall(
+		obj.vtbl.Release,
+		1,
+		uintptr(unsafe.Pointer(obj)),
+		0,
+		0,
+	)
+	if ret != _S_OK {
+		return HRESULT(ret)
+	}
+	return nil
+}
+
+func (obj *IStorage) OpenStream(name string) (*IStream, error) {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	var stream *IStream
+	ret, _, _ := syscall.Syscall6(
+		obj.vtbl.OpenStream,
+		6,
+		uintptr(unsafe.Pointer(obj)),
+		uintptr(unsafe.Pointer(p)),
+		0,
+		STGM_READ|STGM_SHARE_EXCLUSIVE,
+		0,
+		uintptr(unsafe.Pointer(&stream)),
+	)
+	if ret != _S_OK {
+		return nil, HRESULT(ret)
+	}
+	return stream, nil
+}
+
+func (obj *IStorage) OpenStorage(name string) (*IStorage, error) {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	var storage *IStorage
+	ret, _, _ := syscall.Syscall9(
+		obj.vtbl.OpenStorage,
+		7,
+		uintptr(unsafe.Pointer(obj)),
+		uintptr(unsafe.Pointer(p)),
+		0,
+		STGM_READ|STGM_SHARE_EXCLUSIVE,
+		0,
+		0,
+		uintptr(unsafe.Pointer(&storage)),
+		0,
+		0,
+	)
+	if ret != _S_OK {
+		return nil, HRESULT(ret)
+	}
+	return storage, nil
+}
+
+func (obj *IStorage) EnumElements() (*IEnumSTATSTG, error) {
+	var enum *IEnumSTATSTG
+	ret, _, _ := syscall.Syscall6(
+		obj.vtbl.EnumElements,
+		5,
+		uintptr(unsafe.Pointer(obj)),
+		0,
+		0,
+		0,
+		uintptr(unsafe.Pointer(&enum)),
+		0,
+	)
+	if ret != _S_OK {
+		return nil, HRESULT(ret)
+	}
+	return enum, nil
+}
+
+func (obj *IStorage) Stat(stat *STATSTG, flags uint32) error {
+	ret, _, _ := syscall.Syscall(
+		obj.vtbl.Stat,
+		3,
+		uintptr(unsafe.Pointer(obj)),
+		uintptr(unsafe.Pointer(stat)),
+		uintptr(flags),
+	)
+	if ret != _S_OK {
+		return HRESULT(ret)
+	}
+	return nil
+}
+
+type iEnumSTATSTGVtbl struct {
+	iUnknownVtbl
+	Next  uintptr
+	Skip  uintptr
+	Reset uintptr
+	Clone uintptr
+}
+
+type IEnumSTATSTG struct {
+	vtbl *iEnumSTATSTGVtbl
+}
+
+func (obj *IEnumSTATSTG) Release() error {
+	ret, _, _ := syscall.Syscall(
+		obj.vtbl.Release,
+		1,
+		uintptr(unsafe.Pointer(obj)),
+		0,
+		0,
+	)
+	if ret != _S_OK {
+		return HRESULT(ret)
+	}
+	return nil
+}
+
+// Next fills stat with the next element, io.EOF is returned once all elements were enumerated
+func (obj *IEnumSTATSTG) Next(stat *STATSTG) error {
+	var fetched uint32
+	ret, _, _ := syscall.Syscall6(
+		obj.vtbl.Next,
+		4,
+		uintptr(unsafe.Pointer(obj)),
+		1,
+		uintptr(unsafe.Pointer(stat)),
+		uintptr(unsafe.Pointer(&fetched)),
+		0,
+		0,
+	)
+	if ret != _S_OK && ret != _S_FALSE {
+		return HRESULT(ret)
+	}
+	if fetched == 0 {
+		return io.EOF
+	}
+	return nil
+}
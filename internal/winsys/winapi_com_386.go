@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package winsys
 
 type FORMATETC struct {
@@ -12,5 +15,5 @@ type FORMATETC struct {
 type STGMEDIUM struct {
 	Tymed          Tymed
 	UnionMember    uintptr
-	PUnkForRelease uintptr
+	PUnkForRelease *IUnknown
 }
@@ -43,6 +43,7 @@ var (
 	modShell32  = windows.NewLazySystemDLL("Shell32.dll")
 	modUser32   = windows.NewLazySystemDLL("User32.dll")
 
+	procGetModuleHandleW              = modKernel32.NewProc("GetModuleHandleW")
 	procGetProcessHeap                = modKernel32.NewProc("GetProcessHeap")
 	procGlobalLock                    = modKernel32.NewProc("GlobalLock")
 	procGlobalSize                    = modKernel32.NewProc("GlobalSize")
@@ -58,19 +59,43 @@ var (
 	procSHGetKnownFolderPath          = modShell32.NewProc("SHGetKnownFolderPath")
 	procSHGetPathFromIDListEx         = modShell32.NewProc("SHGetPathFromIDListEx")
 	procAddClipboardFormatListener    = modUser32.NewProc("AddClipboardFormatListener")
+	procChangeClipboardChain          = modUser32.NewProc("ChangeClipboardChain")
 	procCloseClipboard                = modUser32.NewProc("CloseClipboard")
+	procCreateWindowExW               = modUser32.NewProc("CreateWindowExW")
+	procDefWindowProcW                = modUser32.NewProc("DefWindowProcW")
+	procDestroyWindow                 = modUser32.NewProc("DestroyWindow")
+	procDispatchMessageW              = modUser32.NewProc("DispatchMessageW")
 	procEmptyClipboard                = modUser32.NewProc("EmptyClipboard")
 	procEnumClipboardFormats          = modUser32.NewProc("EnumClipboardFormats")
 	procGetClipboardData              = modUser32.NewProc("GetClipboardData")
 	procGetClipboardFormatNameW       = modUser32.NewProc("GetClipboardFormatNameW")
+	procGetClipboardOwner             = modUser32.NewProc("GetClipboardOwner")
+	procGetClipboardSequenceNumber    = modUser32.NewProc("GetClipboardSequenceNumber")
+	procGetMessageW                   = modUser32.NewProc("GetMessageW")
 	procIsClipboardFormatAvailable    = modUser32.NewProc("IsClipboardFormatAvailable")
 	procOpenClipboard                 = modUser32.NewProc("OpenClipboard")
+	procPostMessageW                  = modUser32.NewProc("PostMessageW")
+	procPostQuitMessage               = modUser32.NewProc("PostQuitMessage")
+	procRegisterClassExW              = modUser32.NewProc("RegisterClassExW")
 	procRegisterClipboardFormatW      = modUser32.NewProc("RegisterClipboardFormatW")
+	procRegisterWindowMessageW        = modUser32.NewProc("RegisterWindowMessageW")
 	procRemoveClipboardFormatListener = modUser32.NewProc("RemoveClipboardFormatListener")
+	procSendMessageW                  = modUser32.NewProc("SendMessageW")
 	procSetClipboardData              = modUser32.NewProc("SetClipboardData")
+	procSetClipboardViewer            = modUser32.NewProc("SetClipboardViewer")
 	procSetWindowsHookExW             = modUser32.NewProc("SetWindowsHookExW")
+	procTranslateMessage              = modUser32.NewProc("TranslateMessage")
 )
 
+func GetModuleHandle(moduleName *uint16) (h syscall.Handle, err error) {
+	r0, _, e1 := syscall.Syscall(procGetModuleHandleW.Addr(), 1, uintptr(unsafe.Pointer(moduleName)), 0, 0)
+	h = syscall.Handle(r0)
+	if h == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func GetProcessHeap() (hHeap syscall.Handle, err error) {
 	r0, _, e1 := syscall.Syscall(procGetProcessHeap.Addr(), 0, 0, 0, 0)
 	hHeap = syscall.Handle(r0)
@@ -198,6 +223,12 @@ func AddClipboardFormatListener(hWnd syscall.Handle) (err error) {
 	return
 }
 
+func ChangeClipboardChain(hWndRemove syscall.Handle, hWndNewNext syscall.Handle) (ok bool) {
+	r0, _, _ := syscall.Syscall(procChangeClipboardChain.Addr(), 2, uintptr(hWndRemove), uintptr(hWndNewNext), 0)
+	ok = r0 != 0
+	return
+}
+
 func CloseClipboard() (err error) {
 	r1, _, e1 := syscall.Syscall(procCloseClipboard.Addr(), 0, 0, 0, 0)
 	if r1 == 0 {
@@ -206,6 +237,35 @@ func CloseClipboard() (err error) {
 	return
 }
 
+func CreateWindowEx(exStyle uint32, className *uint16, windowName *uint16, style uint32, x int32, y int32, width int32, height int32, parent syscall.Handle, menu syscall.Handle, instance syscall.Handle, param uintptr) (h syscall.Handle, err error) {
+	r0, _, e1 := syscall.Syscall12(procCreateWindowExW.Addr(), 12, uintptr(exStyle), uintptr(unsafe.Pointer(className)), uintptr(unsafe.Pointer(windowName)), uintptr(style), uintptr(x), uintptr(y), uintptr(width), uintptr(height), uintptr(parent), uintptr(menu), uintptr(instance), uintptr(param))
+	h = syscall.Handle(r0)
+	if h == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
+func DefWindowProc(hWnd syscall.Handle, msg uint32, wParam uintptr, lParam uintptr) (ret uintptr) {
+	r0, _, _ := syscall.Syscall6(procDefWindowProcW.Addr(), 4, uintptr(hWnd), uintptr(msg), uintptr(wParam), uintptr(lParam), 0, 0)
+	ret = uintptr(r0)
+	return
+}
+
+func DestroyWindow(hWnd syscall.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procDestroyWindow.Addr(), 1, uintptr(hWnd), 0, 0)
+	if r1 == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
+func DispatchMessage(msg *MSG) (ret uintptr) {
+	r0, _, _ := syscall.Syscall(procDispatchMessageW.Addr(), 1, uintptr(unsafe.Pointer(msg)), 0, 0)
+	ret = uintptr(r0)
+	return
+}
+
 func EmptyClipboard() (err error) {
 	r1, _, e1 := syscall.Syscall(procEmptyClipboard.Addr(), 0, 0, 0, 0)
 	if r1 == 0 {
@@ -241,6 +301,30 @@ func GetClipboardFormatName(format uint32, lpszFormatName *uint16, cchMaxCount i
 	return
 }
 
+func GetClipboardOwner() (h syscall.Handle, err error) {
+	r0, _, e1 := syscall.Syscall(procGetClipboardOwner.Addr(), 0, 0, 0, 0)
+	h = syscall.Handle(r0)
+	if h == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
+func GetClipboardSequenceNumber() (n uint32) {
+	r0, _, _ := syscall.Syscall(procGetClipboardSequenceNumber.Addr(), 0, 0, 0, 0)
+	n = uint32(r0)
+	return
+}
+
+func GetMessage(msg *MSG, hWnd syscall.Handle, msgFilterMin uint32, msgFilterMax uint32) (ret int32, err error) {
+	r0, _, e1 := syscall.Syscall6(procGetMessageW.Addr(), 4, uintptr(unsafe.Pointer(msg)), uintptr(hWnd), uintptr(msgFilterMin), uintptr(msgFilterMax), 0, 0)
+	ret = int32(r0)
+	if ret == -1 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func IsClipboardFormatAvailable(uFormat uint32) (err error) {
 	r1, _, e1 := syscall.Syscall(procIsClipboardFormatAvailable.Addr(), 1, uintptr(uFormat), 0, 0)
 	if r1 == 0 {
@@ -257,6 +341,28 @@ func OpenClipboard(h syscall.Handle) (err error) {
 	return
 }
 
+func PostMessage(hWnd syscall.Handle, msg uint32, wParam uintptr, lParam uintptr) (err error) {
+	r1, _, e1 := syscall.Syscall6(procPostMessageW.Addr(), 4, uintptr(hWnd), uintptr(msg), uintptr(wParam), uintptr(lParam), 0, 0)
+	if r1 == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
+func PostQuitMessage(exitCode int32) {
+	syscall.Syscall(procPostQuitMessage.Addr(), 1, uintptr(exitCode), 0, 0)
+	return
+}
+
+func RegisterClassEx(wc *WNDCLASSEX) (atom uint16, err error) {
+	r0, _, e1 := syscall.Syscall(procRegisterClassExW.Addr(), 1, uintptr(unsafe.Pointer(wc)), 0, 0)
+	atom = uint16(r0)
+	if atom == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func RegisterClipboardFormat(name string) (id uint32, err error) {
 	var _p0 *uint16
 	_p0, err = syscall.UTF16PtrFromString(name)
@@ -275,6 +381,15 @@ func _RegisterClipboardFormat(name *uint16) (id uint32, err error) {
 	return
 }
 
+func RegisterWindowMessage(name *uint16) (msg uint32, err error) {
+	r0, _, e1 := syscall.Syscall(procRegisterWindowMessageW.Addr(), 1, uintptr(unsafe.Pointer(name)), 0, 0)
+	msg = uint32(r0)
+	if msg == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func RemoveClipboardFormatListener(hWnd syscall.Handle) (err error) {
 	r1, _, e1 := syscall.Syscall(procRemoveClipboardFormatListener.Addr(), 1, uintptr(hWnd), 0, 0)
 	if r1 == 0 {
@@ -283,6 +398,12 @@ func RemoveClipboardFormatListener(hWnd syscall.Handle) (err error) {
 	return
 }
 
+func SendMessage(hWnd syscall.Handle, msg uint32, wParam uintptr, lParam uintptr) (ret uintptr) {
+	r0, _, _ := syscall.Syscall6(procSendMessageW.Addr(), 4, uintptr(hWnd), uintptr(msg), uintptr(wParam), uintptr(lParam), 0, 0)
+	ret = uintptr(r0)
+	return
+}
+
 func SetClipboardData(uFormat uint32, hMem syscall.Handle) (h syscall.Handle, err error) {
 	r0, _, e1 := syscall.Syscall(procSetClipboardData.Addr(), 2, uintptr(uFormat), uintptr(hMem), 0)
 	h = syscall.Handle(r0)
@@ -292,6 +413,12 @@ func SetClipboardData(uFormat uint32, hMem syscall.Handle) (h syscall.Handle, er
 	return
 }
 
+func SetClipboardViewer(hWndNewViewer syscall.Handle) (next syscall.Handle) {
+	r0, _, _ := syscall.Syscall(procSetClipboardViewer.Addr(), 1, uintptr(hWndNewViewer), 0, 0)
+	next = syscall.Handle(r0)
+	return
+}
+
 func setWindowsHookExW(idHook int32, lpfn unsafe.Pointer, hmod syscall.Handle, dwThreadId uint32) (h syscall.Handle, err error) {
 	r0, _, e1 := syscall.Syscall6(procSetWindowsHookExW.Addr(), 4, uintptr(idHook), uintptr(lpfn), uintptr(hmod), uintptr(dwThreadId), 0, 0)
 	h = syscall.Handle(r0)
@@ -300,3 +427,9 @@ func setWindowsHookExW(idHook int32, lpfn unsafe.Pointer, hmod syscall.Handle, d
 	}
 	return
 }
+
+func TranslateMessage(msg *MSG) (ok bool) {
+	r0, _, _ := syscall.Syscall(procTranslateMessage.Addr(), 1, uintptr(unsafe.Pointer(msg)), 0, 0)
+	ok = r0 != 0
+	return
+}
@@ -0,0 +1,106 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooLarge is returned when clipboard data exceeds a limit of ReadOptions
+var ErrTooLarge = errors.New("clipboard data exceeds size limit")
+
+// Default limits of GetFormatData and TakeSnapshot
+const (
+	DefaultMaxFormatSize = 64 << 20
+	DefaultMaxTotalSize  = 256 << 20
+)
+
+// DefaultReadOptions returns the limits TakeSnapshot reads with
+func DefaultReadOptions() ReadOptions {
+	return ReadOptions{MaxFormatSize: DefaultMaxFormatSize, MaxTotalSize: DefaultMaxTotalSize}
+}
+
+// ReadOptions limit the memory spent on clipboard data, so that a huge copy can not exhaust a long running process.
+// Zero values mean no limit.
+type ReadOptions struct {
+	// MaxFormatSize limits the data of a single format
+	MaxFormatSize int64
+	// FormatLimits overrides MaxFormatSize for formats by name, e.g. "PNG"
+	FormatLimits map[string]int64
+	// MaxTotalSize limits the data of all formats read together, e.g. for a Snapshot
+	MaxTotalSize int64
+}
+
+// FormatLimit returns the limit for the format called name, zero if there is none
+func (o ReadOptions) FormatLimit(name string) int64 {
+	if l, ok := o.FormatLimits[name]; ok {
+		return l
+	}
+	return o.MaxFormatSize
+}
+
+// LimitedBackend is implemented by backends that can check the size of data before copying it
+type LimitedBackend interface {
+	// DataLimit is Data, failing with ErrTooLarge instead of copying more than limit bytes.
+	// A limit of zero or less means no limit.
+	DataLimit(id Format, limit int64) ([]byte, error)
+}
+
+// ReadData returns the data of id from b, failing with ErrTooLarge if it is larger than limit.
+// Backends not implementing LimitedBackend are read completely before the size is checked.
+func ReadData(b Backend, id Format, limit int64) ([]byte, error) {
+	if lb, ok := b.(LimitedBackend); ok {
+		return lb.DataLimit(id, limit)
+	}
+	data, err := b.Data(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSize(int64(len(data)), limit); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func checkSize(size, limit int64) error {
+	if limit > 0 && size > limit {
+		return fmt.Errorf("%d bytes, limit is %d. %w", size, limit, ErrTooLarge)
+	}
+	return nil
+}
+
+// readBudget tracks the data read with the same ReadOptions
+type readBudget struct {
+	opts ReadOptions
+	used int64
+}
+
+// limit returns the number of bytes the format called name might still use, zero if unlimited
+func (r *readBudget) limit(name string) int64 {
+	limit := r.opts.FormatLimit(name)
+	if r.opts.MaxTotalSize <= 0 {
+		return limit
+	}
+	left := r.opts.MaxTotalSize - r.used
+	if left <= 0 {
+		// a limit of zero would mean none
+		return -1
+	}
+	if limit <= 0 || left < limit {
+		return left
+	}
+	return limit
+}
+
+// read returns the data of id, the budget is only charged for successful reads
+func (r *readBudget) read(b Backend, id Format, name string) ([]byte, error) {
+	limit := r.limit(name)
+	if limit < 0 {
+		return nil, fmt.Errorf("total limit of %d bytes reached. %w", r.opts.MaxTotalSize, ErrTooLarge)
+	}
+	data, err := ReadData(b, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	r.used += int64(len(data))
+	return data, nil
+}
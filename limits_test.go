@@ -0,0 +1,93 @@
+package clipboard
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTakeSnapshotLimits(t *testing.T) {
+	b := NewMemoryBackend()
+	var items []Item
+	for _, v := range []struct {
+		name string
+		size int
+	}{{"A", 10}, {"B", 20}, {"C", 30}} {
+		id, err := b.RegisterFormat(v.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, Item{Format: id, Data: make([]byte, v.size)})
+	}
+	if err := b.Write(items); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    ReadOptions
+		read    []string
+		omitted []string
+	}{
+		{"unlimited", ReadOptions{}, []string{"A", "B", "C"}, nil},
+		{"format limit", ReadOptions{MaxFormatSize: 20}, []string{"A", "B"}, []string{"C"}},
+		{"format override", ReadOptions{MaxFormatSize: 20, FormatLimits: map[string]int64{"C": 30, "A": 5}}, []string{"B", "C"}, []string{"A"}},
+		{"total limit", ReadOptions{MaxTotalSize: 35}, []string{"A", "B"}, []string{"C"}},
+		// the total is exhausted exactly, later formats are omitted rather than unlimited
+		{"total exhausted", ReadOptions{MaxTotalSize: 30}, []string{"A", "B"}, []string{"C"}},
+		{"both", ReadOptions{MaxFormatSize: 15, MaxTotalSize: 40}, []string{"A"}, []string{"B", "C"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := TakeSnapshotWithOptions(b, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var read, omitted []string
+			for _, f := range s.Formats {
+				read = append(read, f.Name)
+			}
+			for _, f := range s.Omitted {
+				omitted = append(omitted, f.Name)
+			}
+			if !reflect.DeepEqual(read, tt.read) || !reflect.DeepEqual(omitted, tt.omitted) {
+				t.Errorf("read %v, omitted %v, want %v and %v", read, omitted, tt.read, tt.omitted)
+			}
+		})
+	}
+}
+
+func TestTakeSnapshotDefaultLimits(t *testing.T) {
+	b := NewMemoryBackend()
+	big, err := b.RegisterFormat("Big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Write([]Item{{Format: CF_UNICODETEXT, Data: []byte("a\x00\x00\x00")}, {Format: big, Data: make([]byte, DefaultMaxFormatSize+1)}}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := TakeSnapshot(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Omitted) != 1 || s.Omitted[0].Name != "Big" {
+		t.Errorf("omitted %v, want Big", s.Omitted)
+	}
+	if _, ok := s.Item("CF_UNICODETEXT"); !ok {
+		t.Error("CF_UNICODETEXT is missing")
+	}
+}
+
+func TestReadData(t *testing.T) {
+	b := NewMemoryBackend()
+	if err := b.Write([]Item{{Format: CF_UNICODETEXT, Data: []byte("a\x00b\x00\x00\x00")}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadData(b, CF_UNICODETEXT, 5); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("got %v, want ErrTooLarge", err)
+	}
+	// synthesized formats are checked after the conversion
+	if data, err := ReadData(b, CF_TEXT, 3); err != nil || string(data) != "ab\x00" {
+		t.Errorf("CF_TEXT = %q, %v", data, err)
+	}
+}
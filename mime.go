@@ -0,0 +1,220 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var ErrNoMIMEMapping = errors.New("no MIME mapping")
+
+// MIMEMapping translates the data of a clipboard format from and to a MIME type
+type MIMEMapping struct {
+	// Format is the name of the clipboard format, e.g. "CF_UNICODETEXT" or "HTML Format"
+	Format string
+	// MIMEType is the media type including parameters, e.g. "text/plain;charset=utf-8"
+	MIMEType string
+	// ToMIME converts clipboard data into MIME data, nil keeps the data unchanged
+	ToMIME func(data []byte) ([]byte, error)
+	// FromMIME converts MIME data into clipboard data, nil keeps the data unchanged
+	FromMIME func(data []byte) ([]byte, error)
+}
+
+var (
+	mimeMu       sync.RWMutex
+	mimeMappings = []MIMEMapping{
+		{Format: "CF_UNICODETEXT", MIMEType: "text/plain;charset=utf-8", ToMIME: unicodeTextToMIME, FromMIME: unicodeTextFromMIME},
+		{Format: HTMLFormatName, MIMEType: "text/html", ToMIME: htmlToMIME, FromMIME: htmlFromMIME},
+		{Format: RTFFormatName, MIMEType: "text/rtf"},
+		{Format: PNGFormatName, MIMEType: "image/png"},
+		{Format: GIFFormatName, MIMEType: "image/gif"},
+		{Format: JFIFFormatName, MIMEType: "image/jpeg"},
+		{Format: "CF_DIB", MIMEType: "image/bmp", ToMIME: DIBToBMP, FromMIME: BMPToDIB},
+		{Format: "CF_HDROP", MIMEType: "text/uri-list", ToMIME: dropFilesToURIList, FromMIME: dropFilesFromURIList},
+	}
+)
+
+// RegisterMIMEMapping adds m, e.g. for a custom registered format.
+// It takes precedence over existing mappings of the same format or MIME type.
+func RegisterMIMEMapping(m MIMEMapping) {
+	mimeMu.Lock()
+	defer mimeMu.Unlock()
+	mimeMappings = append([]MIMEMapping{m}, mimeMappings...)
+}
+
+// MIMEMappings returns all mappings, in the order they are matched
+func MIMEMappings() []MIMEMapping {
+	mimeMu.RLock()
+	defer mimeMu.RUnlock()
+	return append([]MIMEMapping(nil), mimeMappings...)
+}
+
+func mimeMappingByFormat(format string) (MIMEMapping, bool) {
+	mimeMu.RLock()
+	defer mimeMu.RUnlock()
+	for _, m := range mimeMappings {
+		if m.Format == format {
+			return m, true
+		}
+	}
+	return MIMEMapping{}, false
+}
+
+// mimeMappingByType matches the media type of mimeType.
+// Parameters only have to match when both sides specify them.
+func mimeMappingByType(mimeType string) (MIMEMapping, bool) {
+	typ, params, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return MIMEMapping{}, false
+	}
+	mimeMu.RLock()
+	defer mimeMu.RUnlock()
+next:
+	for _, m := range mimeMappings {
+		mTyp, mParams, err := mime.ParseMediaType(m.MIMEType)
+		if err != nil || mTyp != typ {
+			continue
+		}
+		for k, v := range params {
+			if mv, ok := mParams[k]; ok && !strings.EqualFold(mv, v) {
+				continue next
+			}
+		}
+		return m, true
+	}
+	return MIMEMapping{}, false
+}
+
+// MIMETypeOf returns the MIME type the clipboard format name maps to
+func MIMETypeOf(format string) (string, bool) {
+	m, ok := mimeMappingByFormat(format)
+	return m.MIMEType, ok
+}
+
+// FormatOfMIMEType returns the name of the clipboard format mimeType maps to
+func FormatOfMIMEType(mimeType string) (string, bool) {
+	m, ok := mimeMappingByType(mimeType)
+	return m.Format, ok
+}
+
+// ToMIME converts data of the clipboard format name into its MIME representation
+func ToMIME(format string, data []byte) (mimeType string, result []byte, err error) {
+	m, ok := mimeMappingByFormat(format)
+	if !ok {
+		return "", nil, fmt.Errorf("format %q. %w", format, ErrNoMIMEMapping)
+	}
+	if m.ToMIME == nil {
+		return m.MIMEType, data, nil
+	}
+	if result, err = m.ToMIME(data); err != nil {
+		return "", nil, err
+	}
+	return m.MIMEType, result, nil
+}
+
+// FromMIME converts data of mimeType into its clipboard representation
+func FromMIME(mimeType string, data []byte) (format string, result []byte, err error) {
+	m, ok := mimeMappingByType(mimeType)
+	if !ok {
+		return "", nil, fmt.Errorf("MIME type %q. %w", mimeType, ErrNoMIMEMapping)
+	}
+	if m.FromMIME == nil {
+		return m.Format, data, nil
+	}
+	if result, err = m.FromMIME(data); err != nil {
+		return "", nil, err
+	}
+	return m.Format, result, nil
+}
+
+func unicodeTextToMIME(data []byte) ([]byte, error) {
+	return []byte(DecodeUnicodeText(data)), nil
+}
+
+func unicodeTextFromMIME(data []byte) ([]byte, error) {
+	return EncodeUnicodeText(strings.TrimPrefix(string(data), "\uFEFF")), nil
+}
+
+func htmlToMIME(data []byte) ([]byte, error) {
+	h, err := ParseHTML(data)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(h.Fragment), nil
+}
+
+func htmlFromMIME(data []byte) ([]byte, error) {
+	return HTML{Fragment: string(data)}.Bytes(), nil
+}
+
+// dropFilesToURIList converts CF_HDROP data into a text/uri-list of file URIs
+func dropFilesToURIList(data []byte) ([]byte, error) {
+	paths, err := DecodeDropFiles(data)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	for _, p := range paths {
+		sb.WriteString(FilePathToURI(p))
+		sb.WriteString("\r\n")
+	}
+	return []byte(sb.String()), nil
+}
+
+// dropFilesFromURIList converts a text/uri-list of file URIs into CF_HDROP data
+func dropFilesFromURIList(data []byte) ([]byte, error) {
+	var paths []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		p, err := URIToFilePath(line)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return EncodeDropFiles(paths), nil
+}
+
+// FilePathToURI converts a Windows path, including UNC paths, into a file URI
+func FilePathToURI(path string) string {
+	path = strings.ReplaceAll(path, `\`, "/")
+	u := url.URL{Scheme: "file"}
+	if strings.HasPrefix(path, "//") {
+		host := path[2:]
+		u.Path = "/"
+		if i := strings.IndexByte(host, '/'); i >= 0 {
+			host, u.Path = host[:i], host[i:]
+		}
+		u.Host = host
+	} else {
+		u.Path = "/" + strings.TrimPrefix(path, "/")
+	}
+	return u.String()
+}
+
+// URIToFilePath converts a file URI into a Windows path.
+// URIs with a host other than localhost result in UNC paths.
+func URIToFilePath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("%q is not a file URI", uri)
+	}
+	path := u.Path
+	if u.Host != "" && !strings.EqualFold(u.Host, "localhost") {
+		return `\\` + u.Host + strings.ReplaceAll(path, "/", `\`), nil
+	}
+	// "/C:/dir" is the drive letter path "C:\dir"
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return strings.ReplaceAll(path, "/", `\`), nil
+}
@@ -0,0 +1,138 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFilePathToURI(t *testing.T) {
+	tests := []struct {
+		path, uri string
+	}{
+		{`C:\dir\a.txt`, "file:///C:/dir/a.txt"},
+		{`C:\`, "file:///C:/"},
+		{`C:\my dir\a b.txt`, "file:///C:/my%20dir/a%20b.txt"},
+		{`C:\a#1%.txt`, "file:///C:/a%231%25.txt"},
+		{`C:\a?.txt`, "file:///C:/a%3F.txt"},
+		{`C:\über\日本.txt`, "file:///C:/%C3%BCber/%E6%97%A5%E6%9C%AC.txt"},
+		{`\\server\share\a b.txt`, "file://server/share/a%20b.txt"},
+		{`\\server\share`, "file://server/share"},
+	}
+	for _, tt := range tests {
+		if uri := FilePathToURI(tt.path); uri != tt.uri {
+			t.Errorf("FilePathToURI(%q) = %q, want %q", tt.path, uri, tt.uri)
+		}
+		path, err := URIToFilePath(tt.uri)
+		if err != nil {
+			t.Errorf("URIToFilePath(%q): %v", tt.uri, err)
+			continue
+		}
+		if path != tt.path {
+			t.Errorf("URIToFilePath(%q) = %q, want %q", tt.uri, path, tt.path)
+		}
+	}
+	if uri := FilePathToURI(`\\server`); uri != "file://server/" {
+		t.Errorf("FilePathToURI of a bare server = %q", uri)
+	}
+}
+
+func TestURIToFilePath(t *testing.T) {
+	tests := []struct {
+		uri, path string
+	}{
+		{"file://localhost/C:/a.txt", `C:\a.txt`},
+		{"file://LOCALHOST/C:/a.txt", `C:\a.txt`},
+		{"file:/C:/a%20b.txt", `C:\a b.txt`},
+		{"file:///C:/%c3%bc.txt", `C:\ü.txt`},
+		{"file:///home/a.txt", `\home\a.txt`},
+		{"file://server/share/%E6%97%A5.txt", `\\server\share\日.txt`},
+	}
+	for _, tt := range tests {
+		path, err := URIToFilePath(tt.uri)
+		if err != nil || path != tt.path {
+			t.Errorf("URIToFilePath(%q) = %q, %v, want %q", tt.uri, path, err, tt.path)
+		}
+	}
+	for _, uri := range []string{"https://example.com/a.txt", "C:/a.txt", "file:///C:/%zz"} {
+		if path, err := URIToFilePath(uri); err == nil {
+			t.Errorf("URIToFilePath(%q) = %q, want an error", uri, path)
+		}
+	}
+}
+
+func TestMIMERoundTrip(t *testing.T) {
+	tests := []struct {
+		format   string
+		data     []byte
+		mimeType string
+		mimeData []byte
+	}{
+		{"CF_UNICODETEXT", EncodeUnicodeText("a\r\nü"), "text/plain;charset=utf-8", []byte("a\r\nü")},
+		{HTMLFormatName, HTML{Fragment: "<b>bold</b>"}.Bytes(), "text/html", []byte("<b>bold</b>")},
+		{
+			"CF_HDROP", EncodeDropFiles([]string{`C:\a b.txt`, `\\server\share\ü.txt`}),
+			"text/uri-list", []byte("file:///C:/a%20b.txt\r\nfile://server/share/%C3%BC.txt\r\n"),
+		},
+		{RTFFormatName, []byte(`{\rtf1 a}`), "text/rtf", []byte(`{\rtf1 a}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			mimeType, mimeData, err := ToMIME(tt.format, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mimeType != tt.mimeType || !bytes.Equal(mimeData, tt.mimeData) {
+				t.Errorf("ToMIME = %s %q, want %s %q", mimeType, mimeData, tt.mimeType, tt.mimeData)
+			}
+			format, data, err := FromMIME(mimeType, mimeData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != tt.format || !bytes.Equal(data, tt.data) {
+				t.Errorf("FromMIME = %s %q, want %s %q", format, data, tt.format, tt.data)
+			}
+		})
+	}
+}
+
+func TestFromMIME(t *testing.T) {
+	// a BOM is dropped, a matching charset parameter or none at all selects the text mapping
+	for _, mimeType := range []string{"text/plain", "text/plain; charset=UTF-8"} {
+		format, data, err := FromMIME(mimeType, []byte("\uFEFFtext"))
+		if err != nil || format != "CF_UNICODETEXT" || !bytes.Equal(data, EncodeUnicodeText("text")) {
+			t.Errorf("%s: got %s %q, %v", mimeType, format, data, err)
+		}
+	}
+	for _, mimeType := range []string{"text/plain;charset=iso-8859-1", "application/x-unknown", "not a type"} {
+		if _, _, err := FromMIME(mimeType, nil); !errors.Is(err, ErrNoMIMEMapping) {
+			t.Errorf("%s: got %v, want ErrNoMIMEMapping", mimeType, err)
+		}
+	}
+
+	// comments and blank lines of text/uri-list are skipped, LF line breaks are accepted
+	_, data, err := FromMIME("text/uri-list", []byte("# copied\nfile:///C:/a.txt\n\nfile:///C:/b.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths, err := DecodeDropFiles(data); err != nil || !reflect.DeepEqual(paths, []string{`C:\a.txt`, `C:\b.txt`}) {
+		t.Errorf("got %q, %v", paths, err)
+	}
+	if _, _, err := FromMIME("text/uri-list", []byte("https://example.com/\r\n")); err == nil {
+		t.Error("a non file URI was accepted")
+	}
+}
+
+func TestRegisterMIMEMapping(t *testing.T) {
+	RegisterMIMEMapping(MIMEMapping{Format: "Test MIME", MIMEType: "application/x-test"})
+	if mimeType, ok := MIMETypeOf("Test MIME"); !ok || mimeType != "application/x-test" {
+		t.Errorf("MIMETypeOf = %q, %v", mimeType, ok)
+	}
+	if format, ok := FormatOfMIMEType("application/x-test; v=1"); !ok || format != "Test MIME" {
+		t.Errorf("FormatOfMIMEType = %q, %v", format, ok)
+	}
+	if _, _, err := ToMIME("Test Unmapped", nil); !errors.Is(err, ErrNoMIMEMapping) {
+		t.Errorf("got %v, want ErrNoMIMEMapping", err)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"unsafe"
 
 	"github.com/kirides/go-winclipboard/internal/winsys"
-	"golang.org/x/sys/windows"
 )
 
 // Contains Win32 API wrappers and structs
@@ -22,7 +21,7 @@ func dragQueryFileSlice(hDrop syscall.Handle, iFile uint, buf []uint16) (uint, e
 	return uint(i32), err
 }
 
-func setClipboardDataSlice(id uint32, value []byte) error {
+func setClipboardDataSlice(id Format, value []byte) error {
 	hHeap, err := winsys.GetProcessHeap()
 	if hHeap == 0 {
 		return err
@@ -32,7 +31,7 @@ func setClipboardDataSlice(id uint32, value []byte) error {
 		return err
 	}
 	copy((*[1 << 30]byte)(unsafe.Pointer(hMem))[:len(value):len(value)], value)
-	r, e1 := winsys.SetClipboardData(id, syscall.Handle(hMem))
+	r, e1 := winsys.SetClipboardData(uint32(id), syscall.Handle(hMem))
 	if r != syscall.Handle(hMem) {
 		e2 := winsys.HeapFree(hHeap, 0, hMem)
 		if e2 != nil {
@@ -42,24 +41,3 @@ func setClipboardDataSlice(id uint32, value []byte) error {
 	}
 	return nil
 }
-
-type _FILEGROUPDESCRIPTORW struct {
-	nItems uint32
-	fgd    *_FILEDESCRIPTORW
-}
-
-const _MAX_PATH = 260
-
-type _FILEDESCRIPTORW struct {
-	dwFlags          uint32
-	clsid            windows.GUID
-	size             [2]int32
-	pointl           [2]int32
-	dwFileAttributes uint32
-	ftCreationTime   windows.Filetime
-	ftLastAccessTime windows.Filetime
-	ftLastWriteTime  windows.Filetime
-	nFileSizeHigh    uint32
-	nFileSizeLow     uint32
-	cFileName        [_MAX_PATH]uint16
-}
@@ -0,0 +1,409 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+var (
+	ErrInvalidObjectDescriptor = errors.New("invalid OBJECTDESCRIPTOR data")
+	ErrInvalidMoniker          = errors.New("invalid moniker data")
+)
+
+// OLE formats placed by applications supporting embedding and linking
+const (
+	ObjectDescriptorFormatName     = "Object Descriptor"
+	LinkSourceDescriptorFormatName = "Link Source Descriptor"
+	LinkSourceFormatName           = "Link Source"
+	EmbedSourceFormatName          = "Embed Source"
+	EmbeddedObjectFormatName       = "Embedded Object"
+)
+
+// objectDescriptorSize is the size of an OBJECTDESCRIPTOR without its strings
+const objectDescriptorSize = 52
+
+// Draw aspects of ObjectDescriptor.DrawAspect
+const (
+	DVASPECT_CONTENT   = 1
+	DVASPECT_THUMBNAIL = 2
+	DVASPECT_ICON      = 4
+	DVASPECT_DOCPRINT  = 8
+)
+
+// ObjectDescriptor describes an OLE object in the clipboard, decoded from OBJECTDESCRIPTOR
+// (the "Object Descriptor" and "Link Source Descriptor" formats)
+type ObjectDescriptor struct {
+	CLSID      [16]byte
+	DrawAspect uint32
+	// Width and Height are the extent of the object in HIMETRIC units (0.01mm)
+	Width, Height int32
+	// X and Y are the offset of the mouse from the upper left corner of the object while dragging
+	X, Y int32
+	// Status holds the OLEMISC flags of the object
+	Status uint32
+	// FullUserTypeName is the type of the object, e.g. "Microsoft Excel Worksheet"
+	FullUserTypeName string
+	// SourceOfCopy is typically the display name of the source, e.g. a file path
+	SourceOfCopy string
+}
+
+// DecodeObjectDescriptor parses OBJECTDESCRIPTOR data
+func DecodeObjectDescriptor(data []byte) (*ObjectDescriptor, error) {
+	if len(data) < objectDescriptorSize {
+		return nil, fmt.Errorf("%d bytes are too short. %w", len(data), ErrInvalidObjectDescriptor)
+	}
+	// cbSize covers the strings as well, some applications leave trailing garbage
+	if size := binary.LittleEndian.Uint32(data); size >= objectDescriptorSize && uint64(size) <= uint64(len(data)) {
+		data = data[:size]
+	}
+	d := &ObjectDescriptor{
+		DrawAspect: binary.LittleEndian.Uint32(data[20:]),
+		Width:      int32(binary.LittleEndian.Uint32(data[24:])),
+		Height:     int32(binary.LittleEndian.Uint32(data[28:])),
+		X:          int32(binary.LittleEndian.Uint32(data[32:])),
+		Y:          int32(binary.LittleEndian.Uint32(data[36:])),
+		Status:     binary.LittleEndian.Uint32(data[40:]),
+	}
+	copy(d.CLSID[:], data[4:20])
+	var err error
+	if d.FullUserTypeName, err = objectDescriptorString(data, binary.LittleEndian.Uint32(data[44:])); err != nil {
+		return nil, fmt.Errorf("full user type name: %v. %w", err, ErrInvalidObjectDescriptor)
+	}
+	if d.SourceOfCopy, err = objectDescriptorString(data, binary.LittleEndian.Uint32(data[48:])); err != nil {
+		return nil, fmt.Errorf("source of copy: %v. %w", err, ErrInvalidObjectDescriptor)
+	}
+	return d, nil
+}
+
+// objectDescriptorString reads the zero terminated UTF-16 string at offset, zero means absent
+func objectDescriptorString(data []byte, offset uint32) (string, error) {
+	if offset == 0 {
+		return "", nil
+	}
+	if offset < objectDescriptorSize || uint64(offset) >= uint64(len(data)) {
+		return "", fmt.Errorf("offset %d out of range", offset)
+	}
+	s, _ := utf16String(data[offset:])
+	return s, nil
+}
+
+// EncodeObjectDescriptor serializes d as OBJECTDESCRIPTOR
+func EncodeObjectDescriptor(d *ObjectDescriptor) []byte {
+	buf := make([]byte, objectDescriptorSize)
+	copy(buf[4:], d.CLSID[:])
+	binary.LittleEndian.PutUint32(buf[20:], d.DrawAspect)
+	binary.LittleEndian.PutUint32(buf[24:], uint32(d.Width))
+	binary.LittleEndian.PutUint32(buf[28:], uint32(d.Height))
+	binary.LittleEndian.PutUint32(buf[32:], uint32(d.X))
+	binary.LittleEndian.PutUint32(buf[36:], uint32(d.Y))
+	binary.LittleEndian.PutUint32(buf[40:], d.Status)
+	for i, s := range []string{d.FullUserTypeName, d.SourceOfCopy} {
+		if s == "" {
+			continue
+		}
+		binary.LittleEndian.PutUint32(buf[44+i*4:], uint32(len(buf)))
+		for _, c := range utf16.Encode([]rune(s)) {
+			buf = append(buf, byte(c), byte(c>>8))
+		}
+		buf = append(buf, 0, 0)
+	}
+	binary.LittleEndian.PutUint32(buf, uint32(len(buf)))
+	return buf
+}
+
+// MonikerKind identifies the class of a Moniker
+type MonikerKind int
+
+const (
+	MonikerUnknown MonikerKind = iota
+	MonikerFile
+	MonikerItem
+	MonikerComposite
+	MonikerAnti
+	MonikerURL
+)
+
+func (k MonikerKind) String() string {
+	switch k {
+	case MonikerFile:
+		return "file"
+	case MonikerItem:
+		return "item"
+	case MonikerComposite:
+		return "composite"
+	case MonikerAnti:
+		return "anti"
+	case MonikerURL:
+		return "url"
+	}
+	return "unknown"
+}
+
+// CLSIDs of the persisted moniker classes (MS-OSHARED)
+var (
+	clsidFileMoniker      = [16]byte{0x03, 0x03, 0, 0, 0, 0, 0, 0, 0xC0, 0, 0, 0, 0, 0, 0, 0x46}
+	clsidItemMoniker      = [16]byte{0x04, 0x03, 0, 0, 0, 0, 0, 0, 0xC0, 0, 0, 0, 0, 0, 0, 0x46}
+	clsidAntiMoniker      = [16]byte{0x05, 0x03, 0, 0, 0, 0, 0, 0, 0xC0, 0, 0, 0, 0, 0, 0, 0x46}
+	clsidCompositeMoniker = [16]byte{0x09, 0x03, 0, 0, 0, 0, 0, 0, 0xC0, 0, 0, 0, 0, 0, 0, 0x46}
+	clsidURLMoniker       = [16]byte{0xE0, 0xC9, 0xEA, 0x79, 0xF9, 0xBA, 0xCE, 0x11, 0x8C, 0x82, 0x00, 0xAA, 0x00, 0x4B, 0xA9, 0x0B}
+)
+
+// maxMonikerDepth limits the nesting of composite monikers
+const maxMonikerDepth = 8
+
+// maxAntiCount limits the count of an anti moniker to the range of the file moniker's cAnti
+const maxAntiCount = 0xFFFF
+
+// Moniker is a persisted OLE moniker, the name of a linked object
+type Moniker struct {
+	Kind  MonikerKind
+	CLSID [16]byte
+	// Path is the file path of a file moniker, the item name of an item moniker or the URL of a URL moniker
+	Path string
+	// Delimiter separates an item from the preceding moniker, typically "!"
+	Delimiter string
+	// Up is the number of parent directories a relative file moniker ascends, or the count of an anti moniker
+	Up int
+	// Components are the parts of a composite moniker
+	Components []Moniker
+}
+
+// String returns the display name of m, e.g. `C:\Book1.xlsx!Sheet1!R1C1:R2C2`
+func (m Moniker) String() string {
+	switch m.Kind {
+	case MonikerFile:
+		return strings.Repeat(`..\`, m.Up) + m.Path
+	case MonikerItem:
+		return m.Delimiter + m.Path
+	case MonikerURL:
+		return m.Path
+	case MonikerAnti:
+		return strings.Repeat(`\..`, m.Up)
+	case MonikerComposite:
+		var sb strings.Builder
+		for _, c := range m.Components {
+			sb.WriteString(c.String())
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+// File returns the path of the first file moniker in m, empty if there is none
+func (m Moniker) File() string {
+	switch m.Kind {
+	case MonikerFile:
+		return strings.Repeat(`..\`, m.Up) + m.Path
+	case MonikerComposite:
+		for _, c := range m.Components {
+			if f := c.File(); f != "" {
+				return f
+			}
+		}
+	}
+	return ""
+}
+
+// LinkSource is the content of the "Link Source" format:
+// the moniker of the linked object followed by the CLSID of its class
+type LinkSource struct {
+	Moniker Moniker
+	CLSID   [16]byte
+}
+
+// DecodeLinkSource parses "Link Source" data.
+//
+// Monikers of unknown classes can not be skipped, they end the data and leave CLSID unset.
+func DecodeLinkSource(data []byte) (*LinkSource, error) {
+	m, n, err := decodeMoniker(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	ls := &LinkSource{Moniker: m}
+	if m.Kind != MonikerUnknown && len(data)-n >= 16 {
+		copy(ls.CLSID[:], data[n:])
+	}
+	return ls, nil
+}
+
+// DecodeMoniker parses a moniker persisted with OleSaveToStream, its CLSID followed by the moniker data
+func DecodeMoniker(data []byte) (Moniker, error) {
+	m, _, err := decodeMoniker(data, 0)
+	return m, err
+}
+
+// decodeMoniker returns the moniker at the start of data and the number of bytes it occupies
+func decodeMoniker(data []byte, depth int) (Moniker, int, error) {
+	var m Moniker
+	if len(data) < 16 {
+		return m, 0, fmt.Errorf("missing CLSID. %w", ErrInvalidMoniker)
+	}
+	copy(m.CLSID[:], data)
+	r := &monikerReader{data: data, off: 16}
+	switch m.CLSID {
+	case clsidFileMoniker:
+		m.Kind = MonikerFile
+		m.Up = int(r.uint16())
+		ansi := r.bytes(r.uint32())
+		r.skip(2 + 2 + 16 + 4) // endServer, versionNumber, reserved
+		m.Path = decodeANSI(ansi)
+		if size := r.uint32(); size > 0 && r.err == nil {
+			n := r.uint32()
+			r.skip(2) // usKeyValue
+			unicode := r.bytes(n)
+			if r.err == nil {
+				m.Path = string(utf16.Decode(uint16s(unicode)))
+			}
+		}
+	case clsidItemMoniker:
+		m.Kind = MonikerItem
+		m.Delimiter = decodeItemString(r.bytes(r.uint32()))
+		m.Path = decodeItemString(r.bytes(r.uint32()))
+	case clsidAntiMoniker:
+		m.Kind = MonikerAnti
+		count := r.uint32()
+		if count > maxAntiCount {
+			return m, 0, fmt.Errorf("anti moniker count %d. %w", count, ErrInvalidMoniker)
+		}
+		m.Up = int(count)
+	case clsidURLMoniker:
+		m.Kind = MonikerURL
+		b := r.bytes(r.uint32())
+		m.Path, _ = utf16String(b)
+	case clsidCompositeMoniker:
+		m.Kind = MonikerComposite
+		if depth >= maxMonikerDepth {
+			return m, 0, fmt.Errorf("composite monikers nested too deep. %w", ErrInvalidMoniker)
+		}
+		count := r.uint32()
+		for i := uint32(0); i < count && r.err == nil; i++ {
+			c, n, err := decodeMoniker(data[r.off:], depth+1)
+			if err != nil {
+				return m, 0, err
+			}
+			if c.Kind == MonikerUnknown {
+				return m, 0, fmt.Errorf("composite contains an unknown moniker. %w", ErrInvalidMoniker)
+			}
+			m.Components = append(m.Components, c)
+			r.off += n
+		}
+	default:
+		return m, len(data), nil
+	}
+	if r.err != nil {
+		return m, 0, fmt.Errorf("%s moniker: %v. %w", m.Kind, r.err, ErrInvalidMoniker)
+	}
+	return m, r.off, nil
+}
+
+// monikerReader reads little endian values, remembering the first overrun
+type monikerReader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (r *monikerReader) bytes(n uint32) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if uint64(n) > uint64(len(r.data)-r.off) {
+		r.err = fmt.Errorf("%d bytes exceed the data at offset %d", n, r.off)
+		return nil
+	}
+	b := r.data[r.off : r.off+int(n)]
+	r.off += int(n)
+	return b
+}
+
+func (r *monikerReader) skip(n uint32) {
+	r.bytes(n)
+}
+
+func (r *monikerReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *monikerReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+// decodeItemString decodes a zero terminated ANSI string, optionally followed by its UTF-16 form
+func decodeItemString(b []byte) string {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return decodeANSI(b)
+	}
+	if rest := b[i+1:]; len(rest) >= 2 {
+		if s, _ := utf16String(rest); s != "" {
+			return s
+		}
+	}
+	return decodeANSI(b[:i])
+}
+
+// decodeANSI decodes b up to the first zero byte, assuming code page 1252
+func decodeANSI(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	s, err := charmap.Windows1252.NewDecoder().Bytes(b)
+	if err != nil {
+		return string(b)
+	}
+	return string(s)
+}
+
+// utf16String decodes little endian UTF-16 up to the first zero or the end of b,
+// reporting whether a terminator was found
+func utf16String(b []byte) (string, bool) {
+	var s []uint16
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			return string(utf16.Decode(s)), true
+		}
+		s = append(s, c)
+	}
+	return string(utf16.Decode(s)), false
+}
+
+func uint16s(b []byte) []uint16 {
+	result := make([]uint16, len(b)/2)
+	for i := range result {
+		result[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return result
+}
+
+// GetObjectDescriptorFrom decodes the "Object Descriptor" of b, falling back to the "Link Source Descriptor"
+func GetObjectDescriptorFrom(b Backend) (*ObjectDescriptor, error) {
+	data, err := GetAsFrom(b, ObjectDescriptorFormatName)
+	if err != nil {
+		if data, err = GetAsFrom(b, LinkSourceDescriptorFormatName); err != nil {
+			return nil, err
+		}
+	}
+	return DecodeObjectDescriptor(data)
+}
+
+// GetLinkSourceFrom decodes the "Link Source" of b
+func GetLinkSourceFrom(b Backend) (*LinkSource, error) {
+	data, err := GetAsFrom(b, LinkSourceFormatName)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeLinkSource(data)
+}
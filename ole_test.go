@@ -0,0 +1,244 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+// clsidExcelSheet is {00020820-0000-0000-C000-000000000046}
+var clsidExcelSheet = [16]byte{0x20, 0x08, 0x02, 0, 0, 0, 0, 0, 0xC0, 0, 0, 0, 0, 0, 0, 0x46}
+
+// le writes values in little endian order, strings as they are
+func le(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			buf.WriteString(v)
+		case []byte:
+			buf.Write(v)
+		default:
+			binary.Write(&buf, binary.LittleEndian, v)
+		}
+	}
+	return buf.Bytes()
+}
+
+// wide returns s as zero terminated UTF-16LE
+func wide(s string) []byte {
+	return le(append(utf16.Encode([]rune(s)), 0))
+}
+
+// The monikers below follow the persisted layouts of MS-OSHARED 2.3.7.
+
+func fileMoniker(up uint16, ansi string, unicode string) []byte {
+	b := le(clsidFileMoniker[:], up, uint32(len(ansi)+1), ansi+"\x00",
+		uint16(0xFFFF), uint16(0xDEAD), make([]byte, 16), uint32(0))
+	if unicode == "" {
+		return append(b, le(uint32(0))...)
+	}
+	u := le(utf16.Encode([]rune(unicode)))
+	return append(b, le(uint32(len(u)+6), uint32(len(u)), uint16(3), u)...)
+}
+
+func itemMoniker(delimiter, item string) []byte {
+	return le(clsidItemMoniker[:], uint32(len(delimiter)+1), delimiter+"\x00", uint32(len(item)+1), item+"\x00")
+}
+
+func compositeMoniker(monikers ...[]byte) []byte {
+	b := le(clsidCompositeMoniker[:], uint32(len(monikers)))
+	for _, m := range monikers {
+		b = append(b, m...)
+	}
+	return b
+}
+
+func TestDecodeObjectDescriptor(t *testing.T) {
+	typeName, source := wide("Microsoft Excel Worksheet"), wide(`C:\Book1.xlsx`)
+	header := le(uint32(52+len(typeName)+len(source)), clsidExcelSheet[:], uint32(DVASPECT_CONTENT),
+		int32(2540), int32(-1270), int32(10), int32(20), uint32(0x20000), uint32(52), uint32(52+len(typeName)))
+	data := append(append(header, typeName...), source...)
+	want := &ObjectDescriptor{
+		CLSID: clsidExcelSheet, DrawAspect: DVASPECT_CONTENT,
+		Width: 2540, Height: -1270, X: 10, Y: 20, Status: 0x20000,
+		FullUserTypeName: "Microsoft Excel Worksheet", SourceOfCopy: `C:\Book1.xlsx`,
+	}
+
+	d, err := DecodeObjectDescriptor(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("got %+v, want %+v", d, want)
+	}
+	if got := EncodeObjectDescriptor(want); !bytes.Equal(got, data) {
+		t.Errorf("encoded %x, want %x", got, data)
+	}
+	// trailing garbage beyond cbSize is ignored
+	if d, err := DecodeObjectDescriptor(append(data[:len(data):len(data)], "garbage"...)); err != nil || !reflect.DeepEqual(d, want) {
+		t.Errorf("with trailing data: %+v, %v", d, err)
+	}
+
+	noStrings := le(uint32(52), clsidExcelSheet[:], uint32(DVASPECT_ICON), make([]byte, 28))
+	if d, err := DecodeObjectDescriptor(noStrings); err != nil || d.FullUserTypeName != "" || d.SourceOfCopy != "" {
+		t.Errorf("without strings: %+v, %v", d, err)
+	}
+
+	invalid := map[string][]byte{
+		"short":            data[:51],
+		"offset in header": append(le(uint32(60), make([]byte, 40), uint32(20), uint32(0)), make([]byte, 8)...),
+		"offset beyond":    le(uint32(52), make([]byte, 40), uint32(0), uint32(52)),
+		// cbSize cuts off the second string
+		"size excludes string": append(le(uint32(60), clsidExcelSheet[:], make([]byte, 24), uint32(52), uint32(60)), wide("abc")...),
+	}
+	for name, data := range invalid {
+		if _, err := DecodeObjectDescriptor(data); !errors.Is(err, ErrInvalidObjectDescriptor) {
+			t.Errorf("%s: got %v, want ErrInvalidObjectDescriptor", name, err)
+		}
+	}
+}
+
+func TestDecodeMoniker(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Moniker
+		display string
+	}{
+		{
+			"file", fileMoniker(0, `C:\Book1.xlsx`, ""),
+			Moniker{Kind: MonikerFile, CLSID: clsidFileMoniker, Path: `C:\Book1.xlsx`},
+			`C:\Book1.xlsx`,
+		},
+		{
+			"file cp1252", fileMoniker(0, "C:\\\xe4.doc", ""),
+			Moniker{Kind: MonikerFile, CLSID: clsidFileMoniker, Path: `C:\ä.doc`},
+			`C:\ä.doc`,
+		},
+		{
+			"file unicode", fileMoniker(2, `C:\?.doc`, `C:\日本.doc`),
+			Moniker{Kind: MonikerFile, CLSID: clsidFileMoniker, Path: `C:\日本.doc`, Up: 2},
+			`..\..\C:\日本.doc`,
+		},
+		{
+			"item", itemMoniker("!", "Sheet1"),
+			Moniker{Kind: MonikerItem, CLSID: clsidItemMoniker, Delimiter: "!", Path: "Sheet1"},
+			"!Sheet1",
+		},
+		{
+			// the ANSI item is followed by its UTF-16 form
+			"item unicode", le(clsidItemMoniker[:], uint32(2), "!\x00", uint32(2+6), "?\x00", wide("Σ1")),
+			Moniker{Kind: MonikerItem, CLSID: clsidItemMoniker, Delimiter: "!", Path: "Σ1"},
+			"!Σ1",
+		},
+		{
+			"anti", le(clsidAntiMoniker[:], uint32(2)),
+			Moniker{Kind: MonikerAnti, CLSID: clsidAntiMoniker, Up: 2},
+			`\..\..`,
+		},
+		{
+			"url", le(clsidURLMoniker[:], uint32(len(wide("https://example.com/a"))), wide("https://example.com/a")),
+			Moniker{Kind: MonikerURL, CLSID: clsidURLMoniker, Path: "https://example.com/a"},
+			"https://example.com/a",
+		},
+		{
+			"composite", compositeMoniker(fileMoniker(0, `C:\Book1.xlsx`, ""), itemMoniker("!", "Sheet1"), itemMoniker("!", "R1C1:R2C2")),
+			Moniker{Kind: MonikerComposite, CLSID: clsidCompositeMoniker, Components: []Moniker{
+				{Kind: MonikerFile, CLSID: clsidFileMoniker, Path: `C:\Book1.xlsx`},
+				{Kind: MonikerItem, CLSID: clsidItemMoniker, Delimiter: "!", Path: "Sheet1"},
+				{Kind: MonikerItem, CLSID: clsidItemMoniker, Delimiter: "!", Path: "R1C1:R2C2"},
+			}},
+			`C:\Book1.xlsx!Sheet1!R1C1:R2C2`,
+		},
+		{
+			"unknown", clsidExcelSheet[:],
+			Moniker{CLSID: clsidExcelSheet},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := DecodeMoniker(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(m, tt.want) {
+				t.Errorf("got %+v, want %+v", m, tt.want)
+			}
+			if s := m.String(); s != tt.display {
+				t.Errorf("String() = %q, want %q", s, tt.display)
+			}
+		})
+	}
+}
+
+func TestDecodeMonikerInvalid(t *testing.T) {
+	file := fileMoniker(0, `C:\a.txt`, "")
+	nested := itemMoniker("!", "x")
+	for i := 0; i <= maxMonikerDepth; i++ {
+		nested = compositeMoniker(nested)
+	}
+	tests := map[string][]byte{
+		"empty":             nil,
+		"short CLSID":       clsidFileMoniker[:15],
+		"truncated file":    file[:len(file)-1],
+		"anti count":        le(clsidAntiMoniker[:], uint32(maxAntiCount+1)),
+		"ansi length":       le(clsidFileMoniker[:], uint16(0), uint32(0xFFFFFFFF)),
+		"truncated item":    itemMoniker("!", "Sheet1")[:30],
+		"truncated url":     le(clsidURLMoniker[:], uint32(10), "ab"),
+		"composite count":   compositeMoniker(file)[:16+4+len(file)-1],
+		"composite unknown": compositeMoniker(file, clsidExcelSheet[:]),
+		"nested":            nested,
+	}
+	for name, data := range tests {
+		if _, err := DecodeMoniker(data); !errors.Is(err, ErrInvalidMoniker) {
+			t.Errorf("%s: got %v, want ErrInvalidMoniker", name, err)
+		}
+	}
+}
+
+func TestDecodeLinkSource(t *testing.T) {
+	moniker := compositeMoniker(fileMoniker(0, `C:\Book1.xlsx`, ""), itemMoniker("!", "Sheet1"))
+	ls, err := DecodeLinkSource(append(append([]byte(nil), moniker...), clsidExcelSheet[:]...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ls.CLSID != clsidExcelSheet || ls.Moniker.File() != `C:\Book1.xlsx` || ls.Moniker.String() != `C:\Book1.xlsx!Sheet1` {
+		t.Errorf("got %+v", ls)
+	}
+
+	// the class is optional
+	if ls, err := DecodeLinkSource(moniker); err != nil || ls.CLSID != ([16]byte{}) {
+		t.Errorf("without CLSID: %+v, %v", ls, err)
+	}
+	// an unknown moniker swallows the rest of the data
+	if ls, err := DecodeLinkSource(append(clsidExcelSheet[:], clsidExcelSheet[:]...)); err != nil || ls.CLSID != ([16]byte{}) || ls.Moniker.Kind != MonikerUnknown {
+		t.Errorf("unknown moniker: %+v, %v", ls, err)
+	}
+	if _, err := DecodeLinkSource(moniker[:20]); !errors.Is(err, ErrInvalidMoniker) {
+		t.Errorf("truncated: got %v, want ErrInvalidMoniker", err)
+	}
+}
+
+func TestGetObjectDescriptorFrom(t *testing.T) {
+	b := NewMemoryBackend()
+	id, err := b.RegisterFormat(LinkSourceDescriptorFormatName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ObjectDescriptor{CLSID: clsidExcelSheet, DrawAspect: DVASPECT_CONTENT, FullUserTypeName: "Worksheet"}
+	if err := b.Write([]Item{{Format: id, Data: EncodeObjectDescriptor(want)}}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := GetObjectDescriptorFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("got %+v, want %+v", d, want)
+	}
+}
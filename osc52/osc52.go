@@ -0,0 +1,161 @@
+// Package osc52 encodes and parses OSC 52 terminal escape sequences,
+// which let programs set or query the clipboard of the terminal they run in.
+//
+//	ESC ] 52 ; <targets> ; <base64 data or ?> BEL
+package osc52
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	esc = 0x1B
+	bel = 0x07
+)
+
+// Terminators of the OSC string
+const (
+	BEL = "\a"
+	ST  = "\x1b\\"
+)
+
+// Targets are a sequence of selection characters:
+// c (clipboard), p (primary), q (secondary), s (select) and 0-7 (cut buffers)
+const (
+	TargetClipboard = "c"
+	TargetPrimary   = "p"
+	TargetSecondary = "q"
+	TargetSelect    = "s"
+)
+
+// DefaultTargets is assumed by terminals when the targets are empty
+const DefaultTargets = "s0"
+
+const validTargets = "cpqs01234567"
+
+var (
+	ErrInvalidSequence = errors.New("osc52: invalid sequence")
+	ErrTooLarge        = errors.New("osc52: payload exceeds size limit")
+)
+
+// Sequence is a single OSC 52 request or response
+type Sequence struct {
+	// Targets selects the clipboards, empty means DefaultTargets
+	Targets string
+	// Data is the decoded payload, nil for queries
+	Data []byte
+	// Query asks the terminal to respond with the current clipboard contents
+	Query bool
+}
+
+// HasTarget reports whether t is one of the selected targets
+func (s Sequence) HasTarget(t byte) bool {
+	targets := s.Targets
+	if targets == "" {
+		targets = DefaultTargets
+	}
+	return strings.IndexByte(targets, t) >= 0
+}
+
+// Wrap selects how sequences are wrapped to pass through terminal multiplexers
+type Wrap int
+
+const (
+	WrapNone Wrap = iota
+	// WrapTmux wraps the sequence in a tmux DCS passthrough, doubling every ESC
+	WrapTmux
+	// WrapScreen splits the sequence into DCS chunks of at most ScreenChunkSize bytes
+	WrapScreen
+)
+
+// ScreenChunkSize is the largest DCS string GNU screen passes through
+const ScreenChunkSize = 768
+
+// Encoder creates OSC 52 sequences
+type Encoder struct {
+	Wrap Wrap
+	// Terminator is either BEL (default) or ST.
+	// WrapScreen always uses BEL, because ST would end the DCS chunk.
+	Terminator string
+	// MaxPayload limits the length of the base64 payload, 0 means no limit.
+	// Terminals and multiplexers silently drop sequences above their own limit.
+	MaxPayload int
+}
+
+// Encode returns s as an escape sequence, wrapped as configured
+func (e Encoder) Encode(s Sequence) ([]byte, error) {
+	for i := 0; i < len(s.Targets); i++ {
+		if strings.IndexByte(validTargets, s.Targets[i]) < 0 {
+			return nil, fmt.Errorf("target %q. %w", s.Targets[i], ErrInvalidSequence)
+		}
+	}
+	payload := "?"
+	if !s.Query {
+		payload = base64.StdEncoding.EncodeToString(s.Data)
+		if e.MaxPayload > 0 && len(payload) > e.MaxPayload {
+			return nil, fmt.Errorf("%d bytes. %w", len(payload), ErrTooLarge)
+		}
+	}
+	term := e.Terminator
+	if term == "" || e.Wrap == WrapScreen {
+		term = BEL
+	}
+	seq := "\x1b]52;" + s.Targets + ";" + payload + term
+
+	switch e.Wrap {
+	case WrapTmux:
+		return []byte("\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + ST), nil
+	case WrapScreen:
+		var buf bytes.Buffer
+		for len(seq) > 0 {
+			n := len(seq)
+			if n > ScreenChunkSize {
+				n = ScreenChunkSize
+			}
+			buf.WriteString("\x1bP" + seq[:n] + ST)
+			seq = seq[n:]
+		}
+		return buf.Bytes(), nil
+	}
+	return []byte(seq), nil
+}
+
+// Parse decodes a single unwrapped sequence, with or without its terminator
+func Parse(b []byte) (Sequence, error) {
+	b = bytes.TrimSuffix(b, []byte(BEL))
+	b = bytes.TrimSuffix(b, []byte(ST))
+	if !bytes.HasPrefix(b, []byte("\x1b]52;")) {
+		return Sequence{}, fmt.Errorf("missing introducer. %w", ErrInvalidSequence)
+	}
+	return parseBody(b[len("\x1b]52;"):])
+}
+
+// parseBody decodes "<targets>;<payload>"
+func parseBody(b []byte) (Sequence, error) {
+	sep := bytes.IndexByte(b, ';')
+	if sep < 0 {
+		return Sequence{}, fmt.Errorf("missing payload. %w", ErrInvalidSequence)
+	}
+	s := Sequence{Targets: string(b[:sep])}
+	for i := 0; i < len(s.Targets); i++ {
+		if strings.IndexByte(validTargets, s.Targets[i]) < 0 {
+			return Sequence{}, fmt.Errorf("target %q. %w", s.Targets[i], ErrInvalidSequence)
+		}
+	}
+	payload := b[sep+1:]
+	if string(payload) == "?" {
+		s.Query = true
+		return s, nil
+	}
+	s.Data = make([]byte, base64.StdEncoding.DecodedLen(len(payload)))
+	n, err := base64.StdEncoding.Decode(s.Data, payload)
+	if err != nil {
+		return Sequence{}, fmt.Errorf("%v. %w", err, ErrInvalidSequence)
+	}
+	s.Data = s.Data[:n]
+	return s, nil
+}
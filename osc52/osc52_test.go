@@ -0,0 +1,104 @@
+package osc52
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	hello := Sequence{Targets: TargetClipboard, Data: []byte("hello")}
+	tests := []struct {
+		name string
+		enc  Encoder
+		seq  Sequence
+		want string
+		err  error
+	}{
+		{"BEL", Encoder{}, hello, "\x1b]52;c;aGVsbG8=\a", nil},
+		{"ST", Encoder{Terminator: ST}, hello, "\x1b]52;c;aGVsbG8=\x1b\\", nil},
+		{"query", Encoder{}, Sequence{Targets: "p", Query: true}, "\x1b]52;p;?\a", nil},
+		{"default targets", Encoder{}, Sequence{Data: []byte("x")}, "\x1b]52;;eA==\a", nil},
+		{"several targets", Encoder{}, Sequence{Targets: "cs0", Data: []byte("x")}, "\x1b]52;cs0;eA==\a", nil},
+		{"empty data", Encoder{}, Sequence{Targets: "c", Data: []byte{}}, "\x1b]52;c;\a", nil},
+		{"tmux", Encoder{Wrap: WrapTmux, Terminator: ST}, hello, "\x1bPtmux;\x1b\x1b]52;c;aGVsbG8=\x1b\x1b\\\x1b\\", nil},
+		{"screen uses BEL", Encoder{Wrap: WrapScreen, Terminator: ST}, hello, "\x1bP\x1b]52;c;aGVsbG8=\a\x1b\\", nil},
+		{"invalid target", Encoder{}, Sequence{Targets: "x", Data: []byte("x")}, "", ErrInvalidSequence},
+		{"too large", Encoder{MaxPayload: 7}, hello, "", ErrTooLarge},
+		{"limit ignores queries", Encoder{MaxPayload: 1}, Sequence{Targets: "c", Query: true}, "\x1b]52;c;?\a", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.enc.Encode(tt.seq)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeScreenChunks(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 2*ScreenChunkSize)
+	b, err := Encoder{Wrap: WrapScreen}.Encode(Sequence{Targets: "c", Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := strings.Split(strings.TrimSuffix(string(b), ST), ST)
+	for i, c := range chunks {
+		if !strings.HasPrefix(c, "\x1bP") || len(c)-2 > ScreenChunkSize {
+			t.Fatalf("chunk %d of %d bytes: %q...", i, len(c), c[:10])
+		}
+	}
+	seq, err := NewReader(bytes.NewReader(b), nil).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(seq.Data, data) {
+		t.Errorf("read %d bytes back, want %d", len(seq.Data), len(data))
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Sequence
+		err  error
+	}{
+		{"BEL", "\x1b]52;c;aGVsbG8=\a", Sequence{Targets: "c", Data: []byte("hello")}, nil},
+		{"ST", "\x1b]52;c;aGVsbG8=\x1b\\", Sequence{Targets: "c", Data: []byte("hello")}, nil},
+		{"unterminated", "\x1b]52;p;aGVsbG8=", Sequence{Targets: "p", Data: []byte("hello")}, nil},
+		{"query", "\x1b]52;c;?\a", Sequence{Targets: "c", Query: true}, nil},
+		{"default targets", "\x1b]52;;eA==\a", Sequence{Data: []byte("x")}, nil},
+		{"empty data", "\x1b]52;c;\a", Sequence{Targets: "c", Data: []byte{}}, nil},
+		{"invalid base64", "\x1b]52;c;a$==\a", Sequence{}, ErrInvalidSequence},
+		{"truncated base64", "\x1b]52;c;aGVsbG8\a", Sequence{}, ErrInvalidSequence},
+		{"invalid target", "\x1b]52;z;eA==\a", Sequence{}, ErrInvalidSequence},
+		{"missing payload", "\x1b]52;c\a", Sequence{}, ErrInvalidSequence},
+		{"other OSC", "\x1b]0;title\a", Sequence{}, ErrInvalidSequence},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.in))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got.Targets != tt.want.Targets || got.Query != tt.want.Query || !bytes.Equal(got.Data, tt.want.Data) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasTarget(t *testing.T) {
+	if s := (Sequence{}); !s.HasTarget('s') || !s.HasTarget('0') || s.HasTarget('c') {
+		t.Error("empty targets do not mean DefaultTargets")
+	}
+	if s := (Sequence{Targets: "cp"}); !s.HasTarget('p') || s.HasTarget('s') {
+		t.Error("HasTarget ignores the targets")
+	}
+}
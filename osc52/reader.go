@@ -0,0 +1,282 @@
+package osc52
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxPayload is used by Reader when MaxPayload is not set
+const DefaultMaxPayload = 8 << 20
+
+// Reader extracts OSC 52 sequences from a terminal output stream,
+// such as the output of a pty running ssh, tmux or screen.
+//
+// tmux and screen passthrough wrapping is removed.
+// Everything that is not an OSC 52 sequence is copied to the passthrough writer unchanged,
+// so a Reader can sit transparently between a program and the terminal.
+type Reader struct {
+	// MaxPayload limits the length of a single base64 payload, 0 means DefaultMaxPayload
+	MaxPayload int
+
+	r   *bufio.Reader
+	out *bufio.Writer
+
+	outer scanner
+	inner scanner
+
+	state     int
+	dcsPrefix []byte
+	dcsTmux   bool
+	// pending bytes are processed before reading from r again
+	pending []byte
+}
+
+// NewReader returns a Reader reading from r. passthrough may be nil to discard other output.
+func NewReader(r io.Reader, passthrough io.Writer) *Reader {
+	if passthrough == nil {
+		passthrough = io.Discard
+	}
+	rd := &Reader{
+		r:   bufio.NewReader(r),
+		out: bufio.NewWriter(passthrough),
+	}
+	rd.outer.out = rd.out
+	rd.inner.out = rd.out
+	return rd
+}
+
+// reader states outside of the scanners, which handle everything but DCS strings
+const (
+	stateGround = iota
+	stateDCSPrefix
+	stateDCS
+	stateDCSEsc
+	stateDCSRaw
+	stateDCSRawEsc
+)
+
+// Next returns the next OSC 52 sequence.
+//
+// Errors wrapping ErrInvalidSequence or ErrTooLarge only concern a single sequence,
+// Next can be called again to continue reading.
+// io.EOF is returned at the end of the stream.
+func (rd *Reader) Next() (Sequence, error) {
+	limit := rd.MaxPayload
+	if limit <= 0 {
+		limit = DefaultMaxPayload
+	}
+	rd.outer.max, rd.inner.max = limit, limit
+
+	for {
+		// forward pending output before blocking on the next read
+		if rd.r.Buffered() == 0 && len(rd.pending) == 0 {
+			if err := rd.out.Flush(); err != nil {
+				return Sequence{}, err
+			}
+		}
+		var c byte
+		if len(rd.pending) > 0 {
+			c, rd.pending = rd.pending[0], rd.pending[1:]
+		} else {
+			var err error
+			if c, err = rd.r.ReadByte(); err != nil {
+				rd.out.Flush()
+				return Sequence{}, err
+			}
+		}
+		if seq, done, err := rd.feed(c); done || err != nil {
+			return seq, err
+		}
+	}
+}
+
+func (rd *Reader) feed(c byte) (Sequence, bool, error) {
+	switch rd.state {
+	case stateGround:
+		if rd.outer.state == scanEsc && c == 'P' {
+			rd.outer.state = scanGround
+			rd.state = stateDCSPrefix
+			rd.dcsPrefix = rd.dcsPrefix[:0]
+			return Sequence{}, false, nil
+		}
+		return rd.outer.feed(c)
+
+	case stateDCSPrefix:
+		const tmuxPrefix = "tmux;"
+		rd.dcsPrefix = append(rd.dcsPrefix, c)
+		n := len(rd.dcsPrefix)
+		if n <= len(tmuxPrefix) && string(rd.dcsPrefix) == tmuxPrefix[:n] {
+			if n == len(tmuxPrefix) {
+				rd.state, rd.dcsTmux = stateDCS, true
+			}
+			return Sequence{}, false, nil
+		}
+		// a screen chunk either starts an OSC string or continues the one of the previous chunk
+		rd.state, rd.dcsTmux = stateDCSRaw, false
+		if rd.dcsPrefix[0] == esc || rd.inner.state != scanGround {
+			rd.state = stateDCS
+		} else {
+			rd.out.WriteString("\x1bP")
+		}
+		// process the buffered bytes again in the new state
+		rd.pending = append(append([]byte(nil), rd.dcsPrefix...), rd.pending...)
+		return Sequence{}, false, nil
+
+	case stateDCS:
+		if c == esc {
+			rd.state = stateDCSEsc
+			return Sequence{}, false, nil
+		}
+		return rd.inner.feed(c)
+
+	case stateDCSEsc:
+		rd.state = stateDCS
+		switch {
+		case c == '\\':
+			rd.state = stateGround
+			if rd.dcsTmux {
+				rd.inner.reset()
+			}
+			return Sequence{}, false, nil
+		case rd.dcsTmux && c == esc:
+			// tmux doubles every ESC of the wrapped sequence
+			return rd.inner.feed(esc)
+		}
+		rd.inner.feed(esc)
+		return rd.inner.feed(c)
+
+	case stateDCSRaw:
+		rd.out.WriteByte(c)
+		if c == esc {
+			rd.state = stateDCSRawEsc
+		}
+	case stateDCSRawEsc:
+		rd.out.WriteByte(c)
+		rd.state = stateDCSRaw
+		if c == '\\' {
+			rd.state = stateGround
+		}
+	}
+	return Sequence{}, false, nil
+}
+
+// scanner states
+const (
+	scanGround = iota
+	scanEsc
+	scanOSC
+	scanOSCEsc
+	scanOSCForward
+	scanOSCForwardEsc
+)
+
+// scanner recognizes OSC 52 strings, forwarding every other byte
+type scanner struct {
+	out   *bufio.Writer
+	max   int
+	state int
+	buf   []byte
+	// tooLarge is set once the payload exceeded max, the remainder is discarded
+	tooLarge bool
+}
+
+func (s *scanner) reset() {
+	s.state = scanGround
+	s.buf = s.buf[:0]
+	s.tooLarge = false
+}
+
+func (s *scanner) feed(c byte) (Sequence, bool, error) {
+	switch s.state {
+	case scanGround:
+		if c == esc {
+			s.state = scanEsc
+			return Sequence{}, false, nil
+		}
+		s.out.WriteByte(c)
+
+	case scanEsc:
+		switch c {
+		case ']':
+			s.state = scanOSC
+			s.buf = s.buf[:0]
+			s.tooLarge = false
+		case esc:
+			s.out.WriteByte(esc)
+		default:
+			s.state = scanGround
+			s.out.WriteByte(esc)
+			s.out.WriteByte(c)
+		}
+
+	case scanOSC:
+		switch c {
+		case bel:
+			return s.finish()
+		case esc:
+			s.state = scanOSCEsc
+			return Sequence{}, false, nil
+		}
+		if len(s.buf) < 3 {
+			s.buf = append(s.buf, c)
+			if string(s.buf) != "52;"[:len(s.buf)] {
+				// not ours, forward everything up to the terminator
+				s.out.WriteString("\x1b]")
+				s.out.Write(s.buf)
+				s.state = scanOSCForward
+			}
+			return Sequence{}, false, nil
+		}
+		if len(s.buf) > s.max+16 {
+			s.tooLarge = true
+			return Sequence{}, false, nil
+		}
+		s.buf = append(s.buf, c)
+
+	case scanOSCEsc:
+		if c == '\\' {
+			return s.finish()
+		}
+		// ESC aborts the OSC string and starts a new escape sequence
+		s.reset()
+		s.state = scanEsc
+		return s.feed(c)
+
+	case scanOSCForward:
+		s.out.WriteByte(c)
+		switch c {
+		case bel:
+			s.state = scanGround
+		case esc:
+			s.state = scanOSCForwardEsc
+		}
+	case scanOSCForwardEsc:
+		s.out.WriteByte(c)
+		s.state = scanOSCForward
+		if c == '\\' {
+			s.state = scanGround
+		}
+	}
+	return Sequence{}, false, nil
+}
+
+func (s *scanner) finish() (Sequence, bool, error) {
+	s.state = scanGround
+	if s.tooLarge {
+		s.tooLarge = false
+		return Sequence{}, false, fmt.Errorf("more than %d bytes. %w", s.max, ErrTooLarge)
+	}
+	if len(s.buf) < 3 {
+		// "ESC ] 5 BEL" and similar are incomplete OSC strings of some other kind
+		s.out.WriteString("\x1b]")
+		s.out.Write(s.buf)
+		s.out.WriteByte(bel)
+		return Sequence{}, false, nil
+	}
+	seq, err := parseBody(s.buf[3:])
+	if err != nil {
+		return Sequence{}, false, err
+	}
+	return seq, true, nil
+}
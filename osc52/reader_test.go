@@ -0,0 +1,112 @@
+package osc52
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// readAll returns the sequences, errors and passthrough output of in
+func readAll(t *testing.T, r io.Reader, max int) ([]Sequence, []error, string) {
+	t.Helper()
+	var out bytes.Buffer
+	rd := NewReader(r, &out)
+	rd.MaxPayload = max
+	var seqs []Sequence
+	var errs []error
+	for {
+		seq, err := rd.Next()
+		if err == io.EOF {
+			return seqs, errs, out.String()
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidSequence) && !errors.Is(err, ErrTooLarge) {
+				t.Fatal(err)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+}
+
+func TestReader(t *testing.T) {
+	tmux, _ := Encoder{Wrap: WrapTmux}.Encode(Sequence{Targets: "c", Data: []byte("tmux")})
+	screen, _ := Encoder{Wrap: WrapScreen}.Encode(Sequence{Targets: "c", Data: []byte("screen")})
+	tests := []struct {
+		name string
+		in   string
+		data []string
+		out  string
+	}{
+		{"plain output", "hello\x1b[1mworld\x1b[0m\r\n", nil, "hello\x1b[1mworld\x1b[0m\r\n"},
+		{"BEL", "a\x1b]52;c;aGk=\ab", []string{"hi"}, "ab"},
+		{"ST", "a\x1b]52;c;aGk=\x1b\\b", []string{"hi"}, "ab"},
+		{"query", "\x1b]52;c;?\a", []string{""}, ""},
+		{"other OSC", "\x1b]0;title\a\x1b]8;;http://x\x1b\\", nil, "\x1b]0;title\a\x1b]8;;http://x\x1b\\"},
+		{"short OSC", "\x1b]5\a", nil, "\x1b]5\a"},
+		{"tmux", "a" + string(tmux) + "b", []string{"tmux"}, "ab"},
+		{"screen", "a" + string(screen) + "b", []string{"screen"}, "ab"},
+		{"other DCS", "\x1bPq#0;2;0;0;0\x1b\\x", nil, "\x1bPq#0;2;0;0;0\x1b\\x"},
+		{"several", "\x1b]52;c;MQ==\a\x1b]52;c;Mg==\x1b\\", []string{"1", "2"}, ""},
+		{"aborted by ESC", "\x1b]52;c;MQ\x1b[0m", nil, "\x1b[0m"},
+	}
+	for _, tt := range tests {
+		for _, split := range []bool{false, true} {
+			name := tt.name
+			var r io.Reader = strings.NewReader(tt.in)
+			if split {
+				// every byte arrives in its own read
+				name += "/split"
+				r = iotest.OneByteReader(r)
+			}
+			t.Run(name, func(t *testing.T) {
+				seqs, errs, out := readAll(t, r, 0)
+				if len(errs) > 0 {
+					t.Fatalf("errors %v", errs)
+				}
+				var data []string
+				for _, s := range seqs {
+					data = append(data, string(s.Data))
+				}
+				if strings.Join(data, ",") != strings.Join(tt.data, ",") || len(data) != len(tt.data) {
+					t.Errorf("sequences %q, want %q", data, tt.data)
+				}
+				if out != tt.out {
+					t.Errorf("passthrough %q, want %q", out, tt.out)
+				}
+			})
+		}
+	}
+}
+
+func TestReaderLimit(t *testing.T) {
+	big, _ := Encoder{}.Encode(Sequence{Targets: "c", Data: bytes.Repeat([]byte("x"), 300)})
+	small, _ := Encoder{}.Encode(Sequence{Targets: "c", Data: []byte("ok")})
+	in := string(big) + "between" + string(small)
+
+	seqs, errs, out := readAll(t, iotest.HalfReader(strings.NewReader(in)), 100)
+	if len(errs) != 1 || !errors.Is(errs[0], ErrTooLarge) {
+		t.Errorf("errors %v, want one ErrTooLarge", errs)
+	}
+	// the reader recovers after the oversized sequence
+	if len(seqs) != 1 || string(seqs[0].Data) != "ok" {
+		t.Errorf("sequences %+v", seqs)
+	}
+	if out != "between" {
+		t.Errorf("passthrough %q", out)
+	}
+}
+
+func TestReaderInvalid(t *testing.T) {
+	seqs, errs, out := readAll(t, strings.NewReader("\x1b]52;c;!!!\a\x1b]52;c;MQ==\a"), 0)
+	if len(errs) != 1 || !errors.Is(errs[0], ErrInvalidSequence) {
+		t.Errorf("errors %v, want one ErrInvalidSequence", errs)
+	}
+	if len(seqs) != 1 || string(seqs[0].Data) != "1" || out != "" {
+		t.Errorf("sequences %+v, passthrough %q", seqs, out)
+	}
+}
@@ -0,0 +1,166 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrSeedUnsupported = errors.New("backend does not accept format ids")
+
+// DefaultRegistry resolves formats of the system clipboard.
+// On platforms without one, registered ids are allocated in memory.
+var DefaultRegistry = NewRegistry(defaultBackend())
+
+// Registry caches format ids and names in both directions.
+//
+// Names of registered formats are resolved and registered through its Backend once,
+// pre-defined formats are never looked up.
+type Registry struct {
+	backend Backend
+
+	mu     sync.RWMutex
+	byID   map[Format]string
+	byName map[string]Format
+	nextID Format
+}
+
+// formatSeeder is implemented by backends that accept ids chosen by the caller
+type formatSeeder interface {
+	SeedFormat(id Format, name string) error
+}
+
+// NewRegistry returns a Registry resolving formats through b.
+// If b is nil, ids of registered formats are allocated by the Registry itself.
+func NewRegistry(b Backend) *Registry {
+	return &Registry{
+		backend: b,
+		byID:    map[Format]string{},
+		byName:  map[string]Format{},
+		nextID:  firstRegisteredFormat,
+	}
+}
+
+// Seed adds known registered formats, keyed by name.
+//
+// This lets code relying on specific ids (e.g. recorded from a real clipboard)
+// run against a MemoryBackend, which is seeded as well.
+// All entries are validated first, on error neither the Registry nor the backend is changed.
+// Backends choosing ids themselves, like the system clipboard, return ErrSeedUnsupported.
+func (r *Registry) Seed(formats map[string]Format) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seeder, ok := r.backend.(formatSeeder)
+	if r.backend != nil && !ok {
+		return fmt.Errorf("%T. %w", r.backend, ErrSeedUnsupported)
+	}
+
+	names := make(map[Format]string, len(formats))
+	for name, id := range formats {
+		if !id.IsRegistered() {
+			return fmt.Errorf("%q: %d is not a registered format id", name, id)
+		}
+		if other, ok := names[id]; ok {
+			return fmt.Errorf("%q: id %d is also used by %q", name, id, other)
+		}
+		names[id] = name
+		if v, ok := r.byID[id]; ok && v != name {
+			return fmt.Errorf("%q: id %d is already used by %q", name, id, v)
+		}
+		if v, ok := r.byName[name]; ok && v != id {
+			return fmt.Errorf("%q is already registered as %d", name, v)
+		}
+		if seeder != nil {
+			if v, err := r.backend.FormatName(id); err == nil && v != name {
+				return fmt.Errorf("%q: id %d is already used by %q", name, id, v)
+			}
+		}
+	}
+
+	for name, id := range formats {
+		if seeder != nil {
+			if err := seeder.SeedFormat(id, name); err != nil {
+				return err
+			}
+		}
+		r.byID[id] = name
+		r.byName[name] = id
+		if id >= r.nextID {
+			r.nextID = id + 1
+		}
+	}
+	return nil
+}
+
+// Name returns a readable name for id.
+//
+// Being either a pre-defined name, or the name the format was registered with.
+func (r *Registry) Name(id Format) (string, error) {
+	if !id.IsRegistered() {
+		return predefinedFormatName(id)
+	}
+	r.mu.RLock()
+	name, ok := r.byID[id]
+	r.mu.RUnlock()
+	if ok {
+		return name, nil
+	}
+	if r.backend == nil {
+		return "", fmt.Errorf("unregistered format %d. %w", id, ErrUnknownClipboardFormat)
+	}
+
+	name, err := r.backend.FormatName(id)
+	if err != nil {
+		return "", err
+	}
+	r.add(id, name)
+	return name, nil
+}
+
+// LookupByName returns the id of a pre-defined or already known format, without registering it
+func (r *Registry) LookupByName(name string) (Format, bool) {
+	if id, ok := predefinedFormatID(name); ok {
+		return id, true
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	id, ok := r.byName[name]
+	return id, ok
+}
+
+// Register returns the id of the format name, registering it if necessary.
+// Names of pre-defined formats (e.g. "CF_UNICODETEXT") return their fixed id.
+func (r *Registry) Register(name string) (Format, error) {
+	if id, ok := r.LookupByName(name); ok {
+		return id, nil
+	}
+	if r.backend == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if id, ok := r.byName[name]; ok {
+			return id, nil
+		}
+		if r.nextID > 0xFFFF {
+			return 0, fmt.Errorf("no format ids left for %q", name)
+		}
+		id := r.nextID
+		r.nextID++
+		r.byID[id] = name
+		r.byName[name] = id
+		return id, nil
+	}
+
+	id, err := r.backend.RegisterFormat(name)
+	if err != nil {
+		return 0, err
+	}
+	r.add(id, name)
+	return id, nil
+}
+
+func (r *Registry) add(id Format, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byID[id] = name
+	r.byName[name] = id
+}
@@ -0,0 +1,181 @@
+package clipboard
+
+import (
+	"errors"
+	"testing"
+)
+
+// countingBackend counts the lookups reaching the wrapped backend.
+// Embedding the interface hides SeedFormat of a MemoryBackend.
+type countingBackend struct {
+	Backend
+	names, registers int
+}
+
+func (b *countingBackend) FormatName(id Format) (string, error) {
+	b.names++
+	return b.Backend.FormatName(id)
+}
+
+func (b *countingBackend) RegisterFormat(name string) (Format, error) {
+	b.registers++
+	return b.Backend.RegisterFormat(name)
+}
+
+func TestRegistryCaching(t *testing.T) {
+	b := &countingBackend{Backend: NewMemoryBackend()}
+	r := NewRegistry(b)
+
+	if id, err := r.Register("CF_UNICODETEXT"); err != nil || id != CF_UNICODETEXT {
+		t.Errorf("Register(CF_UNICODETEXT) = %d, %v", id, err)
+	}
+	if name, err := r.Name(CF_HDROP); err != nil || name != "CF_HDROP" {
+		t.Errorf("Name(CF_HDROP) = %q, %v", name, err)
+	}
+	if b.names != 0 || b.registers != 0 {
+		t.Errorf("pre-defined formats were looked up: %d names, %d registrations", b.names, b.registers)
+	}
+
+	if _, ok := r.LookupByName("Custom"); ok {
+		t.Error("LookupByName found a format before it was registered")
+	}
+	id, err := r.Register("Custom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := r.Register("Custom"); err != nil || again != id {
+		t.Errorf("registering again = %d, %v, want %d", again, err, id)
+	}
+	if v, ok := r.LookupByName("Custom"); !ok || v != id {
+		t.Errorf("LookupByName = %d, %v, want %d", v, ok, id)
+	}
+	if name, err := r.Name(id); err != nil || name != "Custom" {
+		t.Errorf("Name = %q, %v", name, err)
+	}
+	if b.registers != 1 || b.names != 0 {
+		t.Errorf("%d registrations and %d name lookups, want 1 and 0", b.registers, b.names)
+	}
+
+	// formats registered by others are resolved once
+	other, err := b.Backend.RegisterFormat("Other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if name, err := r.Name(other); err != nil || name != "Other" {
+			t.Errorf("Name = %q, %v", name, err)
+		}
+	}
+	if b.names != 1 {
+		t.Errorf("%d name lookups, want 1", b.names)
+	}
+	if v, ok := r.LookupByName("Other"); !ok || v != other {
+		t.Errorf("LookupByName after Name = %d, %v", v, ok)
+	}
+	if _, err := r.Name(0xFEDC); !errors.Is(err, ErrUnknownClipboardFormat) {
+		t.Errorf("unknown id: got %v, want ErrUnknownClipboardFormat", err)
+	}
+}
+
+func TestRegistryWithoutBackend(t *testing.T) {
+	r := NewRegistry(nil)
+	a, err := r.Register("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := r.Register("B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != firstRegisteredFormat || b != a+1 {
+		t.Errorf("allocated %#x and %#x", a, b)
+	}
+	if again, _ := r.Register("A"); again != a {
+		t.Errorf("registering again = %#x, want %#x", again, a)
+	}
+	if _, err := r.Name(0xFEDC); !errors.Is(err, ErrUnknownClipboardFormat) {
+		t.Errorf("unknown id: got %v, want ErrUnknownClipboardFormat", err)
+	}
+
+	if err := r.Seed(map[string]Format{"Last": 0xFFFF}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Register("C"); err == nil {
+		t.Error("an id beyond 0xFFFF was allocated")
+	}
+}
+
+func TestRegistrySeed(t *testing.T) {
+	b := NewMemoryBackend()
+	r := NewRegistry(b)
+	if err := r.Seed(map[string]Format{"HTML Format": 0xC0F0, "Rich Text Format": 0xC0A5}); err != nil {
+		t.Fatal(err)
+	}
+	for name, id := range map[string]Format{"HTML Format": 0xC0F0, "Rich Text Format": 0xC0A5} {
+		if v, ok := r.LookupByName(name); !ok || v != id {
+			t.Errorf("LookupByName(%q) = %#x, %v", name, v, ok)
+		}
+		if v, err := b.FormatName(id); err != nil || v != name {
+			t.Errorf("backend name of %#x = %q, %v", id, v, err)
+		}
+	}
+	// new formats are allocated above the seeded ones
+	if id, err := r.Register("New"); err != nil || id != 0xC0F1 {
+		t.Errorf("Register = %#x, %v, want 0xC0F1", id, err)
+	}
+	// seeding the same entries again is fine
+	if err := r.Seed(map[string]Format{"HTML Format": 0xC0F0}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegistrySeedErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		formats map[string]Format
+	}{
+		{"pre-defined id", map[string]Format{"A": CF_TEXT}},
+		{"duplicate id", map[string]Format{"A": 0xC100, "B": 0xC100}},
+		{"id of another name", map[string]Format{"A": 0xC000, "Taken": 0xC200}},
+		{"name with another id", map[string]Format{"Known": 0xC201}},
+		{"id used by the backend", map[string]Format{"B": 0xC202}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewMemoryBackend()
+			r := NewRegistry(b)
+			if err := r.Seed(map[string]Format{"Taken": 0xC000, "Known": 0xC001}); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.SeedFormat(0xC202, "Backend"); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Seed(tt.formats); err == nil {
+				t.Fatal("Seed succeeded")
+			}
+			// nothing was changed
+			for name := range tt.formats {
+				if name == "Taken" || name == "Known" {
+					continue
+				}
+				if id, ok := r.LookupByName(name); ok {
+					t.Errorf("%q was added as %#x", name, id)
+				}
+			}
+			if name, err := b.FormatName(0xC200); err == nil {
+				t.Errorf("the backend was seeded with %q", name)
+			}
+		})
+	}
+}
+
+func TestRegistrySeedUnsupported(t *testing.T) {
+	b := &countingBackend{Backend: NewMemoryBackend()}
+	r := NewRegistry(b)
+	if err := r.Seed(map[string]Format{"A": 0xC100}); !errors.Is(err, ErrSeedUnsupported) {
+		t.Fatalf("got %v, want ErrSeedUnsupported", err)
+	}
+	if id, ok := r.LookupByName("A"); ok {
+		t.Errorf("the id %#x was cached anyway", id)
+	}
+}
@@ -0,0 +1,125 @@
+package rfb
+
+import (
+	"bytes"
+	"strings"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+// clipboardFormats maps the RFB formats the adapter converts to clipboard formats.
+// register resolves the registered formats, typically Backend.RegisterFormat.
+func clipboardFormats(register func(name string) (clipboard.Format, error)) (map[Format]clipboard.Format, error) {
+	rtf, err := register(clipboard.RTFFormatName)
+	if err != nil {
+		return nil, err
+	}
+	html, err := register(clipboard.HTMLFormatName)
+	if err != nil {
+		return nil, err
+	}
+	return map[Format]clipboard.Format{
+		FormatText: clipboard.CF_UNICODETEXT,
+		FormatRTF:  rtf,
+		FormatHTML: html,
+		FormatDIB:  clipboard.CF_DIB,
+	}, nil
+}
+
+// ToClipboard converts the data of an ActionProvide message into clipboard items,
+// in the order of the RFB format bits. register resolves the ids of RTF and HTML.
+//
+// Text becomes NUL terminated UTF-16 CF_UNICODETEXT,
+// RTF and HTML (already CF_HTML) are passed through with a terminating NUL, DIB is passed through.
+func ToClipboard(m *ExtendedClipboard, register func(name string) (clipboard.Format, error)) ([]clipboard.Item, error) {
+	formats, err := clipboardFormats(register)
+	if err != nil {
+		return nil, err
+	}
+	var items []clipboard.Item
+	for _, f := range []Format{FormatText, FormatRTF, FormatHTML, FormatDIB} {
+		data, ok := m.Data[f]
+		if !ok {
+			continue
+		}
+		switch f {
+		case FormatText:
+			data = clipboard.EncodeUnicodeText(string(trimNUL(data)))
+		case FormatRTF, FormatHTML:
+			data = append(trimNUL(data), 0)
+		default:
+			data = append([]byte(nil), data...)
+		}
+		items = append(items, clipboard.Item{Format: formats[f], Data: data})
+	}
+	return items, nil
+}
+
+// FromClipboard creates an ActionProvide message from clipboard items,
+// ignoring formats that have no RFB counterpart. register resolves the ids of RTF and HTML.
+func FromClipboard(items []clipboard.Item, register func(name string) (clipboard.Format, error)) (*ExtendedClipboard, error) {
+	formats, err := clipboardFormats(register)
+	if err != nil {
+		return nil, err
+	}
+	m := &ExtendedClipboard{Action: ActionProvide, Data: map[Format][]byte{}}
+	for _, item := range items {
+		for f, id := range formats {
+			if item.Format != id {
+				continue
+			}
+			switch f {
+			case FormatText:
+				m.Data[f] = append([]byte(toCRLF(clipboard.DecodeUnicodeText(item.Data))), 0)
+			case FormatRTF, FormatHTML:
+				m.Data[f] = append(trimNUL(item.Data), 0)
+			default:
+				m.Data[f] = append([]byte(nil), item.Data...)
+			}
+			m.Formats |= f
+		}
+	}
+	return m, nil
+}
+
+// Notify creates the ActionNotify message announcing the formats of ids FromClipboard would provide
+func Notify(ids []clipboard.Format, register func(name string) (clipboard.Format, error)) (*ExtendedClipboard, error) {
+	formats, err := clipboardFormats(register)
+	if err != nil {
+		return nil, err
+	}
+	m := &ExtendedClipboard{Action: ActionNotify}
+	for _, id := range ids {
+		for f, v := range formats {
+			if id == v {
+				m.Formats |= f
+			}
+		}
+	}
+	return m, nil
+}
+
+// CutTextToClipboard converts the text of a plain cut text message into CF_UNICODETEXT data
+func CutTextToClipboard(m *CutText) []byte {
+	return clipboard.EncodeUnicodeText(toCRLF(m.Text))
+}
+
+// CutTextFromClipboard creates a plain cut text message from CF_UNICODETEXT data.
+// Characters outside of ISO 8859-1 are replaced by '?'.
+func CutTextFromClipboard(data []byte) *CutText {
+	text := clipboard.DecodeUnicodeText(data)
+	return &CutText{Text: strings.ReplaceAll(text, "\r\n", "\n")}
+}
+
+// trimNUL returns a copy of b up to the first NUL
+func trimNUL(b []byte) []byte {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return append([]byte(nil), b...)
+}
+
+func toCRLF(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
@@ -0,0 +1,273 @@
+// Package rfb encodes and decodes the clipboard messages of the RFB (VNC) protocol,
+// ServerCutText and ClientCutText, including the Extended Clipboard pseudo-encoding.
+package rfb
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Message types of the cut text messages
+const (
+	MsgServerCutText = 3
+	MsgClientCutText = 6
+)
+
+// EncodingExtendedClipboard is the pseudo-encoding a client announces
+// in SetEncodings to enable extended clipboard messages
+const EncodingExtendedClipboard int32 = -1063131698 // 0xC0A1E5CE
+
+// Format is a bit in the formats field of an extended clipboard message
+type Format uint32
+
+const (
+	FormatText  Format = 1 << 0
+	FormatRTF   Format = 1 << 1
+	FormatHTML  Format = 1 << 2
+	FormatDIB   Format = 1 << 3
+	FormatFiles Format = 1 << 4
+
+	formatMask Format = 0xFFFF
+)
+
+// Action is a bit in the actions field of an extended clipboard message
+type Action uint32
+
+const (
+	ActionCaps    Action = 1 << 24
+	ActionRequest Action = 1 << 25
+	ActionPeek    Action = 1 << 26
+	ActionNotify  Action = 1 << 27
+	ActionProvide Action = 1 << 28
+
+	actionMask Action = 0xFF000000
+)
+
+var (
+	ErrMessageTooLarge = errors.New("rfb: cut text exceeds size limit")
+	ErrMalformed       = errors.New("rfb: malformed extended clipboard message")
+)
+
+// Message is either *CutText or *ExtendedClipboard
+type Message interface {
+	payload() ([]byte, error)
+	extended() bool
+}
+
+// CutText is a plain ServerCutText or ClientCutText message
+type CutText struct {
+	// Text is decoded from ISO 8859-1, lines are separated by a single LF
+	Text string
+}
+
+func (*CutText) extended() bool { return false }
+func (m *CutText) payload() ([]byte, error) {
+	return encodeLatin1(m.Text), nil
+}
+
+// ExtendedClipboard is a cut text message using the Extended Clipboard pseudo-encoding.
+// Exactly one action has to be set.
+type ExtendedClipboard struct {
+	Action Action
+	// Actions lists the other actions the sender supports, used with ActionCaps
+	Actions Action
+	Formats Format
+	// Sizes holds the maximum unsolicited size per format, used with ActionCaps
+	Sizes map[Format]uint32
+	// Data holds the payload per format, used with ActionProvide
+	Data map[Format][]byte
+}
+
+func (*ExtendedClipboard) extended() bool { return true }
+func (m *ExtendedClipboard) payload() ([]byte, error) {
+	var buf bytes.Buffer
+	flags := uint32(m.Action) | uint32(m.Formats&formatMask)
+	if m.Action == ActionCaps {
+		flags |= uint32(m.Actions & actionMask)
+	}
+	writeUint32(&buf, flags)
+
+	switch m.Action {
+	case ActionCaps:
+		for f := Format(1); f != 0 && f <= m.Formats; f <<= 1 {
+			if m.Formats&f != 0 {
+				writeUint32(&buf, m.Sizes[f])
+			}
+		}
+	case ActionProvide:
+		zw := zlib.NewWriter(&buf)
+		for f := Format(1); f != 0 && f <= m.Formats; f <<= 1 {
+			if m.Formats&f == 0 {
+				continue
+			}
+			data := m.Data[f]
+			var size [4]byte
+			binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+			zw.Write(size[:])
+			zw.Write(data)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	case ActionRequest, ActionPeek, ActionNotify:
+	default:
+		return nil, fmt.Errorf("action %#x. %w", uint32(m.Action), ErrMalformed)
+	}
+	return buf.Bytes(), nil
+}
+
+// Caps creates the ActionCaps message announcing the formats of sizes,
+// with the maximum size the sender accepts unsolicited, and the supported actions
+func Caps(actions Action, sizes map[Format]uint32) *ExtendedClipboard {
+	m := &ExtendedClipboard{Action: ActionCaps, Actions: actions &^ ActionCaps, Sizes: map[Format]uint32{}}
+	for f, size := range sizes {
+		m.Formats |= f
+		m.Sizes[f] = size
+	}
+	return m
+}
+
+// WriteMessage writes m as a message of msgType (MsgServerCutText or MsgClientCutText)
+func WriteMessage(w io.Writer, msgType byte, m Message) error {
+	payload, err := m.payload()
+	if err != nil {
+		return err
+	}
+	hdr := make([]byte, 8)
+	hdr[0] = msgType
+	length := int32(len(payload))
+	if m.extended() {
+		// a negative length marks the extended format
+		length = -length
+	}
+	binary.BigEndian.PutUint32(hdr[4:], uint32(length))
+	_, err = w.Write(append(hdr, payload...))
+	return err
+}
+
+// ReadMessage reads the remainder of a cut text message from r,
+// after its message-type byte has been consumed.
+//
+// maxSize limits the payload and the decompressed data of extended messages.
+func ReadMessage(r io.Reader, maxSize uint32) (Message, error) {
+	hdr := make([]byte, 7)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	length := int32(binary.BigEndian.Uint32(hdr[3:]))
+	extended := length < 0
+	size := uint32(length)
+	if extended {
+		size = uint32(-int64(length))
+	}
+	if size > maxSize {
+		return nil, fmt.Errorf("%d bytes. %w", size, ErrMessageTooLarge)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if !extended {
+		return &CutText{Text: decodeLatin1(payload)}, nil
+	}
+	return decodeExtended(payload, maxSize)
+}
+
+func decodeExtended(payload []byte, maxSize uint32) (*ExtendedClipboard, error) {
+	if len(payload) < 4 {
+		return nil, fmt.Errorf("missing flags. %w", ErrMalformed)
+	}
+	flags := binary.BigEndian.Uint32(payload)
+	payload = payload[4:]
+	m := &ExtendedClipboard{
+		Action:  Action(flags) & actionMask,
+		Formats: Format(flags) & formatMask,
+	}
+	// caps messages set the bits of all supported actions next to ActionCaps
+	if m.Action&ActionCaps != 0 {
+		m.Actions = m.Action &^ ActionCaps
+		m.Action = ActionCaps
+	}
+
+	switch m.Action {
+	case ActionCaps:
+		m.Sizes = map[Format]uint32{}
+		for f := Format(1); f <= m.Formats; f <<= 1 {
+			if m.Formats&f == 0 {
+				continue
+			}
+			if len(payload) < 4 {
+				return nil, fmt.Errorf("missing size of format %#x. %w", uint32(f), ErrMalformed)
+			}
+			m.Sizes[f] = binary.BigEndian.Uint32(payload)
+			payload = payload[4:]
+		}
+	case ActionProvide:
+		zr, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("%v. %w", err, ErrMalformed)
+		}
+		defer zr.Close()
+		remaining := maxSize
+		m.Data = map[Format][]byte{}
+		for f := Format(1); f <= m.Formats; f <<= 1 {
+			if m.Formats&f == 0 {
+				continue
+			}
+			var size [4]byte
+			if _, err := io.ReadFull(zr, size[:]); err != nil {
+				// senders may stop early, omitting formats that turned out to be too large
+				if err == io.EOF {
+					break
+				}
+				return nil, fmt.Errorf("%v. %w", err, ErrMalformed)
+			}
+			n := binary.BigEndian.Uint32(size[:])
+			if n > remaining {
+				return nil, fmt.Errorf("%d bytes of format %#x. %w", n, uint32(f), ErrMessageTooLarge)
+			}
+			remaining -= n
+			data := make([]byte, n)
+			if _, err := io.ReadFull(zr, data); err != nil {
+				return nil, fmt.Errorf("%v. %w", err, ErrMalformed)
+			}
+			m.Data[f] = data
+		}
+	case ActionRequest, ActionPeek, ActionNotify:
+	default:
+		return nil, fmt.Errorf("action %#x. %w", uint32(m.Action), ErrMalformed)
+	}
+	return m, nil
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+func encodeLatin1(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xFF {
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	return b
+}
+
+func decodeLatin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
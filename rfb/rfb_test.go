@@ -0,0 +1,196 @@
+package rfb
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+func TestDecodeCaps(t *testing.T) {
+	// caps of a server supporting text and HTML with request, peek, notify and provide
+	msg, _ := hex.DecodeString("03000000" + "fffffff4" +
+		"1f000005" + // all actions, FormatText | FormatHTML
+		"00005000" + "00001000") // sizes of text and HTML
+	m, err := ReadMessage(bytes.NewReader(msg[1:]), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ExtendedClipboard{
+		Action:  ActionCaps,
+		Actions: ActionRequest | ActionPeek | ActionNotify | ActionProvide,
+		Formats: FormatText | FormatHTML,
+		Sizes:   map[Format]uint32{FormatText: 0x5000, FormatHTML: 0x1000},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("decoded %#v, want %#v", m, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, MsgServerCutText, Caps(want.Actions, want.Sizes)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), msg) {
+		t.Errorf("encoded %x, want %x", buf.Bytes(), msg)
+	}
+}
+
+func TestExtendedRoundTrip(t *testing.T) {
+	tests := []*ExtendedClipboard{
+		{Action: ActionRequest, Formats: FormatText},
+		{Action: ActionNotify, Formats: FormatText | FormatRTF},
+		{Action: ActionProvide, Formats: FormatText, Data: map[Format][]byte{FormatText: []byte("hello\r\n\x00")}},
+		Caps(ActionProvide, map[Format]uint32{FormatDIB: 1 << 20}),
+	}
+	for _, m := range tests {
+		var buf bytes.Buffer
+		if err := WriteMessage(&buf, MsgClientCutText, m); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadMessage(bytes.NewReader(buf.Bytes()[1:]), 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, m) {
+			t.Errorf("got %#v, want %#v", got, m)
+		}
+	}
+}
+
+func TestDecodeInvalidAction(t *testing.T) {
+	// request and peek without caps is no single action
+	if _, err := decodeExtended([]byte{0x06, 0, 0, 1}, 1<<20); !errors.Is(err, ErrMalformed) {
+		t.Errorf("got %v, want ErrMalformed", err)
+	}
+}
+
+// provide returns the wire form of an ActionProvide message with the given formats and sizes
+func provide(t *testing.T, formats Format, data map[Format][]byte) []byte {
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, MsgServerCutText, &ExtendedClipboard{Action: ActionProvide, Formats: formats, Data: data}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()[1:]
+}
+
+func TestReadMessageLimits(t *testing.T) {
+	big := make([]byte, 1<<16)
+	half := make([]byte, 600)
+	tests := []struct {
+		name    string
+		msg     []byte
+		maxSize uint32
+		err     error
+	}{
+		{"cut text", append([]byte{0, 0, 0, 0, 0, 0, 10}, "0123456789"...), 10, nil},
+		{"cut text too large", append([]byte{0, 0, 0, 0, 0, 0, 11}, "0123456789a"...), 10, ErrMessageTooLarge},
+		{"extended too large", []byte{0, 0, 0, 0xFF, 0xFF, 0, 0}, 1 << 10, ErrMessageTooLarge},
+		// a few compressed bytes inflate to 64 KiB
+		{"inflated", provide(t, FormatText, map[Format][]byte{FormatText: big}), 1 << 10, ErrMessageTooLarge},
+		{"inflated in total", provide(t, FormatText|FormatHTML, map[Format][]byte{FormatText: half, FormatHTML: half}), 1 << 10, ErrMessageTooLarge},
+		{"inflated at limit", provide(t, FormatText|FormatHTML, map[Format][]byte{FormatText: half, FormatHTML: half}), 1200, nil},
+		{"truncated", []byte{0, 0, 0, 0xFF, 0xFF, 0xFF, 0xF8, 0x10, 0, 0, 1}, 1 << 10, io.ErrUnexpectedEOF},
+		{"not compressed", append([]byte{0, 0, 0, 0xFF, 0xFF, 0xFF, 0xF8, 0x10, 0, 0, 1}, "text"...), 1 << 10, ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if strings.HasPrefix(tt.name, "inflated") && uint32(len(tt.msg)-7) > tt.maxSize {
+				t.Fatalf("the compressed payload of %d bytes exceeds the limit itself", len(tt.msg)-7)
+			}
+			_, err := ReadMessage(bytes.NewReader(tt.msg), tt.maxSize)
+			if tt.err == nil && err != nil || !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestClipboardRoundTrip(t *testing.T) {
+	b := clipboard.NewMemoryBackend()
+	// ids other than the ones of the receiving backend
+	b.RegisterFormat("Other")
+	html := clipboard.HTML{Fragment: "<b>x</b>"}.Bytes()
+	items := []clipboard.Item{
+		{Format: clipboard.CF_LOCALE, Data: []byte{9, 4, 0, 0}},
+		{Format: clipboard.CF_DIB, Data: []byte("dib")},
+		{Format: mustRegister(t, b, clipboard.HTMLFormatName), Data: html},
+		{Format: mustRegister(t, b, clipboard.RTFFormatName), Data: []byte(`{\rtf1 x}` + "\x00")},
+		{Format: clipboard.CF_UNICODETEXT, Data: clipboard.EncodeUnicodeText("a\nb")},
+	}
+
+	m, err := FromClipboard(items, b.RegisterFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ExtendedClipboard{Action: ActionProvide, Formats: FormatText | FormatRTF | FormatHTML | FormatDIB, Data: map[Format][]byte{
+		FormatText: []byte("a\r\nb\x00"),
+		FormatRTF:  []byte(`{\rtf1 x}` + "\x00"),
+		FormatHTML: append(html, 0),
+		FormatDIB:  []byte("dib"),
+	}}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("FromClipboard = %#v, want %#v", m, want)
+	}
+	if n, err := Notify([]clipboard.Format{clipboard.CF_LOCALE, clipboard.CF_UNICODETEXT, items[2].Format}, b.RegisterFormat); err != nil || n.Action != ActionNotify || n.Formats != FormatText|FormatHTML {
+		t.Errorf("Notify = %#v, %v", n, err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, MsgClientCutText, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadMessage(bytes.NewReader(buf.Bytes()[1:]), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := clipboard.NewMemoryBackend()
+	result, err := ToClipboard(got.(*ExtendedClipboard), other.RegisterFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantItems := []clipboard.Item{
+		{Format: clipboard.CF_UNICODETEXT, Data: clipboard.EncodeUnicodeText("a\r\nb")},
+		{Format: mustRegister(t, other, clipboard.RTFFormatName), Data: []byte(`{\rtf1 x}` + "\x00")},
+		{Format: mustRegister(t, other, clipboard.HTMLFormatName), Data: append(html, 0)},
+		{Format: clipboard.CF_DIB, Data: []byte("dib")},
+	}
+	if !reflect.DeepEqual(result, wantItems) {
+		t.Errorf("ToClipboard = %v, want %v", result, wantItems)
+	}
+
+	errRegister := errors.New("register failed")
+	failing := func(string) (clipboard.Format, error) { return 0, errRegister }
+	if _, err := ToClipboard(got.(*ExtendedClipboard), failing); !errors.Is(err, errRegister) {
+		t.Errorf("got %v, want the register error", err)
+	}
+}
+
+func TestCutTextRoundTrip(t *testing.T) {
+	m := CutTextFromClipboard(clipboard.EncodeUnicodeText("a\r\nä€"))
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, MsgClientCutText, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadMessage(bytes.NewReader(buf.Bytes()[1:]), 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the euro sign is not part of ISO 8859-1
+	if text := clipboard.DecodeUnicodeText(CutTextToClipboard(got.(*CutText))); text != "a\r\nä?" {
+		t.Errorf("got %q", text)
+	}
+}
+
+func mustRegister(t *testing.T, b clipboard.Backend, name string) clipboard.Format {
+	id, err := b.RegisterFormat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
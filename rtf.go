@@ -0,0 +1,206 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// RTFFormatName is the name of the registered rich text clipboard format
+const RTFFormatName = "Rich Text Format"
+
+var ErrInvalidRTF = errors.New("invalid RTF data")
+
+// rtfSkipDestinations contain no document text
+var rtfSkipDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true,
+	"pict": true, "object": true, "header": true, "headerl": true,
+	"headerr": true, "headerf": true, "footer": true, "footerl": true,
+	"footerr": true, "footerf": true, "listtable": true, "listoverridetable": true,
+	"rsidtbl": true, "generator": true, "xmlnstbl": true, "themedata": true,
+	"colorschememapping": true, "datastore": true, "latentstyles": true,
+	"fldinst": true, "filetbl": true, "revtbl": true, "pgdsctbl": true,
+}
+
+// rtfSymbols are control words that stand for a single character
+var rtfSymbols = map[string]string{
+	"par": "\n", "line": "\n", "sect": "\n", "page": "\n", "row": "\n",
+	"tab": "\t", "cell": "\t",
+	"emdash": "—", "endash": "–", "bullet": "•",
+	"lquote": "‘", "rquote": "’", "ldblquote": "“", "rdblquote": "”",
+	"emspace": " ", "enspace": " ", "qmspace": " ",
+}
+
+// rtfCodePages maps \ansicpg values to their decoding table, defaulting to Windows-1252
+var rtfCodePages = map[int]*charmap.Charmap{
+	437: charmap.CodePage437, 850: charmap.CodePage850, 866: charmap.CodePage866,
+	874: charmap.Windows874, 1250: charmap.Windows1250, 1251: charmap.Windows1251,
+	1252: charmap.Windows1252, 1253: charmap.Windows1253, 1254: charmap.Windows1254,
+	1255: charmap.Windows1255, 1256: charmap.Windows1256, 1257: charmap.Windows1257,
+	1258: charmap.Windows1258,
+}
+
+// RTFToText extracts the plain text of an RTF document.
+//
+// Formatting, pictures and embedded objects are dropped,
+// paragraphs become line breaks and table cells are separated by tabs.
+func RTFToText(data []byte) (string, error) {
+	if !strings.HasPrefix(string(data), `{\rtf`) {
+		return "", ErrInvalidRTF
+	}
+	type group struct {
+		skip bool
+		// uc is the number of fallback characters following \u
+		uc int
+	}
+	var (
+		sb       strings.Builder
+		stack    []group
+		cur      = group{uc: 1}
+		codePage = charmap.Windows1252
+		// pending counts the fallback characters still to be dropped
+		pending int
+	)
+	emit := func(s string) {
+		if cur.skip {
+			return
+		}
+		if pending > 0 {
+			pending--
+			return
+		}
+		sb.WriteString(s)
+	}
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch c {
+		case '{':
+			stack = append(stack, cur)
+			pending = 0
+			i++
+		case '}':
+			if len(stack) == 0 {
+				return "", fmt.Errorf("unbalanced group. %w", ErrInvalidRTF)
+			}
+			cur = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			pending = 0
+			i++
+		case '\r', '\n':
+			i++
+		case '\\':
+			i++
+			if i >= len(data) {
+				return sb.String(), nil
+			}
+			c = data[i]
+			switch {
+			case c == '\'':
+				if i+2 >= len(data) {
+					return "", fmt.Errorf("truncated hex escape. %w", ErrInvalidRTF)
+				}
+				b, ok := unhex(data[i+1], data[i+2])
+				if !ok {
+					return "", fmt.Errorf("invalid hex escape. %w", ErrInvalidRTF)
+				}
+				emit(string(codePage.DecodeByte(b)))
+				i += 3
+			case c == '*':
+				cur.skip = true
+				i++
+			case isASCIILetter(c):
+				start := i
+				for i < len(data) && isASCIILetter(data[i]) {
+					i++
+				}
+				word := string(data[start:i])
+				num, hasNum := 0, false
+				neg := i < len(data) && data[i] == '-'
+				if neg {
+					i++
+				}
+				for i < len(data) && data[i] >= '0' && data[i] <= '9' && num < 1<<20 {
+					num = num*10 + int(data[i]-'0')
+					hasNum = true
+					i++
+				}
+				if neg {
+					num = -num
+				}
+				if i < len(data) && data[i] == ' ' {
+					i++
+				}
+
+				switch {
+				case rtfSkipDestinations[word]:
+					cur.skip = true
+				case word == "ansicpg" && hasNum:
+					if cp, ok := rtfCodePages[num]; ok {
+						codePage = cp
+					}
+				case word == "uc" && hasNum && num >= 0:
+					cur.uc = num
+				case word == "u" && hasNum:
+					if num < 0 {
+						num += 0x10000
+					}
+					r := rune(num)
+					if !utf8.ValidRune(r) {
+						r = utf8.RuneError
+					}
+					emit(string(r))
+					pending = cur.uc
+				case rtfSymbols[word] != "":
+					emit(rtfSymbols[word])
+				}
+			default:
+				// control symbols
+				switch c {
+				case '\\', '{', '}':
+					emit(string(c))
+				case '~':
+					emit(" ")
+				case '_':
+					emit("‑")
+				case '\r', '\n':
+					emit("\n")
+				}
+				i++
+			}
+		default:
+			if c < 0x80 {
+				emit(string(c))
+			} else {
+				emit(string(codePage.DecodeByte(c)))
+			}
+			i++
+		}
+	}
+	return sb.String(), nil
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func unhex(hi, lo byte) (byte, bool) {
+	h, ok1 := hexValue(hi)
+	l, ok2 := hexValue(lo)
+	return h<<4 | l, ok1 && ok2
+}
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
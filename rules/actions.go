@@ -0,0 +1,130 @@
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+func compileAction(a Action) (transform, error) {
+	switch a.Type {
+	case ActionStripTrackingParams:
+		params := append(append([]string{}, defaultTrackingParams...), a.Params...)
+		return func(c *Content) string { return stripTrackingParams(c.Text, params) }, nil
+	case ActionNormalizeNewlines:
+		switch a.Newline {
+		case "", "crlf":
+			return func(c *Content) string { return normalizeNewlines(c.Text, "\r\n") }, nil
+		case "lf":
+			return func(c *Content) string { return normalizeNewlines(c.Text, "\n") }, nil
+		}
+		return nil, fmt.Errorf("%s: unknown newline %q", a.Type, a.Newline)
+	case ActionTrimWhitespace:
+		return func(c *Content) string { return strings.TrimSpace(c.Text) }, nil
+	case ActionStraightenQuotes:
+		return func(c *Content) string { return quoteReplacer.Replace(c.Text) }, nil
+	case ActionHTMLToText:
+		return func(c *Content) string {
+			if c.HTML == "" {
+				return c.Text
+			}
+			return clipboard.HTMLToText(c.HTML)
+		}, nil
+	case ActionReplace:
+		re, err := regexp.Compile(a.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", a.Type, err)
+		}
+		return func(c *Content) string { return re.ReplaceAllString(c.Text, a.Replacement) }, nil
+	}
+	return nil, fmt.Errorf("unknown action %q", a.Type)
+}
+
+// defaultTrackingParams are stripped by ActionStripTrackingParams in addition to the configured ones
+var defaultTrackingParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"gbraid",
+	"wbraid",
+	"msclkid",
+	"mc_cid",
+	"mc_eid",
+	"igshid",
+	"yclid",
+	"_hsenc",
+	"_hsmi",
+	"mkt_tok",
+	"oly_anon_id",
+	"oly_enc_id",
+	"vero_id",
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+func stripTrackingParams(text string, params []string) string {
+	return urlPattern.ReplaceAllStringFunc(text, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil || u.RawQuery == "" {
+			return raw
+		}
+		// filter the raw query manually to keep the original parameter order and encoding
+		var kept []string
+		for _, kv := range strings.Split(u.RawQuery, "&") {
+			key := kv
+			if i := strings.IndexByte(kv, '='); i >= 0 {
+				key = kv[:i]
+			}
+			if k, err := url.QueryUnescape(key); err == nil {
+				key = k
+			}
+			if !isTrackingParam(key, params) {
+				kept = append(kept, kv)
+			}
+		}
+		u.RawQuery = strings.Join(kept, "&")
+		u.ForceQuery = false
+		return u.String()
+	})
+}
+
+func isTrackingParam(key string, params []string) bool {
+	key = strings.ToLower(key)
+	for _, p := range params {
+		p = strings.ToLower(p)
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(key, p[:len(p)-1]) {
+				return true
+			}
+		} else if key == p {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeNewlines(text, newline string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	if newline != "\n" {
+		text = strings.ReplaceAll(text, "\n", newline)
+	}
+	return text
+}
+
+var quoteReplacer = strings.NewReplacer(
+	"‘", "'", // left single quotation mark
+	"’", "'", // right single quotation mark
+	"‚", "'", // single low-9 quotation mark
+	"‛", "'", // single high-reversed-9 quotation mark
+	"′", "'", // prime
+	"“", `"`, // left double quotation mark
+	"”", `"`, // right double quotation mark
+	"„", `"`, // double low-9 quotation mark
+	"‟", `"`, // double high-reversed-9 quotation mark
+	"″", `"`, // double prime
+)
@@ -0,0 +1,298 @@
+// Package rules rewrites clipboard contents on every change,
+// based on a declarative list of match conditions and actions.
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	clipboard "github.com/kirides/go-winclipboard"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the JSON or YAML representation of a rule set
+//
+//	{
+//	  "rules": [
+//	    {
+//	      "name": "strip tracking",
+//	      "match": { "formats": ["CF_UNICODETEXT"], "content": "https?://" },
+//	      "actions": [ { "type": "strip_tracking_params" } ]
+//	    }
+//	  ]
+//	}
+//
+// or
+//
+//	rules:
+//	  - name: strip tracking
+//	    match: { formats: [CF_UNICODETEXT], content: "https?://" }
+//	    actions: [ { type: strip_tracking_params } ]
+type Config struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+type Rule struct {
+	Name    string   `json:"name" yaml:"name"`
+	Match   Match    `json:"match" yaml:"match"`
+	Actions []Action `json:"actions" yaml:"actions"`
+	// Stop prevents any further rule from running when this rule matched
+	Stop bool `json:"stop,omitempty" yaml:"stop,omitempty"`
+}
+
+// Match describes the conditions that all have to be met for a rule to run.
+// Empty conditions always match.
+type Match struct {
+	// Formats that all have to be available, by name (e.g. "CF_UNICODETEXT", "HTML Format")
+	Formats []string `json:"formats,omitempty" yaml:"formats,omitempty"`
+	// Absent lists formats of which none may be available
+	Absent []string `json:"absent,omitempty" yaml:"absent,omitempty"`
+	// Process is a regular expression matched against the executable path of the clipboard owner
+	Process string `json:"process,omitempty" yaml:"process,omitempty"`
+	// Content is a regular expression matched against the text,
+	// or against the HTML fragment when there is no text
+	Content string `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+// Action is a single rewrite step.
+//
+// Type is one of the Action* constants, the other fields are only used by some of them.
+type Action struct {
+	Type string `json:"type" yaml:"type"`
+	// Params adds query parameter names to strip for ActionStripTrackingParams.
+	// A trailing "*" matches any parameter starting with the prefix.
+	Params []string `json:"params,omitempty" yaml:"params,omitempty"`
+	// Newline is either "crlf" (default) or "lf" for ActionNormalizeNewlines
+	Newline string `json:"newline,omitempty" yaml:"newline,omitempty"`
+	// Pattern and Replacement are used by ActionReplace
+	Pattern     string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty" yaml:"replacement,omitempty"`
+}
+
+const (
+	ActionStripTrackingParams = "strip_tracking_params"
+	ActionNormalizeNewlines   = "normalize_newlines"
+	ActionTrimWhitespace      = "trim_whitespace"
+	ActionStraightenQuotes    = "straighten_quotes"
+	ActionHTMLToText          = "html_to_text"
+	ActionReplace             = "replace"
+)
+
+// Content is the clipboard state a rule set is evaluated against
+type Content struct {
+	// Formats contains the names of all available formats
+	Formats []string
+	// Process is the executable path of the clipboard owner, might be empty
+	Process string
+	// Text is the content of CF_UNICODETEXT
+	Text string
+	// HTML is the fragment of the "HTML Format" slot
+	HTML string
+}
+
+func (c *Content) hasFormat(name string) bool {
+	for _, v := range c.Formats {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+type transform func(c *Content) string
+
+type compiledRule struct {
+	name    string
+	formats []string
+	absent  []string
+	process *regexp.Regexp
+	content *regexp.Regexp
+	actions []transform
+	stop    bool
+}
+
+func (r *compiledRule) matches(c *Content) bool {
+	for _, f := range r.formats {
+		if !c.hasFormat(f) {
+			return false
+		}
+	}
+	for _, f := range r.absent {
+		if c.hasFormat(f) {
+			return false
+		}
+	}
+	if r.process != nil && !r.process.MatchString(c.Process) {
+		return false
+	}
+	if r.content != nil {
+		s := c.Text
+		if s == "" {
+			s = c.HTML
+		}
+		if !r.content.MatchString(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Engine applies a compiled rule set
+type Engine struct {
+	rules []compiledRule
+}
+
+// New compiles cfg into an Engine
+func New(cfg Config) (*Engine, error) {
+	e := &Engine{}
+	for i, r := range cfg.Rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		cr := compiledRule{
+			name:    name,
+			formats: r.Match.Formats,
+			absent:  r.Match.Absent,
+			stop:    r.Stop,
+		}
+		var err error
+		if r.Match.Process != "" {
+			if cr.process, err = regexp.Compile(r.Match.Process); err != nil {
+				return nil, fmt.Errorf("rule %s: process: %w", name, err)
+			}
+		}
+		if r.Match.Content != "" {
+			if cr.content, err = regexp.Compile(r.Match.Content); err != nil {
+				return nil, fmt.Errorf("rule %s: content: %w", name, err)
+			}
+		}
+		for _, a := range r.Actions {
+			t, err := compileAction(a)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", name, err)
+			}
+			cr.actions = append(cr.actions, t)
+		}
+		e.rules = append(e.rules, cr)
+	}
+	return e, nil
+}
+
+// Load reads a JSON Config from r and compiles it
+func Load(r io.Reader) (*Engine, error) {
+	var cfg Config
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	return New(cfg)
+}
+
+// LoadYAML reads a YAML Config from r and compiles it
+func LoadYAML(r io.Reader) (*Engine, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return New(cfg)
+}
+
+// LoadFile reads a Config from the file at path and compiles it,
+// files ending in .yaml or .yml are YAML, all others JSON
+func LoadFile(path string) (*Engine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return LoadYAML(f)
+	}
+	return Load(f)
+}
+
+// Apply runs every matching rule in order and returns the resulting text.
+//
+// Each rule sees the text as rewritten by the rules before it.
+// changed reports whether the text differs from c.Text.
+func (e *Engine) Apply(c Content) (text string, changed bool) {
+	original := c.Text
+	for i := range e.rules {
+		r := &e.rules[i]
+		if !r.matches(&c) {
+			continue
+		}
+		for _, t := range r.actions {
+			c.Text = t(&c)
+		}
+		if r.stop {
+			break
+		}
+	}
+	return c.Text, c.Text != original
+}
+
+// textFormats are derived from CF_UNICODETEXT by Windows, Rewrite drops them when the text changed
+var textFormats = []clipboard.Format{clipboard.CF_TEXT, clipboard.CF_OEMTEXT}
+
+// Rewrite applies the rules to the snapshot s of a clipboard owned by process.
+// It returns every format of s with CF_UNICODETEXT replaced by the rewritten text,
+// or changed false if no rule changed the text.
+func (e *Engine) Rewrite(s *clipboard.Snapshot, process string) (items []clipboard.Item, changed bool) {
+	c := Content{Process: process}
+	for _, f := range s.Formats {
+		c.Formats = append(c.Formats, f.Name)
+		switch {
+		case f.ID == clipboard.CF_UNICODETEXT:
+			c.Text = clipboard.DecodeUnicodeText(f.Data)
+		case f.Name == clipboard.HTMLFormatName:
+			if h, err := clipboard.ParseHTML(f.Data); err == nil {
+				c.HTML = h.Fragment
+			}
+		}
+	}
+	for _, f := range s.Omitted {
+		c.Formats = append(c.Formats, f.Name)
+	}
+
+	text, changed := e.Apply(c)
+	if !changed {
+		return nil, false
+	}
+	hasText := false
+	for _, f := range s.Formats {
+		switch {
+		case f.ID == clipboard.CF_UNICODETEXT:
+			hasText = true
+			items = append(items, clipboard.Item{Format: f.ID, Data: clipboard.EncodeUnicodeText(text)})
+		case isTextFormat(f.ID):
+			// stale, Windows synthesizes them from the new text
+		default:
+			items = append(items, clipboard.Item{Format: f.ID, Data: f.Data})
+		}
+	}
+	if !hasText {
+		// e.g. html_to_text on HTML without text
+		items = append(items, clipboard.Item{Format: clipboard.CF_UNICODETEXT, Data: clipboard.EncodeUnicodeText(text)})
+	}
+	return items, true
+}
+
+func isTextFormat(id clipboard.Format) bool {
+	for _, f := range textFormats {
+		if f == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+func TestMatch(t *testing.T) {
+	c := Content{
+		Formats: []string{"CF_UNICODETEXT", "HTML Format"},
+		Process: `C:\Program Files\Browser\browser.exe`,
+		Text:    "see https://example.com",
+	}
+	tests := []struct {
+		name  string
+		match Match
+		want  bool
+	}{
+		{"empty", Match{}, true},
+		{"formats", Match{Formats: []string{"CF_UNICODETEXT", "HTML Format"}}, true},
+		{"missing format", Match{Formats: []string{"CF_UNICODETEXT", "PNG"}}, false},
+		{"absent", Match{Absent: []string{"PNG"}}, true},
+		{"present although absent", Match{Absent: []string{"PNG", "HTML Format"}}, false},
+		{"process", Match{Process: `(?i)\\browser\.exe$`}, true},
+		{"other process", Match{Process: `editor\.exe$`}, false},
+		{"content", Match{Content: `https?://`}, true},
+		{"other content", Match{Content: `^ftp://`}, false},
+		{"all", Match{Formats: []string{"CF_UNICODETEXT"}, Process: "browser", Content: "example"}, true},
+		{"one fails", Match{Formats: []string{"CF_UNICODETEXT"}, Process: "browser", Content: "other"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(Config{Rules: []Rule{{Match: tt.match}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.rules[0].matches(&c); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// content falls back to the HTML fragment without text
+	e, err := New(Config{Rules: []Rule{{Match: Match{Content: "<b>"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.rules[0].matches(&Content{HTML: "<b>x</b>"}) {
+		t.Error("content does not match the HTML fragment")
+	}
+}
+
+func TestActions(t *testing.T) {
+	tests := []struct {
+		name   string
+		action Action
+		in     Content
+		want   string
+	}{
+		{"strip tracking", Action{Type: ActionStripTrackingParams},
+			Content{Text: "a https://x.com/p?id=1&utm_source=m&UTM_medium=e&fbclid=2#top b"}, "a https://x.com/p?id=1#top b"},
+		{"strip all params", Action{Type: ActionStripTrackingParams},
+			Content{Text: "https://x.com/?gclid=1"}, "https://x.com/"},
+		{"strip configured params", Action{Type: ActionStripTrackingParams, Params: []string{"ref", "src_*"}},
+			Content{Text: "https://x.com/?ref=a&src_x=b&keep=%20c"}, "https://x.com/?keep=%20c"},
+		{"strip without query", Action{Type: ActionStripTrackingParams},
+			Content{Text: "https://x.com/utm_source"}, "https://x.com/utm_source"},
+		{"crlf", Action{Type: ActionNormalizeNewlines}, Content{Text: "a\nb\r\nc\rd"}, "a\r\nb\r\nc\r\nd"},
+		{"lf", Action{Type: ActionNormalizeNewlines, Newline: "lf"}, Content{Text: "a\nb\r\nc\rd"}, "a\nb\nc\nd"},
+		{"trim", Action{Type: ActionTrimWhitespace}, Content{Text: " \t a b \r\n"}, "a b"},
+		{"quotes", Action{Type: ActionStraightenQuotes}, Content{Text: "“it’s„ ″"}, `"it's" "`},
+		{"html to text", Action{Type: ActionHTMLToText}, Content{Text: "old", HTML: "<p>a</p><p>b &amp; c</p>"}, "a\nb & c"},
+		{"html to text without HTML", Action{Type: ActionHTMLToText}, Content{Text: "old"}, "old"},
+		{"replace", Action{Type: ActionReplace, Pattern: `(\d+)`, Replacement: "<$1>"}, Content{Text: "a1b22"}, "a<1>b<22>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := compileAction(tt.action)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tr(&tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{"unknown action", Rule{Actions: []Action{{Type: "shout"}}}},
+		{"unknown newline", Rule{Actions: []Action{{Type: ActionNormalizeNewlines, Newline: "cr"}}}},
+		{"invalid pattern", Rule{Actions: []Action{{Type: ActionReplace, Pattern: "("}}}},
+		{"invalid process", Rule{Match: Match{Process: "["}}},
+		{"invalid content", Rule{Match: Match{Content: "["}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(Config{Rules: []Rule{tt.rule}}); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	e, err := New(Config{Rules: []Rule{
+		{Name: "trim", Actions: []Action{{Type: ActionTrimWhitespace}}},
+		// sees the text trimmed by the previous rule
+		{Name: "wrap", Match: Match{Content: "^x"}, Actions: []Action{
+			{Type: ActionReplace, Pattern: "^", Replacement: "["},
+			{Type: ActionReplace, Pattern: "$", Replacement: "]"},
+		}, Stop: true},
+		{Name: "after stop", Actions: []Action{{Type: ActionReplace, Pattern: "$", Replacement: "!"}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in      string
+		want    string
+		changed bool
+	}{
+		{" x ", "[x]", true},
+		{" y ", "y!", true},
+		{"[x]", "[x]!", true},
+		{"", "!", true},
+	}
+	for _, tt := range tests {
+		got, changed := e.Apply(Content{Text: tt.in})
+		if got != tt.want || changed != tt.changed {
+			t.Errorf("Apply(%q) = %q, %v, want %q, %v", tt.in, got, changed, tt.want, tt.changed)
+		}
+	}
+
+	e, err = New(Config{Rules: []Rule{{Actions: []Action{{Type: ActionTrimWhitespace}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, changed := e.Apply(Content{Text: "clean"}); got != "clean" || changed {
+		t.Errorf("Apply = %q, %v, want unchanged", got, changed)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	e, err := New(Config{Rules: []Rule{{Actions: []Action{{Type: ActionTrimWhitespace}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	png := []byte("\x89PNG")
+	html := clipboard.HTML{Fragment: "<b>x</b>"}.Bytes()
+	s := &clipboard.Snapshot{Formats: []clipboard.SnapshotItem{
+		{Name: "CF_UNICODETEXT", ID: clipboard.CF_UNICODETEXT, Data: clipboard.EncodeUnicodeText(" x ")},
+		{Name: "CF_TEXT", ID: clipboard.CF_TEXT, Data: []byte(" x \x00")},
+		{Name: clipboard.HTMLFormatName, ID: 0xC001, Data: html},
+		{Name: "PNG", ID: 0xC002, Data: png},
+		{Name: "CF_HDROP", ID: clipboard.CF_HDROP, Data: clipboard.EncodeDropFiles([]string{`C:\a`})},
+	}}
+	items, changed := e.Rewrite(s, "")
+	if !changed {
+		t.Fatal("not changed")
+	}
+	want := []clipboard.Format{clipboard.CF_UNICODETEXT, 0xC001, 0xC002, clipboard.CF_HDROP}
+	if len(items) != len(want) {
+		t.Fatalf("items %v, want formats %v", items, want)
+	}
+	for i, f := range want {
+		if items[i].Format != f {
+			t.Errorf("item %d is format %d, want %d", i, items[i].Format, f)
+		}
+	}
+	if got := clipboard.DecodeUnicodeText(items[0].Data); got != "x" {
+		t.Errorf("text %q", got)
+	}
+	if string(items[1].Data) != string(html) || string(items[2].Data) != string(png) {
+		t.Error("other formats were modified")
+	}
+
+	s.Formats[0].Data = clipboard.EncodeUnicodeText("x")
+	if items, changed := e.Rewrite(s, ""); changed || items != nil {
+		t.Errorf("unchanged text: %v, %v", items, changed)
+	}
+}
+
+func TestRewriteHTMLOnly(t *testing.T) {
+	e, err := New(Config{Rules: []Rule{{Match: Match{Absent: []string{"CF_UNICODETEXT"}}, Actions: []Action{{Type: ActionHTMLToText}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := clipboard.HTML{Fragment: "<p>a</p>"}.Bytes()
+	s := &clipboard.Snapshot{Formats: []clipboard.SnapshotItem{{Name: clipboard.HTMLFormatName, ID: 0xC001, Data: html}}}
+	items, changed := e.Rewrite(s, "")
+	if !changed || len(items) != 2 || items[1].Format != clipboard.CF_UNICODETEXT || clipboard.DecodeUnicodeText(items[1].Data) != "a" {
+		t.Errorf("Rewrite = %v, %v", items, changed)
+	}
+}
+
+const jsonConfig = `{
+  "rules": [
+    {
+      "name": "lf",
+      "match": { "formats": ["CF_UNICODETEXT"] },
+      "actions": [ { "type": "normalize_newlines", "newline": "lf" } ]
+    }
+  ]
+}`
+
+const yamlConfig = `rules:
+  - name: lf
+    match: { formats: [CF_UNICODETEXT] }
+    actions:
+      - type: normalize_newlines
+        newline: lf
+`
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"rules.json": jsonConfig, "rules.yaml": yamlConfig, "rules.yml": yamlConfig}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		e, err := LoadFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got, _ := e.Apply(Content{Formats: []string{"CF_UNICODETEXT"}, Text: "a\r\nb"}); got != "a\nb" {
+			t.Errorf("%s: Apply = %q", name, got)
+		}
+	}
+
+	if _, err := Load(strings.NewReader(`{"rules": [{"nmae": "typo"}]}`)); err == nil {
+		t.Error("JSON: unknown field accepted")
+	}
+	if _, err := LoadYAML(strings.NewReader("rules:\n  - nmae: typo\n")); err == nil {
+		t.Error("YAML: unknown field accepted")
+	}
+	if e, err := LoadYAML(strings.NewReader("")); err != nil || len(e.rules) != 0 {
+		t.Errorf("empty YAML: %v", err)
+	}
+}
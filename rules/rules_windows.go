@@ -0,0 +1,100 @@
+package rules
+
+import (
+	"fmt"
+	"syscall"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+// MarkerFormat is the private registered format the engine tags its own writes with,
+// so that the resulting clipboard update is not processed again.
+const MarkerFormat = "GoWinClipboard.RulesEngine"
+
+// synthesizedFormats are skipped by snapshots or derived by Windows from other formats,
+// their absence from a snapshot does not lose data
+var synthesizedFormats = map[clipboard.Format]bool{
+	clipboard.CF_TEXT:         true,
+	clipboard.CF_OEMTEXT:      true,
+	clipboard.CF_LOCALE:       true,
+	clipboard.CF_BITMAP:       true,
+	clipboard.CF_DIB:          true,
+	clipboard.CF_DIBV5:        true,
+	clipboard.CF_PALETTE:      true,
+	clipboard.CF_METAFILEPICT: true,
+	clipboard.CF_ENHMETAFILE:  true,
+}
+
+// HandleClipboardUpdate applies the rules to the current clipboard contents
+// and writes the result back, owned by hWnd. Every format is written back, only the text is replaced.
+//
+// The clipboard is left untouched if no rule changed the text or if a format can not be copied,
+// like formats exceeding clipboard.DefaultReadOptions or GDI handles Windows does not synthesize.
+// If writing fails, the previous contents are restored.
+//
+// It is meant to be called from a WndProc on WM_CLIPBOARDUPDATE.
+func (e *Engine) HandleClipboardUpdate(hWnd syscall.Handle) error {
+	marker, err := clipboard.RegisterFormat(MarkerFormat)
+	if err != nil {
+		return err
+	}
+	ids, err := clipboard.AvailableFormats()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id == marker {
+			return nil
+		}
+	}
+
+	s, err := clipboard.TakeSnapshot(clipboard.SystemBackend())
+	if err != nil {
+		return err
+	}
+	if len(s.Omitted) > 0 {
+		return fmt.Errorf("%s exceeds the read limits, the clipboard is left unchanged. %w", s.Omitted[0].Name, clipboard.ErrTooLarge)
+	}
+	read := make(map[clipboard.Format]bool, len(s.Formats))
+	for _, f := range s.Formats {
+		read[f.ID] = true
+	}
+	for _, id := range ids {
+		if !read[id] && !synthesizedFormats[id] {
+			// a handle or a format that failed to read, rewriting would lose it
+			return nil
+		}
+	}
+	process, _ := clipboard.OwnerProcess()
+
+	items, changed := e.Rewrite(s, process)
+	if !changed {
+		return nil
+	}
+	if err := writeItems(hWnd, append(items, clipboard.Item{Format: marker, Data: []byte{1}})); err != nil {
+		// Begin emptied the clipboard, put the copy of the user back
+		original := make([]clipboard.Item, 0, len(s.Formats)+1)
+		for _, f := range s.Formats {
+			original = append(original, clipboard.Item{Format: f.ID, Data: f.Data})
+		}
+		if rerr := writeItems(hWnd, append(original, clipboard.Item{Format: marker, Data: []byte{1}})); rerr != nil {
+			return fmt.Errorf("%v, restoring the previous contents failed: %w", err, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+func writeItems(hWnd syscall.Handle, items []clipboard.Item) error {
+	tx, err := clipboard.Begin(hWnd)
+	if err != nil {
+		return err
+	}
+	defer tx.Abort()
+	for _, v := range items {
+		if err := tx.Set(v.Format, v.Data); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,69 @@
+package clipboard
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidShellIDList = errors.New("invalid Shell IDList Array data")
+
+// ShellIDListFormatName is the format of CIDA data, placed by the Explorer for every selection
+const ShellIDListFormatName = "Shell IDList Array"
+
+// DecodeShellIDList parses CIDA data into absolute item ID lists (PIDLs),
+// each one the parent folder followed by the relative ID list of an item.
+func DecodeShellIDList(data []byte) ([][]byte, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("%d bytes are too short. %w", len(data), ErrInvalidShellIDList)
+	}
+	count := binary.LittleEndian.Uint32(data)
+	if uint64(count)+1 > uint64(len(data)-4)/4 {
+		return nil, fmt.Errorf("%d items do not fit into %d bytes. %w", count, len(data), ErrInvalidShellIDList)
+	}
+	offsets := make([]uint32, count+1)
+	for i := range offsets {
+		offsets[i] = binary.LittleEndian.Uint32(data[4+i*4:])
+	}
+
+	parent, err := itemIDList(data, offsets[0])
+	if err != nil {
+		return nil, fmt.Errorf("parent folder: %v. %w", err, ErrInvalidShellIDList)
+	}
+	// the parent's terminator is replaced by the item's ID list
+	parent = parent[:len(parent)-2]
+
+	result := make([][]byte, 0, count)
+	for i, offset := range offsets[1:] {
+		item, err := itemIDList(data, offset)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %v. %w", i, err, ErrInvalidShellIDList)
+		}
+		pidl := make([]byte, 0, len(parent)+len(item))
+		pidl = append(append(pidl, parent...), item...)
+		result = append(result, pidl)
+	}
+	return result, nil
+}
+
+// itemIDList returns the ID list at offset including its two byte terminator.
+// An ID list is a sequence of SHITEMIDs, each prefixed with its size, ending with a zero size.
+func itemIDList(data []byte, offset uint32) ([]byte, error) {
+	if uint64(offset)+2 > uint64(len(data)) {
+		return nil, fmt.Errorf("offset %d out of range", offset)
+	}
+	pos := int(offset)
+	for {
+		if pos+2 > len(data) {
+			return nil, errors.New("missing terminator")
+		}
+		cb := int(binary.LittleEndian.Uint16(data[pos:]))
+		if cb == 0 {
+			return data[offset : pos+2], nil
+		}
+		if cb < 2 || pos+cb > len(data) {
+			return nil, fmt.Errorf("item id of %d bytes at %d out of range", cb, pos)
+		}
+		pos += cb
+	}
+}
@@ -0,0 +1,96 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Snapshot is a copy of all formats of a clipboard at one point in time.
+// It serializes to JSON, data is base64 encoded.
+type Snapshot struct {
+	Time           time.Time      `json:"time"`
+	SequenceNumber uint32         `json:"sequenceNumber"`
+	Formats        []SnapshotItem `json:"formats"`
+	// Omitted lists the formats left out because they exceeded a limit of ReadOptions
+	Omitted []OmittedFormat `json:"omitted,omitempty"`
+}
+
+// OmittedFormat is a format of a snapshot whose data was not read
+type OmittedFormat struct {
+	Name string `json:"name"`
+	ID   Format `json:"id"`
+}
+
+// SnapshotItem is the data of a single format
+type SnapshotItem struct {
+	Name string `json:"name"`
+	ID   Format `json:"id"`
+	Data []byte `json:"data"`
+}
+
+// snapshotAttempts limits how often TakeSnapshot retries when the clipboard changes while it is read
+const snapshotAttempts = 3
+
+// TakeSnapshot reads every format of b.
+// Formats without readable data, like GDI handles, are skipped.
+//
+// If the sequence number changed while reading, the snapshot is taken again,
+// so that it never mixes two clipboard contents.
+// Formats exceeding DefaultReadOptions are listed in Snapshot.Omitted.
+func TakeSnapshot(b Backend) (*Snapshot, error) {
+	return TakeSnapshotWithOptions(b, DefaultReadOptions())
+}
+
+// TakeSnapshotWithOptions is TakeSnapshot, formats exceeding the limits of opts are listed in Snapshot.Omitted
+func TakeSnapshotWithOptions(b Backend, opts ReadOptions) (*Snapshot, error) {
+	var s *Snapshot
+	for i := 0; i < snapshotAttempts; i++ {
+		seq, err := b.SequenceNumber()
+		if err != nil {
+			return nil, err
+		}
+		if s, err = readSnapshot(b, opts); err != nil {
+			return nil, err
+		}
+		s.SequenceNumber = seq
+		if after, err := b.SequenceNumber(); err != nil || after == seq {
+			return s, err
+		}
+	}
+	return nil, fmt.Errorf("clipboard changed during %d attempts to take a snapshot", snapshotAttempts)
+}
+
+func readSnapshot(b Backend, opts ReadOptions) (*Snapshot, error) {
+	ids, err := b.Formats()
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{Time: time.Now(), Formats: make([]SnapshotItem, 0, len(ids))}
+	budget := readBudget{opts: opts}
+	for _, id := range ids {
+		name, err := b.FormatName(id)
+		if err != nil {
+			name = fmt.Sprintf("Format(%d)", uint32(id))
+		}
+		data, err := budget.read(b, id, name)
+		if errors.Is(err, ErrTooLarge) {
+			s.Omitted = append(s.Omitted, OmittedFormat{Name: name, ID: id})
+			continue
+		} else if err != nil {
+			continue
+		}
+		s.Formats = append(s.Formats, SnapshotItem{Name: name, ID: id, Data: data})
+	}
+	return s, nil
+}
+
+// Item returns the data of the format called name
+func (s *Snapshot) Item(name string) (SnapshotItem, bool) {
+	for _, v := range s.Formats {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return SnapshotItem{}, false
+}
@@ -0,0 +1,99 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/kirides/go-winclipboard/cfb"
+	"github.com/kirides/go-winclipboard/internal/winsys"
+
+	"golang.org/x/sys/windows"
+)
+
+// compoundFileFromStorage serializes stg and everything below it as compound file
+func compoundFileFromStorage(stg *winsys.IStorage) ([]byte, error) {
+	root, err := readStorage(stg)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := cfb.Write(&buf, root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readStorage copies stg, including all of its streams, into memory
+func readStorage(stg *winsys.IStorage) (*cfb.Storage, error) {
+	var stat winsys.STATSTG
+	if err := stg.Stat(&stat, winsys.STATFLAG_DEFAULT); err != nil {
+		return nil, err
+	}
+	result := &cfb.Storage{
+		Name:      stat.TakeName(),
+		CLSID:     guidBytes(stat.Clsid),
+		StateBits: stat.GrfStateBits,
+		Created:   filetimeToTime(stat.Ctime),
+		Modified:  filetimeToTime(stat.Mtime),
+	}
+
+	enum, err := stg.EnumElements()
+	if err != nil {
+		return nil, err
+	}
+	defer enum.Release()
+	for {
+		var e winsys.STATSTG
+		if err := enum.Next(&e); err != nil {
+			if err == io.EOF {
+				return result, nil
+			}
+			return nil, err
+		}
+		name := e.TakeName()
+		switch e.Type {
+		case winsys.STGTY_STORAGE:
+			child, err := stg.OpenStorage(name)
+			if err != nil {
+				return nil, err
+			}
+			sub, err := readStorage(child)
+			child.Release()
+			if err != nil {
+				return nil, err
+			}
+			sub.Name = name
+			result.Storages = append(result.Storages, sub)
+		case winsys.STGTY_STREAM:
+			stream, err := stg.OpenStream(name)
+			if err != nil {
+				return nil, err
+			}
+			data, err := io.ReadAll(&comStreamWrapper{iStream: stream})
+			stream.Release()
+			if err != nil {
+				return nil, err
+			}
+			result.Streams = append(result.Streams, &cfb.Stream{Name: name, Data: data})
+		}
+	}
+}
+
+// guidBytes returns g in its binary representation, as stored in compound files
+func guidBytes(g windows.GUID) [16]byte {
+	var b [16]byte
+	binary.LittleEndian.PutUint32(b[0:], g.Data1)
+	binary.LittleEndian.PutUint16(b[4:], g.Data2)
+	binary.LittleEndian.PutUint16(b[6:], g.Data3)
+	copy(b[8:], g.Data4[:])
+	return b
+}
+
+func filetimeToTime(ft windows.Filetime) time.Time {
+	if ft.HighDateTime == 0 && ft.LowDateTime == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ft.Nanoseconds())
+}
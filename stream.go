@@ -0,0 +1,108 @@
+package clipboard
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// contextReader fails with the error of ctx once it is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// NewContextReader returns a reader failing with ctx.Err() once ctx is done.
+//
+// Cancellation is checked before every Read, a Read that already started is not interrupted.
+func NewContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, r: r}
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// ProgressFunc receives the number of bytes read so far and the expected total, which is negative if unknown
+type ProgressFunc func(read, total int64)
+
+type progressReader struct {
+	r     io.Reader
+	read  int64
+	total int64
+	fn    ProgressFunc
+}
+
+// NewProgressReader returns a reader calling fn after every Read that returned data,
+// and once more when r reports io.EOF.
+//
+// total is typically FileInfo.Size of a FileContents item.
+func NewProgressReader(r io.Reader, total int64, fn ProgressFunc) io.Reader {
+	return &progressReader{r: r, total: total, fn: fn}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if n > 0 || err == io.EOF {
+		r.fn(r.read, r.total)
+	}
+	return n, err
+}
+
+// ThrottledReader limits the throughput of R to BytesPerSecond on average
+type ThrottledReader struct {
+	R              io.Reader
+	BytesPerSecond int64
+
+	// Now and Sleep default to time.Now and time.Sleep, tests might replace them
+	Now   func() time.Time
+	Sleep func(time.Duration)
+
+	start time.Time
+	read  int64
+}
+
+// NewThrottledReader returns a reader limiting r to bytesPerSecond, zero or less is unlimited
+func NewThrottledReader(r io.Reader, bytesPerSecond int64) *ThrottledReader {
+	return &ThrottledReader{R: r, BytesPerSecond: bytesPerSecond}
+}
+
+func (r *ThrottledReader) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+func (r *ThrottledReader) sleep(d time.Duration) {
+	if r.Sleep != nil {
+		r.Sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+func (r *ThrottledReader) Read(p []byte) (int, error) {
+	if r.BytesPerSecond <= 0 {
+		return r.R.Read(p)
+	}
+	if r.start.IsZero() {
+		r.start = r.now()
+	}
+	// small reads keep every pause short, so that cancellation is noticed in time
+	if chunk := r.BytesPerSecond / 10; chunk > 0 && int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err := r.R.Read(p)
+	r.read += int64(n)
+
+	due := r.start.Add(time.Duration(float64(r.read) / float64(r.BytesPerSecond) * float64(time.Second)))
+	if d := due.Sub(r.now()); d > 0 {
+		r.sleep(d)
+	}
+	return n, err
+}
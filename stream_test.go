@@ -0,0 +1,175 @@
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeStream returns its data in reads of at most chunk bytes, like an IStream
+type fakeStream struct {
+	data   []byte
+	chunk  int
+	reads  int
+	onRead func(reads int)
+}
+
+func (s *fakeStream) Read(p []byte) (int, error) {
+	if len(s.data) == 0 {
+		return 0, io.EOF
+	}
+	s.reads++
+	if len(p) > s.chunk {
+		p = p[:s.chunk]
+	}
+	n := copy(p, s.data)
+	s.data = s.data[n:]
+	if s.onRead != nil {
+		s.onRead(s.reads)
+	}
+	return n, nil
+}
+
+func (s *fakeStream) Close() error { return nil }
+
+func TestContextReaderCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &fakeStream{data: make([]byte, 100), chunk: 10}
+	r := NewContextReader(ctx, s)
+
+	buf := make([]byte, 50)
+	if n, err := r.Read(buf); n != 10 || err != nil {
+		t.Fatalf("Read = %d, %v", n, err)
+	}
+	cancel()
+	if n, err := r.Read(buf); n != 0 || !errors.Is(err, context.Canceled) {
+		t.Errorf("Read after cancel = %d, %v", n, err)
+	}
+	if s.reads != 1 {
+		t.Errorf("stream was read %d times after cancel", s.reads-1)
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	s := &fakeStream{data: make([]byte, 25), chunk: 10}
+	var calls [][2]int64
+	r := NewProgressReader(s, 25, func(read, total int64) { calls = append(calls, [2]int64{read, total}) })
+	if n, err := io.Copy(ioutil.Discard, r); n != 25 || err != nil {
+		t.Fatalf("Copy = %d, %v", n, err)
+	}
+	want := [][2]int64{{10, 25}, {20, 25}, {25, 25}, {25, 25}}
+	if len(calls) != len(want) {
+		t.Fatalf("got %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d: got %v, want %v", i, calls[i], want[i])
+		}
+	}
+}
+
+func TestThrottledReader(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	var slept time.Duration
+	r := NewThrottledReader(&fakeStream{data: make([]byte, 2000), chunk: 1000}, 1000)
+	r.Now = func() time.Time { return now }
+	r.Sleep = func(d time.Duration) {
+		slept += d
+		now = now.Add(d)
+	}
+
+	buf := make([]byte, 1000)
+	n, err := r.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reads are limited to a tenth of a second worth of data
+	if n != 100 {
+		t.Errorf("first Read returned %d bytes, want 100", n)
+	}
+	if slept != 100*time.Millisecond {
+		t.Errorf("slept %v after 100 bytes, want 100ms", slept)
+	}
+
+	// time spent elsewhere counts towards the budget
+	now = now.Add(400 * time.Millisecond)
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n+len(rest) != 2000 {
+		t.Errorf("read %d bytes, want 2000", n+len(rest))
+	}
+	if want := 1600 * time.Millisecond; slept != want {
+		t.Errorf("slept %v in total, want %v", slept, want)
+	}
+}
+
+func TestThrottledReaderUnlimited(t *testing.T) {
+	r := NewThrottledReader(&fakeStream{data: make([]byte, 500), chunk: 500}, 0)
+	r.Sleep = func(time.Duration) { t.Error("unlimited reader slept") }
+	if n, _ := r.Read(make([]byte, 500)); n != 500 {
+		t.Errorf("Read returned %d bytes, want 500", n)
+	}
+}
+
+func TestExtractFilesProgress(t *testing.T) {
+	dir := t.TempDir()
+	files := []FileInfo{
+		{Name: "a.txt", Size: 30},
+		{Name: "unknown.bin"},
+	}
+	data := [][]byte{bytes.Repeat([]byte("a"), 30), []byte("xyz")}
+	last := map[int][2]int64{}
+	opts := ExtractOptions{FileProgress: func(index int, read, total int64) { last[index] = [2]int64{read, total} }}
+	open := func(i int) (io.ReadCloser, error) { return &fakeStream{data: data[i], chunk: 8}, nil }
+
+	if _, err := ExtractFiles(dir, files, open, opts); err != nil {
+		t.Fatal(err)
+	}
+	if last[0] != [2]int64{30, 30} {
+		t.Errorf("a.txt: last progress %v, want [30 30]", last[0])
+	}
+	if last[1] != [2]int64{3, -1} {
+		t.Errorf("unknown.bin: last progress %v, want [3 -1]", last[1])
+	}
+}
+
+func TestExtractFilesCancel(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	files := []FileInfo{{Name: "a.txt", Size: 100}, {Name: "b.txt", Size: 100}}
+	opened := 0
+	open := func(i int) (io.ReadCloser, error) {
+		opened++
+		// cancelled while the first file is copied
+		return &fakeStream{data: make([]byte, 100), chunk: 10, onRead: func(reads int) {
+			if reads == 2 {
+				cancel()
+			}
+		}}, nil
+	}
+
+	results, err := ExtractFiles(dir, files, open, ExtractOptions{Context: ctx})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	for i, r := range results {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("entry %d: got %v, want context.Canceled", i, r.Err)
+		}
+	}
+	if opened != 1 {
+		t.Errorf("opened %d streams, want 1", opened)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("partial file was left behind: %v", err)
+	}
+}
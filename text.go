@@ -0,0 +1,29 @@
+package clipboard
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+// EncodeUnicodeText converts text into NUL terminated UTF-16LE, the CF_UNICODETEXT representation
+func EncodeUnicodeText(text string) []byte {
+	u := utf16.Encode([]rune(text))
+	buf := make([]byte, len(u)*2+2)
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(buf[i*2:], c)
+	}
+	return buf
+}
+
+// DecodeUnicodeText decodes NUL terminated UTF-16LE data, the CF_UNICODETEXT representation
+func DecodeUnicodeText(data []byte) string {
+	u := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		c := binary.LittleEndian.Uint16(data[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,245 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+// ArchiveExt is the file extension of archived snapshots
+const ArchiveExt = ".sealed"
+
+// kdfFile stores the KDF of an archive protected by a passphrase
+const kdfFile = "kdf.json"
+
+// SnapshotMeta is the authenticated but unencrypted metadata of an archived snapshot
+type SnapshotMeta struct {
+	Time           time.Time `json:"time"`
+	SequenceNumber uint32    `json:"sequenceNumber"`
+	Formats        []string  `json:"formats"`
+	Size           int       `json:"size"`
+}
+
+// SealSnapshot encrypts s, its time, sequence number and format names remain readable as SnapshotMeta
+func SealSnapshot(kr *Keyring, s *clipboard.Snapshot) ([]byte, error) {
+	meta := SnapshotMeta{Time: s.Time, SequenceNumber: s.SequenceNumber, Formats: make([]string, 0, len(s.Formats))}
+	for _, f := range s.Formats {
+		meta.Formats = append(meta.Formats, f.Name)
+		meta.Size += len(f.Data)
+	}
+	metadata, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return Seal(kr, metadata, plaintext)
+}
+
+// OpenSnapshot decrypts a snapshot sealed by SealSnapshot
+func OpenSnapshot(kr *Keyring, sealed []byte) (*clipboard.Snapshot, error) {
+	_, s, err := openSnapshot(kr, sealed)
+	return s, err
+}
+
+// openSnapshot decrypts a snapshot and returns its authenticated metadata
+func openSnapshot(kr *Keyring, sealed []byte) (*SnapshotMeta, *clipboard.Snapshot, error) {
+	metadata, plaintext, err := Open(kr, sealed)
+	if err != nil {
+		return nil, nil, err
+	}
+	var meta SnapshotMeta
+	if err := json.Unmarshal(metadata, &meta); err != nil {
+		return nil, nil, fmt.Errorf("%v. %w", err, ErrInvalidFormat)
+	}
+	var s clipboard.Snapshot
+	if err := json.Unmarshal(plaintext, &s); err != nil {
+		return nil, nil, fmt.Errorf("%v. %w", err, ErrInvalidFormat)
+	}
+	return &meta, &s, nil
+}
+
+// Archive stores sealed snapshots as files in a directory
+type Archive struct {
+	Dir  string
+	Keys *Keyring
+}
+
+// ArchiveEntry describes an archived snapshot
+type ArchiveEntry struct {
+	Name  string
+	KeyID KeyID
+	// Meta is not authenticated until the snapshot is read with Get
+	Meta SnapshotMeta
+}
+
+// PassphraseKey derives the key of passphrase with the KDF stored in the archive directory,
+// creating the directory and the KDF on first use. The key still has to be added to Keys.
+func (a *Archive) PassphraseKey(passphrase []byte) ([]byte, error) {
+	path := filepath.Join(a.Dir, kdfFile)
+	var kdf KDF
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &kdf); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		if kdf, err = NewKDF(); err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(kdf); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(a.Dir, 0700); err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(path, data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	return kdf.Key(passphrase)
+}
+
+// Put seals s and stores it, returning the name of the entry
+func (a *Archive) Put(s *clipboard.Snapshot) (string, error) {
+	sealed, err := SealSnapshot(a.Keys, s)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(a.Dir, 0700); err != nil {
+		return "", err
+	}
+	name := entryName(s.Time, s.SequenceNumber)
+	return name, writeFileAtomic(a.path(name), sealed)
+}
+
+// entryName is the name Put stores a snapshot under
+func entryName(t time.Time, seq uint32) string {
+	return fmt.Sprintf("%s-%d", t.UTC().Format("20060102T150405.000000000Z"), seq)
+}
+
+// Get reads and decrypts the entry called name.
+// It fails with ErrMismatch if the entry was sealed under another name, e.g. because files were swapped.
+func (a *Archive) Get(name string) (*clipboard.Snapshot, error) {
+	sealed, err := os.ReadFile(a.path(name))
+	if err != nil {
+		return nil, err
+	}
+	meta, s, err := openSnapshot(a.Keys, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if sealedName := entryName(meta.Time, meta.SequenceNumber); sealedName != name {
+		return nil, fmt.Errorf("%s: sealed as %s. %w", name, sealedName, ErrMismatch)
+	}
+	return s, nil
+}
+
+// Delete removes the entry called name
+func (a *Archive) Delete(name string) error {
+	return os.Remove(a.path(name))
+}
+
+// List returns all entries, oldest first
+func (a *Archive) List() ([]ArchiveEntry, error) {
+	infos, err := os.ReadDir(a.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var entries []ArchiveEntry
+	for _, fi := range infos {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ArchiveExt) {
+			continue
+		}
+		name := strings.TrimSuffix(fi.Name(), ArchiveExt)
+		sealed, err := os.ReadFile(a.path(name))
+		if err != nil {
+			return nil, err
+		}
+		metadata, id, err := Metadata(sealed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		e := ArchiveEntry{Name: name, KeyID: id}
+		if err := json.Unmarshal(metadata, &e.Meta); err != nil {
+			return nil, fmt.Errorf("%s: %v. %w", name, err, ErrInvalidFormat)
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// Rotate seals every entry not sealed with the current key again, after a new key was added to Keys.
+// It returns the number of rewritten entries, the old key can be dropped once it succeeded.
+func (a *Archive) Rotate() (int, error) {
+	entries, err := a.List()
+	if err != nil {
+		return 0, err
+	}
+	current, ok := a.Keys.Current()
+	if !ok {
+		return 0, ErrNoKey
+	}
+	n := 0
+	for _, e := range entries {
+		if e.KeyID == current {
+			continue
+		}
+		sealed, err := os.ReadFile(a.path(e.Name))
+		if err != nil {
+			return n, err
+		}
+		resealed, changed, err := Reseal(a.Keys, sealed)
+		if err != nil {
+			return n, fmt.Errorf("%s: %w", e.Name, err)
+		}
+		if !changed {
+			continue
+		}
+		if err := writeFileAtomic(a.path(e.Name), resealed); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (a *Archive) path(name string) string {
+	return filepath.Join(a.Dir, name+ArchiveExt)
+}
+
+// writeFileAtomic replaces path, so that an interrupted write never leaves a truncated entry
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,272 @@
+// Package vault encrypts clipboard data at rest.
+//
+// Entries are sealed with AES-256-GCM under a fresh random nonce. Every entry carries readable metadata
+// that is authenticated together with the ciphertext, and the id of the key it was sealed with,
+// so that a Keyring can hold old keys for reading while new entries use the current one.
+// Keys are either provided externally or derived from a passphrase with Argon2id, see KDF.
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var (
+	// ErrInvalidFormat is returned for data that is not a sealed entry
+	ErrInvalidFormat = errors.New("invalid sealed entry")
+	// ErrUnknownKey is returned when an entry was sealed with a key missing from the keyring
+	ErrUnknownKey = errors.New("unknown key")
+	// ErrDecrypt is returned when an entry was modified or the key is wrong
+	ErrDecrypt = errors.New("message authentication failed")
+	// ErrNoKey is returned when sealing with an empty keyring
+	ErrNoKey = errors.New("no key in keyring")
+	// ErrWeakKDF is returned for key derivation parameters below the minimums
+	ErrWeakKDF = errors.New("key derivation parameters are too weak")
+	// ErrMismatch is returned when an archived snapshot does not match the name of its entry
+	ErrMismatch = errors.New("entry does not match its name")
+)
+
+// KeySize is the size of AES-256 keys
+const KeySize = 32
+
+const (
+	magic      = "GWCV"
+	version    = 1
+	keyIDSize  = 8
+	nonceSize  = 12
+	headerSize = len(magic) + 1 + keyIDSize + nonceSize + 4
+)
+
+// KeyID identifies a key without revealing it, it is a prefix of the SHA-256 of the key
+type KeyID [keyIDSize]byte
+
+func (id KeyID) String() string {
+	return fmt.Sprintf("%x", id[:])
+}
+
+func keyID(key []byte) KeyID {
+	sum := sha256.Sum256(key)
+	var id KeyID
+	copy(id[:], sum[:])
+	return id
+}
+
+// Keyring holds the keys entries can be opened with, safe for concurrent use.
+// New entries are sealed with the current key, the one added last unless changed with SetCurrent.
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    map[KeyID]cipher.AEAD
+	current KeyID
+}
+
+// NewKeyring returns a keyring holding keys, the last one becoming the current key
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	kr := &Keyring{keys: map[KeyID]cipher.AEAD{}}
+	for _, k := range keys {
+		if _, err := kr.Add(k); err != nil {
+			return nil, err
+		}
+	}
+	return kr, nil
+}
+
+// Add adds key and makes it the current one
+func (kr *Keyring) Add(key []byte) (KeyID, error) {
+	if len(key) != KeySize {
+		return KeyID{}, fmt.Errorf("key has %d bytes, expected %d", len(key), KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return KeyID{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return KeyID{}, err
+	}
+	id := keyID(key)
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys[id] = aead
+	kr.current = id
+	return id, nil
+}
+
+// SetCurrent selects the key new entries are sealed with
+func (kr *Keyring) SetCurrent(id KeyID) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if _, ok := kr.keys[id]; !ok {
+		return fmt.Errorf("key %s. %w", id, ErrUnknownKey)
+	}
+	kr.current = id
+	return nil
+}
+
+// Current returns the id of the key new entries are sealed with
+func (kr *Keyring) Current() (KeyID, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	_, ok := kr.keys[kr.current]
+	return kr.current, ok
+}
+
+func (kr *Keyring) aead(id KeyID) (cipher.AEAD, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	aead, ok := kr.keys[id]
+	return aead, ok
+}
+
+// NewKey returns a random key
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// KDF describes how a key is derived from a passphrase with Argon2id.
+// It is not secret and has to be stored next to the entries, e.g. as JSON.
+type KDF struct {
+	Salt []byte `json:"salt"`
+	// Time is the number of passes, Memory the memory in KiB
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// Minimums enforced by KDF.Key, a stored KDF can not be downgraded below them.
+// MinKDFTime and MinKDFMemory are the OWASP recommendation for Argon2id.
+const (
+	MinKDFSaltSize = 16
+	MinKDFTime     = 2
+	// MinKDFMemory is in KiB
+	MinKDFMemory = 19 << 10
+)
+
+// NewKDF returns a KDF with a random salt and the parameters recommended by RFC 9106 for memory-constrained environments
+func NewKDF() (KDF, error) {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return KDF{}, err
+	}
+	return KDF{Salt: salt, Time: 3, Memory: 64 << 10, Threads: 4}, nil
+}
+
+// Key derives the key of passphrase, it fails with ErrWeakKDF if k is below the minimums
+func (k KDF) Key(passphrase []byte) ([]byte, error) {
+	switch {
+	case len(k.Salt) < MinKDFSaltSize:
+		return nil, fmt.Errorf("salt has %d bytes, expected at least %d. %w", len(k.Salt), MinKDFSaltSize, ErrWeakKDF)
+	case k.Time < MinKDFTime:
+		return nil, fmt.Errorf("time %d, expected at least %d. %w", k.Time, MinKDFTime, ErrWeakKDF)
+	case k.Memory < MinKDFMemory:
+		return nil, fmt.Errorf("memory %d KiB, expected at least %d KiB. %w", k.Memory, MinKDFMemory, ErrWeakKDF)
+	case k.Threads == 0:
+		return nil, fmt.Errorf("no threads. %w", ErrWeakKDF)
+	}
+	return argon2.IDKey(passphrase, k.Salt, k.Time, k.Memory, k.Threads, KeySize), nil
+}
+
+// Seal encrypts plaintext with the current key of kr.
+// metadata is stored unencrypted but authenticated, Open fails if either was modified.
+func Seal(kr *Keyring, metadata, plaintext []byte) ([]byte, error) {
+	id, ok := kr.Current()
+	if !ok {
+		return nil, ErrNoKey
+	}
+	aead, _ := kr.aead(id)
+
+	out := make([]byte, headerSize, headerSize+len(metadata)+len(plaintext)+aead.Overhead())
+	copy(out, magic)
+	out[len(magic)] = version
+	copy(out[len(magic)+1:], id[:])
+	nonce := out[len(magic)+1+keyIDSize : len(magic)+1+keyIDSize+nonceSize]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint32(out[headerSize-4:], uint32(len(metadata)))
+	out = append(out, metadata...)
+	return aead.Seal(out, nonce, plaintext, out), nil
+}
+
+// header is the unencrypted part of a sealed entry
+type header struct {
+	keyID    KeyID
+	nonce    []byte
+	metadata []byte
+	// aad is everything before the ciphertext
+	aad []byte
+}
+
+func parseHeader(sealed []byte) (*header, error) {
+	if len(sealed) < headerSize || !bytes.HasPrefix(sealed, []byte(magic)) {
+		return nil, ErrInvalidFormat
+	}
+	if v := sealed[len(magic)]; v != version {
+		return nil, fmt.Errorf("unsupported version %d. %w", v, ErrInvalidFormat)
+	}
+	var h header
+	copy(h.keyID[:], sealed[len(magic)+1:])
+	h.nonce = sealed[len(magic)+1+keyIDSize : len(magic)+1+keyIDSize+nonceSize]
+	n := binary.LittleEndian.Uint32(sealed[headerSize-4:])
+	if uint64(n) > uint64(len(sealed)-headerSize) {
+		return nil, fmt.Errorf("metadata exceeds entry. %w", ErrInvalidFormat)
+	}
+	h.aad = sealed[:headerSize+int(n)]
+	h.metadata = h.aad[headerSize:]
+	return &h, nil
+}
+
+// Metadata returns the metadata and key id of a sealed entry without opening it.
+// The metadata is not authenticated until the entry is opened.
+func Metadata(sealed []byte) ([]byte, KeyID, error) {
+	h, err := parseHeader(sealed)
+	if err != nil {
+		return nil, KeyID{}, err
+	}
+	return h.metadata, h.keyID, nil
+}
+
+// Open authenticates and decrypts an entry returned by Seal
+func Open(kr *Keyring, sealed []byte) (metadata, plaintext []byte, err error) {
+	h, err := parseHeader(sealed)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, ok := kr.aead(h.keyID)
+	if !ok {
+		return nil, nil, fmt.Errorf("key %s. %w", h.keyID, ErrUnknownKey)
+	}
+	plaintext, err = aead.Open(nil, h.nonce, sealed[len(h.aad):], h.aad)
+	if err != nil {
+		return nil, nil, ErrDecrypt
+	}
+	return h.metadata, plaintext, nil
+}
+
+// Reseal opens sealed and seals it again with the current key, for key rotation.
+// Entries already sealed with the current key are returned unchanged.
+func Reseal(kr *Keyring, sealed []byte) ([]byte, bool, error) {
+	metadata, plaintext, err := Open(kr, sealed)
+	if err != nil {
+		return nil, false, err
+	}
+	current, _ := kr.Current()
+	if _, id, _ := Metadata(sealed); id == current {
+		return sealed, false, nil
+	}
+	resealed, err := Seal(kr, metadata, plaintext)
+	return resealed, err == nil, err
+}
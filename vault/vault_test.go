@@ -0,0 +1,320 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	clipboard "github.com/kirides/go-winclipboard"
+)
+
+func newKeyring(t *testing.T) (*Keyring, []byte) {
+	t.Helper()
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kr, err := NewKeyring(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kr, key
+}
+
+func TestSealOpen(t *testing.T) {
+	kr, _ := newKeyring(t)
+	metadata, plaintext := []byte(`{"formats":["CF_TEXT"]}`), []byte("secret")
+	sealed, err := Seal(kr, metadata, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Error("sealed entry contains the plaintext")
+	}
+	gotMeta, gotPlain, err := Open(kr, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotMeta, metadata) || !bytes.Equal(gotPlain, plaintext) {
+		t.Errorf("Open = %q, %q", gotMeta, gotPlain)
+	}
+	meta, id, err := Metadata(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := kr.Current(); id != current || !bytes.Equal(meta, metadata) {
+		t.Errorf("Metadata = %q, %s", meta, id)
+	}
+
+	again, err := Seal(kr, metadata, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(again, sealed) {
+		t.Error("sealing twice gave the same entry, the nonce is not random")
+	}
+}
+
+func TestTamper(t *testing.T) {
+	kr, _ := newKeyring(t)
+	sealed, err := Seal(kr, []byte("metadata"), []byte("plaintext"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		off  int
+		want error
+	}{
+		{"nonce", len(magic) + 1 + keyIDSize, ErrDecrypt},
+		{"metadata", headerSize, ErrDecrypt},
+		{"ciphertext", headerSize + len("metadata"), ErrDecrypt},
+		{"tag", len(sealed) - 1, ErrDecrypt},
+		{"key id", len(magic) + 1, ErrUnknownKey},
+		{"version", len(magic), ErrInvalidFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := append([]byte(nil), sealed...)
+			b[tt.off] ^= 1
+			if _, _, err := Open(kr, b); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	for _, n := range []int{0, headerSize - 1, headerSize + 3, len(sealed) - 1} {
+		if _, _, err := Open(kr, sealed[:n]); err == nil {
+			t.Errorf("truncated to %d bytes: no error", n)
+		}
+	}
+	// a metadata length beyond the entry
+	b := append([]byte(nil), sealed...)
+	b[headerSize-1] = 0xFF
+	if _, _, err := Open(kr, b); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("metadata length: got %v, want ErrInvalidFormat", err)
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	kr, _ := newKeyring(t)
+	sealed, err := Seal(kr, nil, []byte("plaintext"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := newKeyring(t)
+	if _, _, err := Open(other, sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("other keyring: got %v, want ErrUnknownKey", err)
+	}
+
+	// a different key filed under the id of the right one
+	wrongID, _ := other.Current()
+	other.keys[mustKeyID(t, sealed)] = other.keys[wrongID]
+	if _, _, err := Open(other, sealed); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("wrong key: got %v, want ErrDecrypt", err)
+	}
+
+	if _, err := Seal(&Keyring{}, nil, nil); !errors.Is(err, ErrNoKey) {
+		t.Errorf("empty keyring: got %v, want ErrNoKey", err)
+	}
+	if _, err := kr.Add(make([]byte, 16)); err == nil {
+		t.Error("added a 16 byte key")
+	}
+}
+
+func mustKeyID(t *testing.T, sealed []byte) KeyID {
+	t.Helper()
+	_, id, err := Metadata(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestKDF(t *testing.T) {
+	kdf, err := NewKDF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(kdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored KDF
+	if err := json.Unmarshal(b, &stored); err != nil {
+		t.Fatal(err)
+	}
+	k1, err := kdf.Key([]byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := stored.Key([]byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(k1) != KeySize || !bytes.Equal(k1, k2) {
+		t.Errorf("keys differ after storing the KDF")
+	}
+	k3, err := stored.Key([]byte("Passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(k1, k3) {
+		t.Error("different passphrases give the same key")
+	}
+
+	weak := []KDF{
+		{Salt: kdf.Salt[:8], Time: kdf.Time, Memory: kdf.Memory, Threads: kdf.Threads},
+		{Salt: kdf.Salt, Time: 1, Memory: kdf.Memory, Threads: kdf.Threads},
+		{Salt: kdf.Salt, Time: kdf.Time, Memory: 1024, Threads: kdf.Threads},
+		{Salt: kdf.Salt, Time: kdf.Time, Memory: kdf.Memory},
+	}
+	for _, k := range weak {
+		if _, err := k.Key([]byte("passphrase")); !errors.Is(err, ErrWeakKDF) {
+			t.Errorf("%+v: got %v, want ErrWeakKDF", k, err)
+		}
+	}
+}
+
+func testSnapshot(seq uint32) *clipboard.Snapshot {
+	return &clipboard.Snapshot{
+		Time:           time.Date(2021, 1, 1, 12, 0, 0, int(seq), time.UTC),
+		SequenceNumber: seq,
+		Formats:        []clipboard.SnapshotItem{{Name: "CF_TEXT", ID: 1, Data: []byte("hello\x00")}},
+	}
+}
+
+func TestArchive(t *testing.T) {
+	kr, _ := newKeyring(t)
+	a := &Archive{Dir: filepath.Join(t.TempDir(), "archive"), Keys: kr}
+	name, err := a.Put(testSnapshot(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := a.Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.SequenceNumber != 1 || len(s.Formats) != 1 || string(s.Formats[0].Data) != "hello\x00" {
+		t.Errorf("Get = %+v", s)
+	}
+	entries, err := a.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != name || entries[0].Meta.Size != 6 {
+		t.Errorf("List = %+v", entries)
+	}
+}
+
+func TestArchiveSwappedEntries(t *testing.T) {
+	kr, _ := newKeyring(t)
+	a := &Archive{Dir: t.TempDir(), Keys: kr}
+	first, err := a.Put(testSnapshot(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := a.Put(testSnapshot(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(a.path(second), a.path(first)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Get(first); !errors.Is(err, ErrMismatch) {
+		t.Errorf("got %v, want ErrMismatch", err)
+	}
+}
+
+func TestArchiveRotate(t *testing.T) {
+	kr, _ := newKeyring(t)
+	a := &Archive{Dir: t.TempDir(), Keys: kr}
+	var names []string
+	for seq := uint32(1); seq <= 3; seq++ {
+		name, err := a.Put(testSnapshot(seq))
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newID, err := kr.Add(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// entries sealed after adding the key already use it
+	name, err := a.Put(testSnapshot(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = append(names, name)
+
+	n, err := a.Rotate()
+	if err != nil || n != 3 {
+		t.Fatalf("Rotate = %d, %v, want 3", n, err)
+	}
+	if n, err := a.Rotate(); err != nil || n != 0 {
+		t.Errorf("second Rotate = %d, %v, want 0", n, err)
+	}
+
+	// the old key is no longer needed
+	a.Keys, err = NewKeyring(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := a.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.KeyID != newID {
+			t.Errorf("%s: key %s, want %s", e.Name, e.KeyID, newID)
+		}
+	}
+	for i, name := range names {
+		s, err := a.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.SequenceNumber != uint32(i+1) {
+			t.Errorf("%s: sequence number %d", name, s.SequenceNumber)
+		}
+	}
+}
+
+func TestArchivePassphrase(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "archive")
+	a := &Archive{Dir: dir}
+	k1, err := a.PassphraseKey([]byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := (&Archive{Dir: dir}).PassphraseKey([]byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(k1, k2) {
+		t.Error("the stored KDF derives another key")
+	}
+
+	// a kdf.json downgraded by someone with write access to the directory
+	weak := KDF{Salt: make([]byte, 16), Time: 1, Memory: 8, Threads: 1}
+	b, err := json.Marshal(weak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, kdfFile), b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.PassphraseKey([]byte("passphrase")); !errors.Is(err, ErrWeakKDF) {
+		t.Errorf("got %v, want ErrWeakKDF", err)
+	}
+}
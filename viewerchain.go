@@ -0,0 +1,92 @@
+package clipboard
+
+import (
+	"sync"
+
+	"github.com/kirides/go-winclipboard/wm"
+)
+
+// SendFunc delivers a message to another window and returns its result, typically SendMessage
+type SendFunc func(h uintptr, msg uint32, wParam, lParam uintptr) uintptr
+
+// ViewerChain is the state of a window in the legacy clipboard viewer chain (SetClipboardViewer).
+//
+// Every viewer has to pass WM_DRAWCLIPBOARD and WM_CHANGECBCHAIN on to the next viewer,
+// a viewer failing to do so breaks notifications for all viewers registered before it.
+type ViewerChain struct {
+	mu       sync.Mutex
+	self     uintptr
+	next     uintptr
+	joined   bool
+	send     SendFunc
+	onChange func()
+}
+
+// NewViewerChain returns the chain state of the window self.
+// onChange is called for every WM_DRAWCLIPBOARD, before the message is forwarded.
+func NewViewerChain(self uintptr, send SendFunc, onChange func()) *ViewerChain {
+	return &ViewerChain{self: self, send: send, onChange: onChange}
+}
+
+// Join records next, the window returned by SetClipboardViewer.
+// Messages handled before, like the WM_DRAWCLIPBOARD sent by SetClipboardViewer itself, are not forwarded.
+func (c *ViewerChain) Join(next uintptr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.next = next
+	c.joined = true
+}
+
+// Leave returns the arguments for ChangeClipboardChain(self, next), ok is false if the chain was left already
+func (c *ViewerChain) Leave() (self, next uintptr, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.joined {
+		return 0, 0, false
+	}
+	c.joined = false
+	return c.self, c.next, true
+}
+
+// Next returns the following viewer, zero if self is the last one
+func (c *ViewerChain) Next() uintptr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.next
+}
+
+// HandleMessage processes WM_DRAWCLIPBOARD and WM_CHANGECBCHAIN, reporting whether msg was one of them.
+// The WndProc returns zero for handled messages.
+//
+// Messages are forwarded without holding a lock, as the next viewer might change the chain while handling them.
+func (c *ViewerChain) HandleMessage(msg uint32, wParam, lParam uintptr) bool {
+	switch msg {
+	case wm.DRAWCLIPBOARD:
+		c.mu.Lock()
+		next, onChange := c.next, c.onChange
+		c.mu.Unlock()
+		if onChange != nil {
+			onChange()
+		}
+		if next != 0 {
+			c.send(next, msg, wParam, lParam)
+		}
+		return true
+	case wm.CHANGECBCHAIN:
+		removed := wParam
+		c.mu.Lock()
+		next := c.next
+		if removed == next {
+			// repair the chain, the window after the removed one follows us now
+			c.next = lParam
+			c.mu.Unlock()
+			return true
+		}
+		c.mu.Unlock()
+		if next != 0 {
+			c.send(next, msg, wParam, lParam)
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package clipboard
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kirides/go-winclipboard/wm"
+)
+
+type sentMessage struct {
+	h              uintptr
+	msg            uint32
+	wParam, lParam uintptr
+}
+
+// fakeChain returns a ViewerChain of window 1 recording the messages it sends and its log of events
+func fakeChain() (*ViewerChain, *[]sentMessage, *[]string) {
+	var sent []sentMessage
+	var events []string
+	send := func(h uintptr, msg uint32, wParam, lParam uintptr) uintptr {
+		sent = append(sent, sentMessage{h, msg, wParam, lParam})
+		events = append(events, "send")
+		return 0
+	}
+	c := NewViewerChain(1, send, func() { events = append(events, "change") })
+	return c, &sent, &events
+}
+
+func TestViewerChainForwarding(t *testing.T) {
+	c, sent, events := fakeChain()
+	c.Join(2)
+
+	if !c.HandleMessage(wm.DRAWCLIPBOARD, 7, 8) {
+		t.Fatal("WM_DRAWCLIPBOARD was not handled")
+	}
+	if want := []sentMessage{{2, wm.DRAWCLIPBOARD, 7, 8}}; !reflect.DeepEqual(*sent, want) {
+		t.Errorf("sent %v, want %v", *sent, want)
+	}
+	if want := []string{"change", "send"}; !reflect.DeepEqual(*events, want) {
+		t.Errorf("events %v, want %v", *events, want)
+	}
+	if c.HandleMessage(wm.CLIPBOARDUPDATE, 0, 0) {
+		t.Error("WM_CLIPBOARDUPDATE was handled")
+	}
+}
+
+func TestViewerChainLastViewer(t *testing.T) {
+	c, sent, events := fakeChain()
+	c.Join(0)
+	c.HandleMessage(wm.DRAWCLIPBOARD, 0, 0)
+	c.HandleMessage(wm.CHANGECBCHAIN, 5, 6)
+	if len(*sent) != 0 {
+		t.Errorf("the last viewer sent %v", *sent)
+	}
+	if want := []string{"change"}; !reflect.DeepEqual(*events, want) {
+		t.Errorf("events %v, want %v", *events, want)
+	}
+}
+
+// SetClipboardViewer sends WM_DRAWCLIPBOARD before it returns the next viewer
+func TestViewerChainBeforeJoin(t *testing.T) {
+	c, sent, events := fakeChain()
+	c.HandleMessage(wm.DRAWCLIPBOARD, 0, 0)
+	if len(*sent) != 0 {
+		t.Errorf("sent %v before joining", *sent)
+	}
+	if want := []string{"change"}; !reflect.DeepEqual(*events, want) {
+		t.Errorf("events %v, want %v", *events, want)
+	}
+	if _, _, ok := c.Leave(); ok {
+		t.Error("left a chain that was never joined")
+	}
+}
+
+func TestViewerChainRepair(t *testing.T) {
+	c, sent, _ := fakeChain()
+	c.Join(2)
+
+	// the next viewer leaves, the one after it follows us now
+	if !c.HandleMessage(wm.CHANGECBCHAIN, 2, 3) {
+		t.Fatal("WM_CHANGECBCHAIN was not handled")
+	}
+	if len(*sent) != 0 {
+		t.Errorf("sent %v while repairing", *sent)
+	}
+	if next := c.Next(); next != 3 {
+		t.Errorf("next is %d, want 3", next)
+	}
+
+	// the last viewer leaves
+	c.HandleMessage(wm.CHANGECBCHAIN, 3, 0)
+	if next := c.Next(); next != 0 {
+		t.Errorf("next is %d, want 0", next)
+	}
+}
+
+func TestViewerChainForwardRemoval(t *testing.T) {
+	c, sent, _ := fakeChain()
+	c.Join(2)
+
+	// a viewer further down the chain leaves
+	c.HandleMessage(wm.CHANGECBCHAIN, 4, 5)
+	if want := []sentMessage{{2, wm.CHANGECBCHAIN, 4, 5}}; !reflect.DeepEqual(*sent, want) {
+		t.Errorf("sent %v, want %v", *sent, want)
+	}
+	if next := c.Next(); next != 2 {
+		t.Errorf("next changed to %d", next)
+	}
+}
+
+func TestViewerChainLeave(t *testing.T) {
+	c, _, _ := fakeChain()
+	c.Join(2)
+	c.HandleMessage(wm.CHANGECBCHAIN, 2, 3)
+
+	self, next, ok := c.Leave()
+	if !ok || self != 1 || next != 3 {
+		t.Errorf("Leave() = %d, %d, %v, want 1, 3, true", self, next, ok)
+	}
+	if _, _, ok := c.Leave(); ok {
+		t.Error("second Leave reported ok")
+	}
+}
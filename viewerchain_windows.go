@@ -0,0 +1,65 @@
+package clipboard
+
+import (
+	"syscall"
+
+	"github.com/kirides/go-winclipboard/hwnd"
+	"github.com/kirides/go-winclipboard/internal/winsys"
+	"github.com/kirides/go-winclipboard/wm"
+)
+
+// ClipboardViewer is a window registered in the legacy clipboard viewer chain
+type ClipboardViewer struct {
+	chain *ViewerChain
+}
+
+// JoinViewerChain registers w with SetClipboardViewer and calls onChange on the thread of w for every change.
+// It is an alternative to AddClipboardFormatListener for environments where that is unreliable, e.g. some RDP sessions.
+//
+// Handlers of w for WM_DRAWCLIPBOARD, WM_CHANGECBCHAIN and WM_NCDESTROY are replaced.
+// The window leaves the chain on Leave or once it is destroyed.
+// Joining sends an initial WM_DRAWCLIPBOARD, so onChange is called right away.
+// That message is meant for w only and is not forwarded.
+func JoinViewerChain(w *hwnd.Window, onChange func()) (*ClipboardViewer, error) {
+	c := NewViewerChain(uintptr(w.Handle), sendMessage, onChange)
+	v := &ClipboardViewer{chain: c}
+	handle := func(h syscall.Handle, msg uint32, wParam, lParam uintptr) uintptr {
+		if c.HandleMessage(msg, wParam, lParam) {
+			return 0
+		}
+		return hwnd.DefWindowProc(h, msg, wParam, lParam)
+	}
+	w.On(wm.DRAWCLIPBOARD, handle)
+	w.On(wm.CHANGECBCHAIN, handle)
+	w.On(wm.NCDESTROY, func(h syscall.Handle, msg uint32, wParam, lParam uintptr) uintptr {
+		v.Leave()
+		return hwnd.DefWindowProc(h, msg, wParam, lParam)
+	})
+
+	// on the thread of w no WM_CHANGECBCHAIN is handled before the next viewer is known.
+	// SetClipboardViewer returns zero both for errors and for the first viewer in the chain.
+	err := w.Invoke(func() {
+		c.Join(uintptr(winsys.SetClipboardViewer(w.Handle)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Leave removes the window from the chain, linking its predecessor to its successor
+func (v *ClipboardViewer) Leave() {
+	if self, next, ok := v.chain.Leave(); ok {
+		// the result only tells whether the other viewers handled WM_CHANGECBCHAIN
+		winsys.ChangeClipboardChain(syscall.Handle(self), syscall.Handle(next))
+	}
+}
+
+// Next returns the following viewer in the chain, zero if the window is the last one
+func (v *ClipboardViewer) Next() syscall.Handle {
+	return syscall.Handle(v.chain.Next())
+}
+
+func sendMessage(h uintptr, msg uint32, wParam, lParam uintptr) uintptr {
+	return winsys.SendMessage(syscall.Handle(h), msg, wParam, lParam)
+}
@@ -0,0 +1,101 @@
+package clipboard
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	DefaultPollInterval    = 250 * time.Millisecond
+	DefaultMaxPollInterval = 2 * time.Second
+)
+
+// Change is reported by the watchers whenever the clipboard contents changed
+type Change struct {
+	// SequenceNumber is the clipboard sequence number after the change, zero if unknown
+	SequenceNumber uint32
+	Time           time.Time
+}
+
+// Poller reports changes by polling a sequence number, for processes that can not run a message loop.
+//
+// Polling starts at Interval and doubles the delay after every poll without change, up to MaxInterval.
+// A change resets the delay to Interval.
+type Poller struct {
+	// Sequence returns the current sequence number, typically Backend.SequenceNumber
+	Sequence func() (uint32, error)
+	// Interval defaults to DefaultPollInterval
+	Interval time.Duration
+	// MaxInterval defaults to DefaultMaxPollInterval, a value below Interval disables backing off
+	MaxInterval time.Duration
+
+	// Now and After default to time.Now and time.After, tests might replace them
+	Now   func() time.Time
+	After func(time.Duration) <-chan time.Time
+}
+
+// NewPoller returns a Poller for the sequence number of b
+func NewPoller(b Backend) *Poller {
+	return &Poller{Sequence: b.SequenceNumber}
+}
+
+func (p *Poller) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+func (p *Poller) after(d time.Duration) <-chan time.Time {
+	if p.After != nil {
+		return p.After(d)
+	}
+	return time.After(d)
+}
+
+func (p *Poller) intervals() (base, limit time.Duration) {
+	base, limit = p.Interval, p.MaxInterval
+	if base <= 0 {
+		base = DefaultPollInterval
+	}
+	if limit == 0 {
+		limit = DefaultMaxPollInterval
+	}
+	if limit < base {
+		limit = base
+	}
+	return base, limit
+}
+
+// Run calls fn for every change until ctx is done or Sequence fails.
+// The sequence number at the start is the baseline, it is not reported.
+//
+// A sequence number of zero, returned by Windows without clipboard access, is never reported as change.
+func (p *Poller) Run(ctx context.Context, fn func(Change)) error {
+	base, limit := p.intervals()
+	last, err := p.Sequence()
+	if err != nil {
+		return err
+	}
+	delay := base
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.after(delay):
+		}
+		seq, err := p.Sequence()
+		if err != nil {
+			return err
+		}
+		if seq == last || seq == 0 {
+			if delay *= 2; delay > limit {
+				delay = limit
+			}
+			continue
+		}
+		last = seq
+		delay = base
+		fn(Change{SequenceNumber: seq, Time: p.now()})
+	}
+}
@@ -0,0 +1,134 @@
+package clipboard
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// scriptedPoller returns a Poller reading the sequence numbers from script and recording every delay.
+// ctx is cancelled once the script is exhausted.
+func scriptedPoller(ctx context.Context, cancel func(), script []uint32) (*Poller, *[]time.Duration) {
+	var delays []time.Duration
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := &Poller{
+		Sequence: func() (uint32, error) {
+			seq := script[0]
+			if len(script) > 1 {
+				script = script[1:]
+			} else {
+				cancel()
+			}
+			return seq, nil
+		},
+		Now: func() time.Time { return now },
+		After: func(d time.Duration) <-chan time.Time {
+			delays = append(delays, d)
+			if ctx.Err() != nil {
+				return nil
+			}
+			now = now.Add(d)
+			c := make(chan time.Time, 1)
+			c <- now
+			return c
+		},
+	}
+	return p, &delays
+}
+
+func TestPollerBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p, delays := scriptedPoller(ctx, cancel, []uint32{5, 5, 5, 5, 5, 5, 6, 0, 0, 7})
+	p.Interval, p.MaxInterval = 100*time.Millisecond, time.Second
+
+	var changes []uint32
+	err := p.Run(ctx, func(c Change) { changes = append(changes, c.SequenceNumber) })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v, want context.Canceled", err)
+	}
+
+	ms := time.Millisecond
+	want := []time.Duration{
+		100 * ms, 200 * ms, 400 * ms, 800 * ms, // doubling while nothing changes
+		1000 * ms, 1000 * ms, // clamped at MaxInterval
+		100 * ms, 200 * ms, 400 * ms, // reset by the change, zero is not a change
+		100 * ms, // reset by the second change
+	}
+	if !reflect.DeepEqual(*delays, want) {
+		t.Errorf("delays %v, want %v", *delays, want)
+	}
+	if want := []uint32{6, 7}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes %v, want %v", changes, want)
+	}
+}
+
+func TestPollerNoBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p, delays := scriptedPoller(ctx, cancel, []uint32{1, 1, 1, 1})
+	p.Interval, p.MaxInterval = 50*time.Millisecond, time.Millisecond
+
+	p.Run(ctx, func(Change) { t.Error("reported a change") })
+	for _, d := range *delays {
+		if d != 50*time.Millisecond {
+			t.Errorf("delays %v, want 50ms each", *delays)
+			break
+		}
+	}
+}
+
+func TestPollerChangeTime(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p, _ := scriptedPoller(ctx, cancel, []uint32{1, 2})
+
+	var got []Change
+	p.Run(ctx, func(c Change) { got = append(got, c) })
+	want := []Change{{SequenceNumber: 2, Time: time.Date(2021, 1, 1, 0, 0, 0, int(DefaultPollInterval), time.UTC)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPollerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Poller{
+		Sequence: func() (uint32, error) { return 1, nil },
+		After:    func(time.Duration) <-chan time.Time { return nil },
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- p.Run(ctx, func(Change) {}) }()
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestPollerSequenceError(t *testing.T) {
+	errAccess := errors.New("access denied")
+	calls := 0
+	p := &Poller{
+		Sequence: func() (uint32, error) {
+			if calls++; calls > 1 {
+				return 0, errAccess
+			}
+			return 1, nil
+		},
+		After: func(time.Duration) <-chan time.Time {
+			c := make(chan time.Time, 1)
+			c <- time.Time{}
+			return c
+		},
+	}
+	if err := p.Run(context.Background(), func(Change) {}); !errors.Is(err, errAccess) {
+		t.Errorf("got %v, want %v", err, errAccess)
+	}
+}
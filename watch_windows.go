@@ -0,0 +1,80 @@
+package clipboard
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/kirides/go-winclipboard/hwnd"
+	"github.com/kirides/go-winclipboard/internal/winsys"
+	"github.com/kirides/go-winclipboard/wm"
+)
+
+// WatchMode selects how Watch learns about changes
+type WatchMode int
+
+const (
+	// WatchListener uses AddClipboardFormatListener on a message-only window
+	WatchListener WatchMode = iota
+	// WatchViewerChain joins the legacy clipboard viewer chain
+	WatchViewerChain
+	// WatchPoll polls GetClipboardSequenceNumber, it works without a message loop
+	WatchPoll
+)
+
+// WatchOptions configure Watch
+type WatchOptions struct {
+	Mode WatchMode
+	// Interval and MaxInterval configure WatchPoll, see Poller
+	Interval    time.Duration
+	MaxInterval time.Duration
+}
+
+// Watch calls fn for every clipboard change until ctx is done.
+// fn is called from a single goroutine, the contents at the start are not reported.
+func Watch(ctx context.Context, opts WatchOptions, fn func(Change)) error {
+	switch opts.Mode {
+	case WatchPoll:
+		p := NewPoller(systemBackend{})
+		p.Interval, p.MaxInterval = opts.Interval, opts.MaxInterval
+		return p.Run(ctx, fn)
+	case WatchListener, WatchViewerChain:
+	default:
+		return fmt.Errorf("unknown watch mode %d", opts.Mode)
+	}
+
+	w, err := hwnd.New(nil)
+	if err != nil {
+		return err
+	}
+	var ready int32
+	notify := func() {
+		if atomic.LoadInt32(&ready) != 0 {
+			fn(Change{SequenceNumber: winsys.GetClipboardSequenceNumber(), Time: time.Now()})
+		}
+	}
+
+	if opts.Mode == WatchViewerChain {
+		// joining reports the current contents, which Watch does not
+		v, err := JoinViewerChain(w, notify)
+		if err != nil {
+			w.Close()
+			return fmt.Errorf("SetClipboardViewer. %w", err)
+		}
+		defer v.Leave()
+	} else {
+		w.On(wm.CLIPBOARDUPDATE, func(h syscall.Handle, msg uint32, wParam, lParam uintptr) uintptr {
+			notify()
+			return 0
+		})
+		if err := AddClipboardFormatListener(w.Handle); err != nil {
+			w.Close()
+			return fmt.Errorf("AddClipboardFormatListener. %w", err)
+		}
+		defer RemoveClipboardFormatListener(w.Handle)
+	}
+	atomic.StoreInt32(&ready, 1)
+	return w.ProcessMessagesContext(ctx)
+}
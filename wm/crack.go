@@ -0,0 +1,360 @@
+package wm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Message is a window message with decoded wParam and lParam
+type Message interface {
+	// Describe returns a single line suitable for logging, starting with the message name
+	Describe() string
+}
+
+// Crack decodes wParam and lParam of msg.
+// Messages without a dedicated type are returned as Raw.
+//
+// Pointers and handles are returned as they are, Crack never dereferences them.
+func Crack(msg uint32, wParam, lParam uintptr) Message {
+	switch msg {
+	case SIZE:
+		return Size{Type: uint32(wParam), Width: int(loword(lParam)), Height: int(hiword(lParam))}
+	case MOVE:
+		return Move{X: int(int16(loword(lParam))), Y: int(int16(hiword(lParam)))}
+	case MOUSEMOVE, LBUTTONDOWN, LBUTTONUP, LBUTTONDBLCLK, RBUTTONDOWN, RBUTTONUP, RBUTTONDBLCLK,
+		MBUTTONDOWN, MBUTTONUP, MBUTTONDBLCLK, XBUTTONDOWN, XBUTTONUP, XBUTTONDBLCLK, MOUSEWHEEL, MOUSEHWHEEL:
+		m := Mouse{
+			Msg:  msg,
+			X:    int(int16(loword(lParam))),
+			Y:    int(int16(hiword(lParam))),
+			Keys: uint32(loword(wParam)),
+		}
+		switch msg {
+		case MOUSEWHEEL, MOUSEHWHEEL:
+			m.WheelDelta = int(int16(hiword(wParam)))
+		case XBUTTONDOWN, XBUTTONUP, XBUTTONDBLCLK:
+			m.XButton = int(hiword(wParam))
+		}
+		return m
+	case KEYDOWN, KEYUP, SYSKEYDOWN, SYSKEYUP:
+		return Key{
+			Msg:          msg,
+			VirtualKey:   uint32(wParam),
+			RepeatCount:  int(loword(lParam)),
+			ScanCode:     uint8(lParam >> 16),
+			Extended:     lParam&(1<<24) != 0,
+			AltDown:      lParam&(1<<29) != 0,
+			PreviousDown: lParam&(1<<30) != 0,
+			Released:     lParam&(1<<31) != 0,
+		}
+	case COPYDATA:
+		return CopyData{Sender: wParam, CopyDataStruct: lParam}
+	case DROPFILES:
+		return DropFiles{HDrop: wParam}
+	case HOTKEY:
+		return HotKey{ID: int(int32(wParam)), Modifiers: uint32(loword(lParam)), VirtualKey: uint32(hiword(lParam))}
+	case POWERBROADCAST:
+		return PowerBroadcast{Event: uint32(wParam), Data: lParam}
+	case DEVICECHANGE:
+		return DeviceChange{Event: uint32(wParam), Data: lParam}
+	case CHANGECBCHAIN:
+		return ChangeCBChain{Removed: wParam, Next: lParam}
+	case RENDERFORMAT:
+		return RenderFormat{Format: uint32(wParam)}
+	}
+	return Raw{Msg: msg, WParam: wParam, LParam: lParam}
+}
+
+// Describe is a shorthand for Crack(msg, wParam, lParam).Describe()
+func Describe(msg uint32, wParam, lParam uintptr) string {
+	return Crack(msg, wParam, lParam).Describe()
+}
+
+func loword(v uintptr) uint16 {
+	return uint16(v)
+}
+
+func hiword(v uintptr) uint16 {
+	return uint16(v >> 16)
+}
+
+// flagNames renders the set bits of v as names joined by "|", unknown bits in hex
+func flagNames(v uint32, names []flagName) string {
+	if v == 0 {
+		return "0"
+	}
+	var parts []string
+	for _, n := range names {
+		if v&n.bit != 0 {
+			parts = append(parts, n.name)
+			v &^= n.bit
+		}
+	}
+	if v != 0 {
+		parts = append(parts, fmt.Sprintf("0x%X", v))
+	}
+	return strings.Join(parts, "|")
+}
+
+type flagName struct {
+	bit  uint32
+	name string
+}
+
+// Raw is a message without a dedicated type
+type Raw struct {
+	Msg            uint32
+	WParam, LParam uintptr
+}
+
+func (m Raw) Describe() string {
+	return fmt.Sprintf("%s wParam=0x%X lParam=0x%X", String(m.Msg), m.WParam, m.LParam)
+}
+
+// Size types of WM_SIZE
+const (
+	SIZE_RESTORED  = 0
+	SIZE_MINIMIZED = 1
+	SIZE_MAXIMIZED = 2
+	SIZE_MAXSHOW   = 3
+	SIZE_MAXHIDE   = 4
+)
+
+var sizeTypeNames = []string{"restored", "minimized", "maximized", "maxshow", "maxhide"}
+
+// Size is WM_SIZE, Width and Height are the new client area
+type Size struct {
+	Type          uint32
+	Width, Height int
+}
+
+func (m Size) Describe() string {
+	typ := fmt.Sprint(m.Type)
+	if int(m.Type) < len(sizeTypeNames) {
+		typ = sizeTypeNames[m.Type]
+	}
+	return fmt.Sprintf("WM_SIZE %s %dx%d", typ, m.Width, m.Height)
+}
+
+// Move is WM_MOVE, X and Y are the new position of the client area
+type Move struct {
+	X, Y int
+}
+
+func (m Move) Describe() string {
+	return fmt.Sprintf("WM_MOVE (%d,%d)", m.X, m.Y)
+}
+
+// Key states of Mouse.Keys
+const (
+	MK_LBUTTON  = 0x0001
+	MK_RBUTTON  = 0x0002
+	MK_SHIFT    = 0x0004
+	MK_CONTROL  = 0x0008
+	MK_MBUTTON  = 0x0010
+	MK_XBUTTON1 = 0x0020
+	MK_XBUTTON2 = 0x0040
+)
+
+var mouseKeyNames = []flagName{
+	{MK_LBUTTON, "MK_LBUTTON"}, {MK_RBUTTON, "MK_RBUTTON"}, {MK_SHIFT, "MK_SHIFT"}, {MK_CONTROL, "MK_CONTROL"},
+	{MK_MBUTTON, "MK_MBUTTON"}, {MK_XBUTTON1, "MK_XBUTTON1"}, {MK_XBUTTON2, "MK_XBUTTON2"},
+}
+
+// WheelDelta is the distance of one notch of the mouse wheel
+const WheelDelta = 120
+
+// Mouse is a mouse message in the range WM_MOUSEMOVE to WM_MOUSEHWHEEL.
+//
+// X and Y are client coordinates, except for WM_MOUSEWHEEL and WM_MOUSEHWHEEL which report screen coordinates.
+type Mouse struct {
+	Msg  uint32
+	X, Y int
+	Keys uint32
+	// WheelDelta is set for wheel messages, a multiple of WheelDelta for notched wheels
+	WheelDelta int
+	// XButton is 1 or 2 for the WM_XBUTTON messages
+	XButton int
+}
+
+func (m Mouse) Describe() string {
+	s := fmt.Sprintf("%s (%d,%d) keys=%s", String(m.Msg), m.X, m.Y, flagNames(m.Keys, mouseKeyNames))
+	switch m.Msg {
+	case MOUSEWHEEL, MOUSEHWHEEL:
+		s += fmt.Sprintf(" delta=%d", m.WheelDelta)
+	case XBUTTONDOWN, XBUTTONUP, XBUTTONDBLCLK:
+		s += fmt.Sprintf(" button=%d", m.XButton)
+	}
+	return s
+}
+
+// Key is WM_KEYDOWN, WM_KEYUP, WM_SYSKEYDOWN or WM_SYSKEYUP
+type Key struct {
+	Msg         uint32
+	VirtualKey  uint32
+	RepeatCount int
+	ScanCode    uint8
+	// Extended is set for keys like the right ALT and CTRL or the arrow keys outside the numpad
+	Extended bool
+	// AltDown is the context code, set if ALT is held
+	AltDown bool
+	// PreviousDown is set if the key was down before, i.e. for auto-repeat
+	PreviousDown bool
+	// Released is the transition state, set for the up messages
+	Released bool
+}
+
+func (m Key) Describe() string {
+	s := fmt.Sprintf("%s vk=0x%02X scan=0x%02X repeat=%d", String(m.Msg), m.VirtualKey, m.ScanCode, m.RepeatCount)
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{{m.Extended, "extended"}, {m.AltDown, "alt"}, {m.PreviousDown, "previous"}, {m.Released, "released"}} {
+		if f.set {
+			s += " " + f.name
+		}
+	}
+	return s
+}
+
+// CopyData is WM_COPYDATA.
+// CopyDataStruct points to a COPYDATASTRUCT, which is only valid while the message is handled.
+type CopyData struct {
+	Sender         uintptr
+	CopyDataStruct uintptr
+}
+
+func (m CopyData) Describe() string {
+	return fmt.Sprintf("WM_COPYDATA sender=0x%X data=0x%X", m.Sender, m.CopyDataStruct)
+}
+
+// DropFiles is WM_DROPFILES, HDrop is passed to DragQueryFile
+type DropFiles struct {
+	HDrop uintptr
+}
+
+func (m DropFiles) Describe() string {
+	return fmt.Sprintf("WM_DROPFILES hdrop=0x%X", m.HDrop)
+}
+
+// Modifiers of HotKey.Modifiers
+const (
+	MOD_ALT     = 0x0001
+	MOD_CONTROL = 0x0002
+	MOD_SHIFT   = 0x0004
+	MOD_WIN     = 0x0008
+)
+
+// IDs of the predefined hot keys
+const (
+	IDHOT_SNAPWINDOW  = -1
+	IDHOT_SNAPDESKTOP = -2
+)
+
+var modifierNames = []flagName{{MOD_ALT, "MOD_ALT"}, {MOD_CONTROL, "MOD_CONTROL"}, {MOD_SHIFT, "MOD_SHIFT"}, {MOD_WIN, "MOD_WIN"}}
+
+// HotKey is WM_HOTKEY, ID is the id passed to RegisterHotKey
+type HotKey struct {
+	ID         int
+	Modifiers  uint32
+	VirtualKey uint32
+}
+
+func (m HotKey) Describe() string {
+	return fmt.Sprintf("WM_HOTKEY id=%d modifiers=%s vk=0x%02X", m.ID, flagNames(m.Modifiers, modifierNames), m.VirtualKey)
+}
+
+// Power management events of PowerBroadcast.Event
+const (
+	PBT_APMSUSPEND           = 0x0004
+	PBT_APMRESUMESUSPEND     = 0x0007
+	PBT_APMPOWERSTATUSCHANGE = 0x000A
+	PBT_APMRESUMEAUTOMATIC   = 0x0012
+	PBT_POWERSETTINGCHANGE   = 0x8013
+)
+
+var powerEventNames = map[uint32]string{
+	PBT_APMSUSPEND:           "PBT_APMSUSPEND",
+	PBT_APMRESUMESUSPEND:     "PBT_APMRESUMESUSPEND",
+	PBT_APMPOWERSTATUSCHANGE: "PBT_APMPOWERSTATUSCHANGE",
+	PBT_APMRESUMEAUTOMATIC:   "PBT_APMRESUMEAUTOMATIC",
+	PBT_POWERSETTINGCHANGE:   "PBT_POWERSETTINGCHANGE",
+}
+
+// PowerBroadcast is WM_POWERBROADCAST.
+// Data points to a POWERBROADCAST_SETTING for PBT_POWERSETTINGCHANGE.
+type PowerBroadcast struct {
+	Event uint32
+	Data  uintptr
+}
+
+func (m PowerBroadcast) Describe() string {
+	return fmt.Sprintf("WM_POWERBROADCAST %s data=0x%X", eventName(powerEventNames, m.Event), m.Data)
+}
+
+// Device events of DeviceChange.Event
+const (
+	DBT_DEVNODES_CHANGED        = 0x0007
+	DBT_QUERYCHANGECONFIG       = 0x0017
+	DBT_CONFIGCHANGED           = 0x0018
+	DBT_CONFIGCHANGECANCELED    = 0x0019
+	DBT_DEVICEARRIVAL           = 0x8000
+	DBT_DEVICEQUERYREMOVE       = 0x8001
+	DBT_DEVICEQUERYREMOVEFAILED = 0x8002
+	DBT_DEVICEREMOVEPENDING     = 0x8003
+	DBT_DEVICEREMOVECOMPLETE    = 0x8004
+	DBT_DEVICETYPESPECIFIC      = 0x8005
+	DBT_CUSTOMEVENT             = 0x8006
+)
+
+var deviceEventNames = map[uint32]string{
+	DBT_DEVNODES_CHANGED:        "DBT_DEVNODES_CHANGED",
+	DBT_QUERYCHANGECONFIG:       "DBT_QUERYCHANGECONFIG",
+	DBT_CONFIGCHANGED:           "DBT_CONFIGCHANGED",
+	DBT_CONFIGCHANGECANCELED:    "DBT_CONFIGCHANGECANCELED",
+	DBT_DEVICEARRIVAL:           "DBT_DEVICEARRIVAL",
+	DBT_DEVICEQUERYREMOVE:       "DBT_DEVICEQUERYREMOVE",
+	DBT_DEVICEQUERYREMOVEFAILED: "DBT_DEVICEQUERYREMOVEFAILED",
+	DBT_DEVICEREMOVEPENDING:     "DBT_DEVICEREMOVEPENDING",
+	DBT_DEVICEREMOVECOMPLETE:    "DBT_DEVICEREMOVECOMPLETE",
+	DBT_DEVICETYPESPECIFIC:      "DBT_DEVICETYPESPECIFIC",
+	DBT_CUSTOMEVENT:             "DBT_CUSTOMEVENT",
+}
+
+// DeviceChange is WM_DEVICECHANGE.
+// Data points to a DEV_BROADCAST_HDR for most events.
+type DeviceChange struct {
+	Event uint32
+	Data  uintptr
+}
+
+func (m DeviceChange) Describe() string {
+	return fmt.Sprintf("WM_DEVICECHANGE %s data=0x%X", eventName(deviceEventNames, m.Event), m.Data)
+}
+
+func eventName(names map[uint32]string, event uint32) string {
+	if name, ok := names[event]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%X", event)
+}
+
+// ChangeCBChain is WM_CHANGECBCHAIN, sent to clipboard viewers when Removed leaves the chain
+type ChangeCBChain struct {
+	Removed uintptr
+	// Next is the window following Removed, or zero if Removed was the last one
+	Next uintptr
+}
+
+func (m ChangeCBChain) Describe() string {
+	return fmt.Sprintf("WM_CHANGECBCHAIN removed=0x%X next=0x%X", m.Removed, m.Next)
+}
+
+// RenderFormat is WM_RENDERFORMAT, asking the clipboard owner for the data of a delayed format
+type RenderFormat struct {
+	Format uint32
+}
+
+func (m RenderFormat) Describe() string {
+	return fmt.Sprintf("WM_RENDERFORMAT format=%d", m.Format)
+}
@@ -0,0 +1,75 @@
+package wm
+
+import (
+	"reflect"
+	"testing"
+)
+
+// makeLong packs two words like MAKELPARAM
+func makeLong(lo, hi uint16) uintptr {
+	return uintptr(lo) | uintptr(hi)<<16
+}
+
+func TestCrack(t *testing.T) {
+	tests := []struct {
+		name           string
+		msg            uint32
+		wParam, lParam uintptr
+		want           Message
+	}{
+		{"size", SIZE, SIZE_MAXIMIZED, makeLong(1920, 1080), Size{Type: SIZE_MAXIMIZED, Width: 1920, Height: 1080}},
+		{"move on a monitor left of the primary", MOVE, 0, makeLong(0xFF38, 0xFFFF), Move{X: -200, Y: -1}},
+		{"mouse move with negative coordinates", MOUSEMOVE, MK_LBUTTON | MK_SHIFT, makeLong(0xFFF6, 0x8000),
+			Mouse{Msg: MOUSEMOVE, X: -10, Y: -32768, Keys: MK_LBUTTON | MK_SHIFT}},
+		{"mouse move with positive coordinates", MOUSEMOVE, 0, makeLong(0x7FFF, 5), Mouse{Msg: MOUSEMOVE, X: 32767, Y: 5}},
+		{"wheel forward", MOUSEWHEEL, makeLong(MK_CONTROL, WheelDelta), makeLong(100, 200),
+			Mouse{Msg: MOUSEWHEEL, X: 100, Y: 200, Keys: MK_CONTROL, WheelDelta: WheelDelta}},
+		{"wheel backward", MOUSEWHEEL, makeLong(0, 0xFF88), 0, Mouse{Msg: MOUSEWHEEL, WheelDelta: -WheelDelta}},
+		{"horizontal wheel, fine grained", MOUSEHWHEEL, makeLong(0, 0xFFFD), 0, Mouse{Msg: MOUSEHWHEEL, WheelDelta: -3}},
+		{"first x button", XBUTTONDOWN, makeLong(MK_XBUTTON1, 1), makeLong(1, 2),
+			Mouse{Msg: XBUTTONDOWN, X: 1, Y: 2, Keys: MK_XBUTTON1, XButton: 1}},
+		{"second x button", XBUTTONUP, makeLong(0, 2), 0, Mouse{Msg: XBUTTONUP, XButton: 2}},
+		{"hiword is ignored outside wheel and x button messages", LBUTTONDOWN, makeLong(MK_LBUTTON, 0xFFFF), 0,
+			Mouse{Msg: LBUTTONDOWN, Keys: MK_LBUTTON}},
+		{"key down", KEYDOWN, 0x41, 0x001E0001, Key{Msg: KEYDOWN, VirtualKey: 0x41, RepeatCount: 1, ScanCode: 0x1E}},
+		{"extended key", KEYDOWN, 0x27, 1<<24 | 0x4D<<16 | 1,
+			Key{Msg: KEYDOWN, VirtualKey: 0x27, RepeatCount: 1, ScanCode: 0x4D, Extended: true}},
+		{"alt held", SYSKEYDOWN, 0x73, 1<<29 | 0x3E<<16 | 1,
+			Key{Msg: SYSKEYDOWN, VirtualKey: 0x73, RepeatCount: 1, ScanCode: 0x3E, AltDown: true}},
+		{"auto repeat", KEYDOWN, 0x41, 1<<30 | 0x1E<<16 | 3,
+			Key{Msg: KEYDOWN, VirtualKey: 0x41, RepeatCount: 3, ScanCode: 0x1E, PreviousDown: true}},
+		{"key up", KEYUP, 0x41, 1<<31 | 1<<30 | 0x1E<<16 | 1,
+			Key{Msg: KEYUP, VirtualKey: 0x41, RepeatCount: 1, ScanCode: 0x1E, PreviousDown: true, Released: true}},
+		{"all key flags", SYSKEYUP, 0x12, 0xE1380001,
+			Key{Msg: SYSKEYUP, VirtualKey: 0x12, RepeatCount: 1, ScanCode: 0x38, Extended: true, AltDown: true, PreviousDown: true, Released: true}},
+		{"hot key", HOTKEY, 7, makeLong(MOD_CONTROL|MOD_ALT, 0x56), HotKey{ID: 7, Modifiers: MOD_CONTROL | MOD_ALT, VirtualKey: 0x56}},
+		{"predefined hot key", HOTKEY, ^uintptr(0), 0, HotKey{ID: IDHOT_SNAPWINDOW}},
+		{"change clipboard chain", CHANGECBCHAIN, 0x1234, 0x5678, ChangeCBChain{Removed: 0x1234, Next: 0x5678}},
+		{"raw", 0x1234, 1, 2, Raw{Msg: 0x1234, WParam: 1, LParam: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Crack(tt.msg, tt.wParam, tt.lParam); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		msg            uint32
+		wParam, lParam uintptr
+		want           string
+	}{
+		{KEYDOWN, 0x41, 0x001E0001, "WM_KEYDOWN vk=0x41 scan=0x1E repeat=1"},
+		{KEYUP, 0x27, 0xC14D0001, "WM_KEYUP vk=0x27 scan=0x4D repeat=1 extended previous released"},
+		{MOUSEWHEEL, makeLong(MK_SHIFT|0x100, 0xFF88), makeLong(0xFFFF, 3), "WM_MOUSEWHEEL (-1,3) keys=MK_SHIFT|0x100 delta=-120"},
+		{XBUTTONDBLCLK, makeLong(0, 2), 0, "WM_XBUTTONDBLCLK (0,0) keys=0 button=2"},
+	}
+	for _, tt := range tests {
+		if got := Describe(tt.msg, tt.wParam, tt.lParam); got != tt.want {
+			t.Errorf("Describe(0x%X, 0x%X, 0x%X) = %q, want %q", tt.msg, tt.wParam, tt.lParam, got, tt.want)
+		}
+	}
+}
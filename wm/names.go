@@ -0,0 +1,56 @@
+package wm
+
+import "sync"
+
+// Range of the message ids returned by RegisterWindowMessage
+const (
+	RegisteredFirst = 0xC000
+	RegisteredLast  = 0xFFFF
+)
+
+var (
+	namesMu sync.RWMutex
+	// names holds the names passed to RegisterName
+	names = map[uint32]string{}
+	// registeredNames caches the names of registered messages, they are never freed by the system
+	registeredNames = map[uint32]string{}
+)
+
+// RegisterName names the application defined message msg, e.g. RegisterName(wm.APP+1, "WM_TRAYICON").
+// These names take precedence over the built-in ones, an empty name removes the entry.
+//
+// It also names registered messages where the system can not be asked, e.g. outside of Windows.
+func RegisterName(msg uint32, name string) {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	if name == "" {
+		delete(names, msg)
+		return
+	}
+	names[msg] = name
+}
+
+func lookupName(msg uint32) (string, bool) {
+	namesMu.RLock()
+	defer namesMu.RUnlock()
+	name, ok := names[msg]
+	return name, ok
+}
+
+// registeredName resolves the name of a registered message, caching successful lookups
+func registeredName(msg uint32) (string, bool) {
+	namesMu.RLock()
+	name, ok := registeredNames[msg]
+	namesMu.RUnlock()
+	if ok {
+		return name, true
+	}
+	name, ok = systemMessageName(msg)
+	if !ok {
+		return "", false
+	}
+	namesMu.Lock()
+	registeredNames[msg] = name
+	namesMu.Unlock()
+	return name, true
+}
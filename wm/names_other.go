@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package wm
+
+// systemMessageName fails, only names passed to RegisterName are known
+func systemMessageName(msg uint32) (string, bool) {
+	return "", false
+}
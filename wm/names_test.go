@@ -0,0 +1,78 @@
+package wm
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		msg  uint32
+		want string
+	}{
+		{NULL, "WM_NULL"},
+		{CLIPBOARDUPDATE, "WM_CLIPBOARDUPDATE"},
+		{USER - 1, "1023"},
+		{USER, "WM_USER"},
+		{USER + 1, "WM_USER+1"},
+		{APP - 1, "WM_USER+31743"},
+		{APP, "WM_APP"},
+		{APP + 1, "WM_APP+1"},
+		{RegisteredFirst - 1, "WM_APP+16383"},
+		{RegisteredLast + 1, "65536"},
+		{0xFFFFFFFF, "4294967295"},
+	}
+	for _, tt := range tests {
+		if got := WM(tt.msg).String(); got != tt.want {
+			t.Errorf("WM(0x%X) = %q, want %q", tt.msg, got, tt.want)
+		}
+		if got := String(tt.msg); got != tt.want {
+			t.Errorf("String(0x%X) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestStringRegistered(t *testing.T) {
+	for _, msg := range []uint32{RegisteredFirst, 0xC123, RegisteredLast} {
+		got := WM(msg).String()
+		if got == strconv.FormatUint(uint64(msg), 10) {
+			t.Errorf("WM(0x%X) is not symbolic", msg)
+		}
+		// on Windows the id might be the atom of a message registered by another program
+		if want := fmt.Sprintf("WM_REGISTERED(0x%04X)", msg); runtime.GOOS != "windows" && got != want {
+			t.Errorf("WM(0x%X) = %q, want %q", msg, got, want)
+		}
+	}
+}
+
+func TestRegisterName(t *testing.T) {
+	tests := []struct {
+		msg          uint32
+		name, before string
+	}{
+		{USER + 5, "WM_MYUSER", "WM_USER+5"},
+		{APP + 1, "WM_TRAYICON", "WM_APP+1"},
+		{RegisteredLast + 1, "WM_OUTOFRANGE", "65536"},
+		// registered names take precedence over the built-in ones
+		{CLIPBOARDUPDATE, "WM_CLIPBOARDCHANGED", "WM_CLIPBOARDUPDATE"},
+	}
+	for _, tt := range tests {
+		RegisterName(tt.msg, tt.name)
+		if got := WM(tt.msg).String(); got != tt.name {
+			t.Errorf("WM(0x%X) = %q after RegisterName, want %q", tt.msg, got, tt.name)
+		}
+		RegisterName(tt.msg, "")
+		if got := WM(tt.msg).String(); got != tt.before {
+			t.Errorf("WM(0x%X) = %q after removing the name, want %q", tt.msg, got, tt.before)
+		}
+	}
+
+	// registered messages can be named where the system is not asked
+	RegisterName(0xC123, "TaskbarCreated")
+	defer RegisterName(0xC123, "")
+	if got := WM(0xC123).String(); got != "TaskbarCreated" {
+		t.Errorf("WM(0xC123) = %q, want TaskbarCreated", got)
+	}
+}
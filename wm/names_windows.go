@@ -0,0 +1,18 @@
+package wm
+
+import (
+	"unicode/utf16"
+
+	"github.com/kirides/go-winclipboard/internal/winsys"
+)
+
+// systemMessageName asks the system for the name of a registered message.
+// Window messages and clipboard formats share the same atom table, so GetClipboardFormatNameW resolves both.
+func systemMessageName(msg uint32) (string, bool) {
+	var buf [256]uint16
+	n, err := winsys.GetClipboardFormatName(msg, &buf[0], int32(len(buf)))
+	if err != nil || n <= 0 {
+		return "", false
+	}
+	return string(utf16.Decode(buf[:n])), true
+}
@@ -1,6 +1,9 @@
 package wm
 
-import "strconv"
+import (
+	"fmt"
+	"strconv"
+)
 
 // Contains some Window Messages extracted from WIN 10 SDK
 
@@ -146,6 +149,11 @@ const (
 	MBUTTONDOWN             = 0x0207
 	MBUTTONUP               = 0x0208
 	MBUTTONDBLCLK           = 0x0209
+	MOUSEWHEEL              = 0x020A
+	XBUTTONDOWN             = 0x020B
+	XBUTTONUP               = 0x020C
+	XBUTTONDBLCLK           = 0x020D
+	MOUSEHWHEEL             = 0x020E
 	PARENTNOTIFY            = 0x0210
 	ENTERMENULOOP           = 0x0211
 	EXITMENULOOP            = 0x0212
@@ -373,6 +381,11 @@ var mapWMMsg = map[uint32]string{
 	MBUTTONDOWN:             "WM_MBUTTONDOWN",
 	MBUTTONUP:               "WM_MBUTTONUP",
 	MBUTTONDBLCLK:           "WM_MBUTTONDBLCLK",
+	MOUSEWHEEL:              "WM_MOUSEWHEEL",
+	XBUTTONDOWN:             "WM_XBUTTONDOWN",
+	XBUTTONUP:               "WM_XBUTTONUP",
+	XBUTTONDBLCLK:           "WM_XBUTTONDBLCLK",
+	MOUSEHWHEEL:             "WM_MOUSEHWHEEL",
 	PARENTNOTIFY:            "WM_PARENTNOTIFY",
 	ENTERMENULOOP:           "WM_ENTERMENULOOP",
 	EXITMENULOOP:            "WM_EXITMENULOOP",
@@ -468,14 +481,38 @@ var mapWMMsg = map[uint32]string{
 
 type WM uint32
 
+// String returns the name of wm. Unnamed messages of the WM_USER and WM_APP ranges are rendered
+// as offset ("WM_USER+5"), registered messages are looked up by their atom.
 func (wm WM) String() string {
-	if name, ok := mapWMMsg[uint32(wm)]; ok {
+	msg := uint32(wm)
+	if name, ok := lookupName(msg); ok {
+		return name
+	}
+	if name, ok := mapWMMsg[msg]; ok {
 		return name
-	} else {
-		return strconv.FormatUint(uint64(wm), 10)
 	}
+	switch {
+	case msg >= USER && msg < APP:
+		return offsetName("WM_USER", msg-USER)
+	case msg >= APP && msg < RegisteredFirst:
+		return offsetName("WM_APP", msg-APP)
+	case msg >= RegisteredFirst && msg <= RegisteredLast:
+		if name, ok := registeredName(msg); ok {
+			return name
+		}
+		return fmt.Sprintf("WM_REGISTERED(0x%04X)", msg)
+	}
+	return strconv.FormatUint(uint64(wm), 10)
 }
+
+// offsetName returns base, followed by the offset n unless it is zero
+func offsetName(base string, n uint32) string {
+	if n == 0 {
+		return base
+	}
+	return base + "+" + strconv.FormatUint(uint64(n), 10)
+}
+
 func String(wm uint32) string {
 	return WM(wm).String()
 }
-